@@ -0,0 +1,195 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// DefaultAnycastGap is the round-trip time difference above which replies
+// with the same hop limit are attributed to different anycast instances.
+const DefaultAnycastGap = 10 * time.Millisecond
+
+// anycastVariants is the number of distinct identifier and payload size
+// combinations probes cycle through.
+const anycastVariants = 8
+
+// AnycastReport is the analysis of the replies to probes of an IPv6 anycast
+// address.
+type AnycastReport struct {
+	// Addr is the string address of the host being pinged.
+	Addr string
+
+	// PacketsSent is the number of probes sent.
+	PacketsSent int
+
+	// PacketsRecv is the number of replies received.
+	PacketsRecv int
+
+	// Instances are the clusters of replies attributed to distinct
+	// instances, by increasing distance.
+	Instances []AnycastInstance
+}
+
+// AnycastInstance is a cluster of replies sharing a hop limit and a range of
+// round-trip times, likely sent by the same anycast instance.
+type AnycastInstance struct {
+	// HopLimit is the hop limit of the replies when received.
+	HopLimit int
+
+	// Hops is the estimated number of hops to the instance, assuming it
+	// sets a common initial hop limit of 64, 128 or 255.
+	Hops int
+
+	// Statistics are the round-trip time statistics of the replies.
+	Statistics *Statistics
+}
+
+// EstimatedInstances returns the number of distinct instances that answered.
+func (r *AnycastReport) EstimatedInstances() int {
+	return len(r.Instances)
+}
+
+type anycastReply struct {
+	hopLimit int
+	rtt      time.Duration
+}
+
+// AnalyzeAnycast sends count echo requests to an IPv6 target, one every
+// Interval, cycling through different identifiers and payload sizes so that
+// load balancers hash them onto different paths, and clusters the replies by
+// hop limit and round-trip time to estimate how many distinct anycast
+// instances are answering. Replies with the same hop limit whose round-trip
+// times are more than gap apart are attributed to different instances; gap
+// defaults to DefaultAnycastGap.
+//
+// Identifiers are only varied in privileged mode, as the kernel sets them for
+// unprivileged pings. This is a blocking function, interrupted when ctx is
+// done. It is not available in a pingminimal build, as it opens its own
+// socket.
+func (p *Pinger) AnalyzeAnycast(ctx context.Context, count int, gap time.Duration) (*AnycastReport, error) {
+	if MinimalSyscalls {
+		return nil, ErrMinimalSyscalls
+	}
+	if p.ipv4 {
+		return nil, errors.New("Anycast analysis is only available over IPv6")
+	}
+	if gap <= 0 {
+		gap = DefaultAnycastGap
+	}
+
+	conn, err := p.listenICMP(ipv6Proto[p.network], false)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer p.closeOnDone(ctx, conn)()
+	pc := conn.IPv6PacketConn()
+	if err := pc.SetControlMessage(ipv6.FlagHopLimit, true); err != nil {
+		return nil, err
+	}
+
+	report := &AnycastReport{Addr: p.addr}
+	var replies []anycastReply
+	bytes := make([]byte, 1500)
+	for seq := 0; seq < count; seq++ {
+		if err := p.contextErr(ctx); err != nil {
+			return nil, err
+		}
+
+		variant := seq % anycastVariants
+		id := (p.id + variant) & 0xffff
+		size := timeSliceLength + variant*16
+		probe := p.clone()
+		probe.id, probe.size = id, size
+		msg, dst, err := icmpProber{}.Marshal(probe, seq)
+		if err != nil {
+			return nil, err
+		}
+		sent := time.Now()
+		if _, err := conn.WriteTo(msg, dst); err != nil {
+			if ctxErr := p.contextErr(ctx); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+		report.PacketsSent++
+
+		deadline := sent.Add(p.Interval)
+		conn.SetReadDeadline(deadline)
+		for {
+			n, cm, _, err := pc.ReadFrom(bytes)
+			if err != nil {
+				break
+			}
+			pkt, err := icmpProber{}.Parse(probe, bytes[:n])
+			if err != nil || pkt == nil || pkt.Seq != seq {
+				continue
+			}
+			if cm == nil {
+				return nil, errors.New("Hop limit of replies unavailable")
+			}
+			report.PacketsRecv++
+			replies = append(replies, anycastReply{hopLimit: cm.HopLimit, rtt: pkt.Rtt})
+			p.sleepContext(ctx, deadline)
+			break
+		}
+	}
+
+	report.Instances = clusterAnycast(replies, gap)
+	for _, inst := range report.Instances {
+		inst.Statistics.Addr = p.addr
+		inst.Statistics.IPAddr = p.ipaddr
+	}
+	return report, nil
+}
+
+// clusterAnycast groups replies by hop limit, then splits each group where
+// consecutive round-trip times are more than gap apart.
+func clusterAnycast(replies []anycastReply, gap time.Duration) []AnycastInstance {
+	byHopLimit := make(map[int][]time.Duration)
+	for _, r := range replies {
+		byHopLimit[r.hopLimit] = append(byHopLimit[r.hopLimit], r.rtt)
+	}
+
+	var instances []AnycastInstance
+	for hopLimit, rtts := range byHopLimit {
+		sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+		start := 0
+		for i := 1; i <= len(rtts); i++ {
+			if i < len(rtts) && rtts[i]-rtts[i-1] <= gap {
+				continue
+			}
+			cluster := rtts[start:i:i]
+			instances = append(instances, AnycastInstance{
+				HopLimit:   hopLimit,
+				Hops:       initialHopLimit(hopLimit) - hopLimit,
+				Statistics: statistics(len(cluster), len(cluster), cluster),
+			})
+			start = i
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		a, b := instances[i], instances[j]
+		if a.Hops != b.Hops {
+			return a.Hops < b.Hops
+		}
+		return a.Statistics.MinRtt < b.Statistics.MinRtt
+	})
+	return instances
+}
+
+// initialHopLimit guesses the initial hop limit of a packet received with
+// hopLimit, from the common defaults of operating systems.
+func initialHopLimit(hopLimit int) int {
+	for _, initial := range []int{64, 128, 255} {
+		if hopLimit <= initial {
+			return initial
+		}
+	}
+	return hopLimit
+}
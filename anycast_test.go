@@ -0,0 +1,91 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClusterAnycast(t *testing.T) {
+	ms := time.Millisecond
+	replies := []anycastReply{
+		{hopLimit: 52, rtt: 12 * ms},
+		{hopLimit: 52, rtt: 14 * ms},
+		{hopLimit: 52, rtt: 80 * ms},
+		{hopLimit: 57, rtt: 5 * ms},
+		{hopLimit: 52, rtt: 11 * ms},
+		{hopLimit: 57, rtt: 6 * ms},
+	}
+	instances := clusterAnycast(replies, DefaultAnycastGap)
+	if len(instances) != 3 {
+		t.Fatalf("Expected %v instances, got %v", 3, len(instances))
+	}
+
+	expected := []struct {
+		hops    int
+		replies int
+		minRtt  time.Duration
+	}{
+		{7, 2, 5 * ms},
+		{12, 3, 11 * ms},
+		{12, 1, 80 * ms},
+	}
+	for i, e := range expected {
+		inst := instances[i]
+		if inst.Hops != e.hops {
+			t.Errorf("Expected %v, got %v", e.hops, inst.Hops)
+		}
+		if inst.Statistics.PacketsRecv != e.replies {
+			t.Errorf("Expected %v, got %v", e.replies, inst.Statistics.PacketsRecv)
+		}
+		if inst.Statistics.MinRtt != e.minRtt {
+			t.Errorf("Expected %v, got %v", e.minRtt, inst.Statistics.MinRtt)
+		}
+	}
+}
+
+func TestInitialHopLimit(t *testing.T) {
+	for hopLimit, initial := range map[int]int{64: 64, 50: 64, 65: 128, 120: 128, 240: 255} {
+		if got := initialHopLimit(hopLimit); got != initial {
+			t.Errorf("Expected %v, got %v", initial, got)
+		}
+	}
+}
+
+func TestAnalyzeAnycast(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	_, err = p.AnalyzeAnycast(context.Background(), 1, 0)
+	AssertError(t, err, "IPv4 target")
+
+	p, err = NewPinger(context.Background(), "::1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Interval = 100 * time.Millisecond
+	report, err := p.AnalyzeAnycast(context.Background(), 4, 0)
+	if MinimalSyscalls {
+		if err != ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Skipf("Can't ping ::1, skipping: %s", err)
+	}
+	if report.PacketsSent != 4 || report.PacketsRecv != 4 {
+		t.Fatalf("Expected 4 replies to 4 probes, got %d/%d",
+			report.PacketsRecv, report.PacketsSent)
+	}
+	if report.EstimatedInstances() != 1 {
+		t.Fatalf("Expected %v, got %v", 1, report.EstimatedInstances())
+	}
+	if hops := report.Instances[0].Hops; hops != 0 {
+		t.Errorf("Expected %v, got %v", 0, hops)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.AnalyzeAnycast(ctx, 4, 0); err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,109 @@
+package ping
+
+import (
+	"net"
+	"sync"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// batchSize is the number of packets a batchReader reads at once.
+const batchSize = 16
+
+// batchReader reads the packets received on an ICMP socket in batches, with
+// a single recvmmsg on Linux, into buffers recycled once the packets are
+// processed. The packets carry the time the kernel received them at, if the
+// socket timestamps them.
+type batchReader struct {
+	conn ipConn
+	raw4 bool
+	pool *sync.Pool
+
+	ms   []ipv4.Message
+	bufs []*[]byte
+	// next is the next of the n messages read to hand out
+	next, n int
+}
+
+// newBatchReader returns a batchReader reading packets of up to size bytes
+// from c.
+func newBatchReader(c ipConn, size int) *batchReader {
+	r := &batchReader{
+		conn: c,
+		pool: &sync.Pool{New: func() interface{} {
+			b := make([]byte, size)
+			return &b
+		}},
+		ms:   make([]ipv4.Message, batchSize),
+		bufs: make([]*[]byte, batchSize),
+	}
+	oob := len(ipv6.NewControlMessage(ipv6.FlagHopLimit | ipv6.FlagTrafficClass))
+	if c.IPv4PacketConn() != nil {
+		_, raw := c.LocalAddr().(*net.IPAddr)
+		r.raw4 = raw && rawHeaders
+		oob = len(ipv4.NewControlMessage(ipv4.FlagTTL))
+	}
+	for i := range r.ms {
+		r.ms[i].OOB = make([]byte, oob+timestampSpace)
+	}
+	return r
+}
+
+// read returns the next packet received, like readPacket, reading a new
+// batch once the last one is handed out.
+func (r *batchReader) read() (*packet, net.Addr, error) {
+	for r.next == r.n {
+		for i := range r.ms {
+			if r.bufs[i] == nil {
+				r.bufs[i] = r.pool.Get().(*[]byte)
+			}
+			r.ms[i].Buffers = [][]byte{*r.bufs[i]}
+			r.ms[i].OOB = r.ms[i].OOB[:cap(r.ms[i].OOB)]
+		}
+		var n int
+		var err error
+		if pc := r.conn.IPv4PacketConn(); pc != nil {
+			n, err = pc.ReadBatch(r.ms, 0)
+		} else {
+			n, err = r.conn.IPv6PacketConn().ReadBatch(r.ms, 0)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		r.next, r.n = 0, n
+	}
+	m := &r.ms[r.next]
+	pkt := &packet{bytes: *r.bufs[r.next], nbytes: m.N, pool: r.pool, buf: r.bufs[r.next]}
+	r.bufs[r.next] = nil
+	r.next++
+
+	oob := m.OOB[:m.NN]
+	pkt.received = parseTimestamp(oob)
+	if r.conn.IPv4PacketConn() != nil {
+		var cm ipv4.ControlMessage
+		if cm.Parse(oob) == nil {
+			pkt.ttl = cm.TTL
+		}
+		if r.raw4 {
+			pkt.nbytes = 0
+			pkt.stripHeader(m.N)
+		}
+		return pkt, m.Addr, nil
+	}
+	var cm ipv6.ControlMessage
+	if cm.Parse(oob) == nil {
+		pkt.ttl = cm.HopLimit
+		pkt.tos = cm.TrafficClass
+	}
+	return pkt, m.Addr, nil
+}
+
+// free recycles the buffer of a packet read by a batchReader, once it is
+// processed.
+func (pkt *packet) free() {
+	if pkt.pool != nil {
+		pkt.pool.Put(pkt.buf)
+		pkt.pool, pkt.buf = nil, nil
+	}
+}
@@ -0,0 +1,59 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBatchReader(t *testing.T) {
+	if !batchReads {
+		t.Skip("Batch reads unsupported, skipping")
+	}
+	for _, addr := range []string{"127.0.0.1", "::1"} {
+		p, err := NewPinger(context.Background(), addr)
+		AssertNoError(t, err)
+		p.SetPrivileged(true)
+		conn, err := icmpProber{}.Listen(p)
+		if err != nil {
+			t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+		}
+		r := newBatchReader(conn.(ipConn), 512)
+		before := time.Now()
+		for seq := 0; seq < 3; seq++ {
+			msg, dst, err := icmpProber{}.Marshal(p, seq)
+			AssertNoError(t, err)
+			_, err = conn.WriteTo(msg, dst)
+			AssertNoError(t, err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+
+		replies := 0
+		for replies < 3 {
+			pkt, rAddr, err := r.read()
+			AssertNoError(t, err)
+			if ip := addrIP(rAddr); !ip.Equal(net.ParseIP(addr)) {
+				t.Errorf("Expected %v, got %v", addr, ip)
+			}
+			p.received = pkt.received
+			reply, err := icmpProber{}.Parse(p, pkt.bytes[:pkt.nbytes])
+			pkt.free()
+			if err != nil || reply == nil {
+				// Our own echo request, on ::1
+				continue
+			}
+			if reply.Seq != replies {
+				t.Errorf("Expected %v, got %v", replies, reply.Seq)
+			}
+			if pkt.received.Before(before) || pkt.received.After(time.Now()) {
+				t.Errorf("Expected a kernel timestamp, got %v", pkt.received)
+			}
+			if reply.Rtt <= 0 || pkt.ttl == 0 {
+				t.Errorf("Expected a round-trip time and TTL, got %v and %v", reply.Rtt, pkt.ttl)
+			}
+			replies++
+		}
+		conn.Close()
+	}
+}
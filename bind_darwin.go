@@ -0,0 +1,20 @@
+package ping
+
+import (
+	"net"
+	"syscall"
+)
+
+// IP_BOUND_IF and IPV6_BOUND_IF, missing from package syscall
+const (
+	ipBoundIf   = 25
+	ipv6BoundIf = 125
+)
+
+// bindToInterface binds socket fd to the interface by index.
+func bindToInterface(fd uintptr, ifi *net.Interface, ipv4 bool) error {
+	if ipv4 {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, ipBoundIf, ifi.Index)
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, ipv6BoundIf, ifi.Index)
+}
@@ -0,0 +1,11 @@
+package ping
+
+import (
+	"net"
+	"syscall"
+)
+
+// bindToInterface binds socket fd to the interface with SO_BINDTODEVICE.
+func bindToInterface(fd uintptr, ifi *net.Interface, ipv4 bool) error {
+	return syscall.BindToDevice(int(fd), ifi.Name)
+}
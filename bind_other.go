@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package ping
+
+import "net"
+
+func bindToInterface(fd uintptr, ifi *net.Interface, ipv4 bool) error {
+	return ErrUnsupportedPlatform
+}
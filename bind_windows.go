@@ -0,0 +1,23 @@
+package ping
+
+import (
+	"math/bits"
+	"net"
+	"syscall"
+)
+
+// IP_UNICAST_IF and IPV6_UNICAST_IF, missing from package syscall
+const (
+	ipUnicastIf   = 31
+	ipv6UnicastIf = 31
+)
+
+// bindToInterface makes socket fd send through the interface. The IPv4
+// option takes the index in network byte order.
+func bindToInterface(fd uintptr, ifi *net.Interface, ipv4 bool) error {
+	if ipv4 {
+		return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, ipUnicastIf,
+			int(bits.ReverseBytes32(uint32(ifi.Index))))
+	}
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IPV6, ipv6UnicastIf, ifi.Index)
+}
@@ -0,0 +1,111 @@
+package ping
+
+import (
+	"net"
+	"time"
+)
+
+// Clock is a source of time and timers. Run times the echo requests, their
+// replies and timeouts on the clock of the pinger, the system clock unless
+// SetClock replaces it, as tests do with a fake clock like the one of
+// package pingtest.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a timer of a Clock, like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// Ticker is a ticker of a Clock, like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// SystemClock is the Clock of the system.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// SetClock sets the clock Run times the pinger on. Nil restores the system
+// clock. Only ICMP echo requests and TWAMP-Light test packets are timed on
+// it, and the deadlines of sockets remain on the system clock.
+func (p *Pinger) SetClock(clock Clock) {
+	p.clock = clock
+}
+
+// getClock returns the clock of the pinger.
+func (p *Pinger) getClock() Clock {
+	if p.clock == nil {
+		return SystemClock
+	}
+	return p.clock
+}
+
+// now returns the current time on the clock of the pinger.
+func (p *Pinger) now() time.Time {
+	return p.getClock().Now()
+}
+
+// receiveTime returns the time the packet being processed was received at,
+// as timestamped by the kernel, or else the current time on the clock of the
+// pinger.
+func (p *Pinger) receiveTime() time.Time {
+	if p.clock == nil && !p.received.IsZero() {
+		return p.received
+	}
+	return p.now()
+}
+
+// Transport opens the sockets a pinger sends and receives its ICMP echo
+// requests on, in place of the ICMP sockets of the system, for instance to
+// test it without network access. The sockets exchange ICMP messages without
+// IP header, addressed like those of an unprivileged ICMP socket, with
+// *net.UDPAddr, or in privileged mode like those of a raw socket, with
+// *net.IPAddr.
+type Transport interface {
+	ListenPacket(p *Pinger) (net.PacketConn, error)
+}
+
+// SetTransport sets the transport of the ICMP echo requests. Nil restores
+// the sockets of the system. The options of the sockets, like SetTTL, don't
+// apply to a transport. Its sockets can be opened by Run in a pingminimal
+// build too, without Listen.
+func (p *Pinger) SetTransport(transport Transport) {
+	p.transport = transport
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"time"
 
 	"github.com/sparrc/go-ping"
@@ -11,7 +14,11 @@ import (
 var usage = `
 Usage:
 
-    ping [-c count] [-i interval] [-t timeout] [--privileged] host
+    ping [-c count] [-i interval] [-t timeout] [-m ttl] [-Q tos] [-S source]
+         [-I interface] [-f] [-A] [--floor interval] [--rate pps] [-R]
+         [-T tsonly|tsandaddr] [-g sweepminsize] [-G sweepmaxsize]
+         [-h sweepincrsize] [--proto protocol] [--port port] [--privileged]
+         host
 
 Examples:
 
@@ -27,6 +34,33 @@ Examples:
     # ping google for 10 seconds
     ping -t 10s www.google.com
 
+    # ping google with a TTL of 5
+    ping -m 5 www.google.com
+
+    # ping google marked with the EF class
+    ping -Q 0xb8 www.google.com
+
+    # ping google through the eth1 interface
+    ping -I eth1 www.google.com
+
+    # ping google 50 times per second
+    ping --rate 50 www.google.com
+
+    # flood ping google, sending as fast as it answers
+    ping -f -c 1000 www.google.com
+
+    # ping google as it answers, with at least 100ms between requests
+    ping -A --floor 100ms www.google.com
+
+    # ping google with sizes from 100 to 1500 bytes, by 100
+    ping -g 100 -G 1500 -h 100 www.google.com
+
+    # record the route to google
+    sudo ping -R --privileged www.google.com
+
+    # ping google's web server over TCP, where ICMP is filtered
+    ping --proto tcp --port 443 www.google.com
+
     # Send a privileged raw ICMP ping
     sudo ping --privileged www.google.com
 `
@@ -35,9 +69,24 @@ func main() {
 	timeout := flag.Duration("t", time.Second*100000, "")
 	interval := flag.Duration("i", time.Second, "")
 	count := flag.Int("c", -1, "")
+	ttl := flag.Int("m", 0, "")
+	tos := flag.Uint("Q", 0, "")
+	source := flag.String("S", "", "")
+	iface := flag.String("I", "", "")
+	proto := flag.String("proto", "icmp", "")
+	port := flag.Int("port", 0, "")
+	flood := flag.Bool("f", false, "")
+	adaptive := flag.Bool("A", false, "")
+	floor := flag.Duration("floor", 0, "")
+	rate := flag.Float64("rate", 0, "")
+	recordRoute := flag.Bool("R", false, "")
+	timestamp := flag.String("T", "", "")
+	sweepMin := flag.Int("g", 8, "")
+	sweepMax := flag.Int("G", 0, "")
+	sweepStep := flag.Int("h", 0, "")
 	privileged := flag.Bool("privileged", false, "")
 	flag.Usage = func() {
-		fmt.Printf(usage)
+		fmt.Print(usage)
 	}
 	flag.Parse()
 
@@ -46,30 +95,154 @@ func main() {
 		return
 	}
 
+	// Stop on ^C, still printing the statistics
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	host := flag.Arg(0)
-	pinger, err := ping.NewPinger(host)
+	pinger, err := ping.NewPinger(ctx, host)
 	if err != nil {
 		fmt.Printf("ERROR: %s\n", err.Error())
 		return
 	}
 
 	pinger.OnRecv = func(pkt *ping.Packet) {
-		fmt.Printf("%d bytes from %s: icmp_seq=%d time=%v\n",
-			pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Rtt)
+		fmt.Printf("%d bytes from %s: icmp_seq=%d ttl=%d time=%v\n",
+			pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Ttl, pkt.Rtt)
+		printOptions(pkt.Options)
+	}
+	pinger.OnDuplicate = func(pkt *ping.Packet) {
+		fmt.Printf("%d bytes from %s: icmp_seq=%d ttl=%d time=%v (DUP!)\n",
+			pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Ttl, pkt.Rtt)
+	}
+	if *flood {
+		// A dot per echo request, erased by its reply
+		pinger.OnSend = func(*ping.Packet) {
+			fmt.Print(".")
+		}
+		pinger.OnRecv = func(*ping.Packet) {
+			fmt.Print("\b \b")
+		}
+		pinger.OnDuplicate = nil
+	}
+	pinger.OnRecvError = func(e *ping.ICMPError) {
+		fmt.Printf("From %s icmp_seq=%d %s\n", e.RAddr, e.Seq, e.Reason())
 	}
 	pinger.OnFinish = func(stats *ping.Statistics) {
 		fmt.Printf("\n--- %s ping statistics ---\n", stats.Addr)
 		fmt.Printf("%d packets transmitted, %d packets received, %v%% packet loss\n",
 			stats.PacketsSent, stats.PacketsRecv, stats.PacketLoss)
+		if stats.PacketsRecvErrors > 0 {
+			fmt.Printf("%d errors received\n", stats.PacketsRecvErrors)
+		}
+		if stats.PacketsRecvCorrupted > 0 {
+			fmt.Printf("%d corrupted replies received\n", stats.PacketsRecvCorrupted)
+		}
 		fmt.Printf("round-trip min/avg/max/stddev = %v/%v/%v/%v\n",
 			stats.MinRtt, stats.AvgRtt, stats.MaxRtt, stats.StdDevRtt)
+		if *adaptive || *flood || *rate > 0 {
+			fmt.Printf("%.1f packets transmitted per second\n", stats.SendRate)
+		}
+		for _, size := range stats.Sizes {
+			fmt.Printf("%d bytes: %d packets transmitted, %d received, %v%% packet loss, min/avg/max = %v/%v/%v\n",
+				size.Size, size.PacketsSent, size.PacketsRecv, size.PacketLoss,
+				size.MinRtt, size.AvgRtt, size.MaxRtt)
+		}
 	}
 
 	pinger.Count = *count
 	pinger.Interval = *interval
 	pinger.Timeout = *timeout
 	pinger.SetPrivileged(*privileged)
+	pinger.SetTTL(*ttl)
+	if *tos > 0xff {
+		fmt.Printf("ERROR: Invalid TOS %d\n", *tos)
+		return
+	}
+	pinger.SetTOS(byte(*tos))
+	if err := pinger.SetSource(*source); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+	if err := pinger.SetInterface(*iface); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+	if err := pinger.SetProtocol(*proto); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+	pinger.SetPort(*port)
+	pinger.SetFlood(*flood)
+	pinger.SetAdaptive(*adaptive)
+	if err := pinger.SetAdaptiveFloor(*floor); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+	option := ping.IPOptionNone
+	switch {
+	case *recordRoute:
+		option = ping.IPOptionRecordRoute
+	case *timestamp == "tsonly":
+		option = ping.IPOptionTimestamp
+	case *timestamp == "tsandaddr":
+		option = ping.IPOptionTimestampAddr
+	case *timestamp != "":
+		fmt.Printf("ERROR: Invalid timestamp type %q\n", *timestamp)
+		return
+	}
+	if err := pinger.SetIPOption(option); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+	if *sweepMax > 0 {
+		step := *sweepStep
+		if step == 0 {
+			step = 1
+		}
+		if err := pinger.SetSizeSweep(*sweepMin, *sweepMax, step); err != nil {
+			fmt.Printf("ERROR: %s\n", err.Error())
+			return
+		}
+	}
+	if err := pinger.SetRate(*rate); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return
+	}
 
 	fmt.Printf("PING %s (%s):\n", pinger.Addr(), pinger.IPAddr())
-	pinger.Run()
+	if err := pinger.Run(ctx); err != nil && ctx.Err() == nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+	}
+}
+
+// printOptions prints the route and timestamps recorded in a reply.
+func printOptions(opts *ping.IPOptions) {
+	if opts == nil {
+		return
+	}
+	if opts.Timestamps == nil {
+		for i, ip := range opts.Route {
+			if i == 0 {
+				fmt.Printf("RR:\t%s\n", ip)
+			} else {
+				fmt.Printf("\t%s\n", ip)
+			}
+		}
+		return
+	}
+	for i, ts := range opts.Timestamps {
+		prefix := "\t"
+		if i == 0 {
+			prefix = "TS:\t"
+		}
+		if i < len(opts.Route) {
+			fmt.Printf("%s%s\t%d absolute\n", prefix, opts.Route[i], ts)
+		} else {
+			fmt.Printf("%s%d absolute\n", prefix, ts)
+		}
+	}
+	if opts.Overflow > 0 {
+		fmt.Printf("Unrecorded hops: %d\n", opts.Overflow)
+	}
 }
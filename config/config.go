@@ -0,0 +1,226 @@
+// Package config loads ping targets and their options from YAML, JSON or
+// TOML files, so that a set of pingers can be driven declaratively.
+//
+// A configuration has default options and a list of targets, each of which
+// can override any option:
+//
+//	defaults:
+//	  interval: 1s
+//	  privileged: true
+//	targets:
+//	  - host: www.google.com
+//	  - name: router
+//	    host: 192.168.1.1
+//	    interval: 200ms
+//	    windows: ["Mon-Fri 08:00-18:00"]
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sparrc/go-ping"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a set of ping targets.
+type Config struct {
+	// Defaults are the options of targets that don't set them.
+	Defaults Options `json:"defaults" yaml:"defaults" toml:"defaults"`
+
+	// Targets are the hosts to ping.
+	Targets []Target `json:"targets" yaml:"targets" toml:"targets"`
+}
+
+// Target is a host to ping and its options.
+type Target struct {
+	// Name identifies the target. Default is Host.
+	Name string `json:"name" yaml:"name" toml:"name"`
+
+	// Host is the DNS name or IP address to ping.
+	Host string `json:"host" yaml:"host" toml:"host"`
+
+	Options `yaml:",inline"`
+}
+
+// Options are the Pinger options that can be configured. Unset options keep
+// the Pinger defaults.
+type Options struct {
+	Interval        *Duration `json:"interval" yaml:"interval" toml:"interval"`
+	Timeout         *Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+	Count           *int      `json:"count" yaml:"count" toml:"count"`
+	Privileged      *bool     `json:"privileged" yaml:"privileged" toml:"privileged"`
+	Isochronous     *bool     `json:"isochronous" yaml:"isochronous" toml:"isochronous"`
+	SummaryInterval *Duration `json:"summary_interval" yaml:"summary_interval" toml:"summary_interval"`
+	DownAfter       *int      `json:"down_after" yaml:"down_after" toml:"down_after"`
+
+	// Windows are time ranges in the format of ping.ParseTimeRange.
+	Windows []string `json:"windows" yaml:"windows" toml:"windows"`
+}
+
+// Duration is a time.Duration written as a string such as "1s" or "200ms".
+type Duration time.Duration
+
+// UnmarshalText parses a duration in the format of time.ParseDuration.
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration as time.Duration.String does.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load reads the configuration file at path. Its format is chosen from the
+// file extension: ".yaml", ".yml", ".json" or ".toml".
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+	return Parse(data, format)
+}
+
+// Parse parses a configuration in the given format, one of "yaml", "yml",
+// "json" or "toml".
+func Parse(data []byte, format string) (*Config, error) {
+	c := &Config{}
+	var err error
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, c)
+	case "json":
+		err = json.Unmarshal(data, c)
+	case "toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return nil, fmt.Errorf("Unsupported config format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, c.validate()
+}
+
+func (c *Config) validate() error {
+	if err := c.Defaults.validate(); err != nil {
+		return fmt.Errorf("Defaults: %s", err)
+	}
+	names := make(map[string]bool)
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.Host == "" {
+			return fmt.Errorf("Target %d has no host", i)
+		}
+		if t.Name == "" {
+			t.Name = t.Host
+		}
+		if names[t.Name] {
+			return fmt.Errorf("Duplicate target %s", t.Name)
+		}
+		if err := t.Options.validate(); err != nil {
+			return fmt.Errorf("Target %s: %s", t.Name, err)
+		}
+		names[t.Name] = true
+	}
+	return nil
+}
+
+func (o *Options) validate() error {
+	if o.Interval != nil && *o.Interval <= 0 {
+		return fmt.Errorf("Invalid interval %s, must be positive",
+			time.Duration(*o.Interval))
+	}
+	if o.Timeout != nil && *o.Timeout <= 0 {
+		return fmt.Errorf("Invalid timeout %s, must be positive",
+			time.Duration(*o.Timeout))
+	}
+	if o.SummaryInterval != nil && *o.SummaryInterval < 0 {
+		return fmt.Errorf("Invalid summary_interval %s, must not be negative",
+			time.Duration(*o.SummaryInterval))
+	}
+	return nil
+}
+
+// MultiPinger returns a MultiPinger with a Pinger for every target.
+func (c *Config) MultiPinger(ctx context.Context) (*ping.MultiPinger, error) {
+	m := ping.NewMultiPinger()
+	for _, t := range c.Targets {
+		p, err := c.NewPinger(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Add(t.Name, p); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// NewPinger returns a Pinger for t, with the options of t applied over the
+// defaults of c.
+func (c *Config) NewPinger(ctx context.Context, t Target) (*ping.Pinger, error) {
+	p, err := ping.NewPinger(ctx, t.Host)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Defaults.Apply(p); err != nil {
+		return nil, err
+	}
+	if err := t.Options.Apply(p); err != nil {
+		return nil, fmt.Errorf("Target %s: %s", t.Name, err)
+	}
+	return p, nil
+}
+
+// Apply sets the options that are set in o on p. Interval and Timeout must
+// be positive, and SummaryInterval not negative.
+func (o *Options) Apply(p *ping.Pinger) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	if o.Interval != nil {
+		p.Interval = time.Duration(time.Duration(*o.Interval))
+	}
+	if o.Timeout != nil {
+		p.Timeout = time.Duration(time.Duration(*o.Timeout))
+	}
+	if o.Count != nil {
+		p.Count = *o.Count
+	}
+	if o.Privileged != nil {
+		p.SetPrivileged(*o.Privileged)
+	}
+	if o.Isochronous != nil {
+		p.Isochronous = *o.Isochronous
+	}
+	if o.SummaryInterval != nil {
+		p.SummaryInterval = time.Duration(*o.SummaryInterval)
+	}
+	if o.DownAfter != nil {
+		p.DownAfter = *o.DownAfter
+	}
+	if o.Windows != nil {
+		p.Windows = nil
+		for _, s := range o.Windows {
+			w, err := ping.ParseTimeRange(s)
+			if err != nil {
+				return err
+			}
+			p.Windows = append(p.Windows, w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sparrc/go-ping"
+)
+
+var configs = map[string]string{
+	"yaml": `
+defaults:
+  interval: 500ms
+  privileged: true
+targets:
+  - host: 127.0.0.1
+  - name: router
+    host: 127.0.0.2
+    interval: 200ms
+    count: 5
+    windows: ["Mon-Fri 08:00-18:00"]
+`,
+	"json": `{
+  "defaults": {"interval": "500ms", "privileged": true},
+  "targets": [
+    {"host": "127.0.0.1"},
+    {"name": "router", "host": "127.0.0.2", "interval": "200ms", "count": 5,
+     "windows": ["Mon-Fri 08:00-18:00"]}
+  ]
+}`,
+	"toml": `
+[defaults]
+interval = "500ms"
+privileged = true
+
+[[targets]]
+host = "127.0.0.1"
+
+[[targets]]
+name = "router"
+host = "127.0.0.2"
+interval = "200ms"
+count = 5
+windows = ["Mon-Fri 08:00-18:00"]
+`,
+}
+
+func TestParse(t *testing.T) {
+	ctx := context.Background()
+	for format, data := range configs {
+		c, err := Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		m, err := c.MultiPinger(ctx)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		names := m.Names()
+		if len(names) != 2 || names[0] != "127.0.0.1" || names[1] != "router" {
+			t.Errorf("%s: Expected [127.0.0.1 router], got %v", format, names)
+		}
+
+		p := m.Pinger("127.0.0.1")
+		if p.Interval != 500*time.Millisecond || !p.Privileged() || p.Count != -1 {
+			t.Errorf("%s: Expected defaults to apply, got %v %v %v", format,
+				p.Interval, p.Privileged(), p.Count)
+		}
+
+		p = m.Pinger("router")
+		if p.Interval != 200*time.Millisecond || !p.Privileged() || p.Count != 5 {
+			t.Errorf("%s: Expected overrides to apply, got %v %v %v", format,
+				p.Interval, p.Privileged(), p.Count)
+		}
+		if len(p.Windows) != 1 {
+			t.Errorf("%s: Expected %v, got %v", format, 1, len(p.Windows))
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing host":  `{"targets": [{"name": "foo"}]}`,
+		"duplicate":     `{"targets": [{"host": "127.0.0.1"}, {"host": "127.0.0.1"}]}`,
+		"bad duration":  `{"defaults": {"interval": "soon"}}`,
+		"zero interval": `{"defaults": {"interval": "0s"}}`,
+		"zero timeout":  `{"targets": [{"host": "127.0.0.1", "timeout": "0s"}]}`,
+		"negative summary": `{"targets": [{"host": "127.0.0.1",
+			"summary_interval": "-1s"}]}`,
+		"unknown format": ``,
+	}
+	for name, data := range tests {
+		format := "json"
+		if name == "unknown format" {
+			format = "ini"
+		}
+		if _, err := Parse([]byte(data), format); err == nil {
+			t.Errorf("%s: Expected Error but got nil", name)
+		}
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	p, err := ping.NewPinger(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	interval := Duration(-time.Second)
+	o := Options{Interval: &interval}
+	err = o.Apply(p)
+	if err == nil || !strings.Contains(err.Error(), "interval") {
+		t.Errorf("Expected an interval error, got %v", err)
+	}
+	if p.Interval != time.Second {
+		t.Errorf("Expected %v, got %v", time.Second, p.Interval)
+	}
+}
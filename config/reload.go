@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sparrc/go-ping"
+)
+
+// Reloader runs the targets of a configuration file in a MultiPinger, and
+// applies changes of the file without restarting it. Added targets are
+// started, removed targets are stopped, and targets whose options changed are
+// restarted with their statistics carried over. Other targets are left
+// untouched.
+type Reloader struct {
+	// OnReload is called after each reload with the names of the targets
+	// that were added, removed and changed, or with the error that made the
+	// reload fail. A failed reload leaves the running targets as they were.
+	OnReload func(added, removed, changed []string, err error)
+
+	path    string
+	ctx     context.Context
+	m       *ping.MultiPinger
+	targets map[string]string
+	modTime time.Time
+}
+
+// NewReloader loads the configuration file at path and returns a Reloader
+// for it. The pingers are created with ctx.
+func NewReloader(ctx context.Context, path string) (*Reloader, error) {
+	r := &Reloader{
+		path:    path,
+		ctx:     ctx,
+		m:       ping.NewMultiPinger(),
+		targets: make(map[string]string),
+	}
+	if _, _, _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// MultiPinger returns the MultiPinger running the targets. Callers are
+// expected to Run it.
+func (r *Reloader) MultiPinger() *ping.MultiPinger {
+	return r.m
+}
+
+// Reload reloads the configuration file and applies the changes.
+func (r *Reloader) Reload() error {
+	added, removed, changed, err := r.reload()
+	if handler := r.OnReload; handler != nil {
+		handler(added, removed, changed, err)
+	}
+	return err
+}
+
+func (r *Reloader) reload() (added, removed, changed []string, err error) {
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	c, err := Load(r.path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Create all the new pingers first, so that an invalid target leaves the
+	// running ones untouched.
+	targets := make(map[string]string, len(c.Targets))
+	pingers := make(map[string]*ping.Pinger)
+	for _, t := range c.Targets {
+		key := c.key(t)
+		targets[t.Name] = key
+		if r.targets[t.Name] == key {
+			continue
+		}
+		p, err := c.NewPinger(r.ctx, t)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pingers[t.Name] = p
+	}
+
+	for name := range r.targets {
+		if _, ok := targets[name]; !ok {
+			r.m.Remove(name)
+			removed = append(removed, name)
+		}
+	}
+	for _, t := range c.Targets {
+		p, ok := pingers[t.Name]
+		if !ok {
+			continue
+		}
+		// Replaced in place, for the MultiPinger to keep running
+		if old := r.m.Replace(t.Name, p, carryOver); old != nil {
+			changed = append(changed, t.Name)
+		} else {
+			added = append(added, t.Name)
+		}
+	}
+	r.targets = targets
+	return added, removed, changed, nil
+}
+
+// key returns a string identifying the host and effective options of t, to
+// tell whether it changed between two reloads.
+func (c *Config) key(t Target) string {
+	o := c.Defaults.merge(t.Options)
+	b, _ := json.Marshal(struct {
+		Host    string
+		Options Options
+	}{t.Host, o})
+	return string(b)
+}
+
+// merge returns o with the options set in override replaced.
+func (o Options) merge(override Options) Options {
+	if override.Interval != nil {
+		o.Interval = override.Interval
+	}
+	if override.Timeout != nil {
+		o.Timeout = override.Timeout
+	}
+	if override.Count != nil {
+		o.Count = override.Count
+	}
+	if override.Privileged != nil {
+		o.Privileged = override.Privileged
+	}
+	if override.Isochronous != nil {
+		o.Isochronous = override.Isochronous
+	}
+	if override.SummaryInterval != nil {
+		o.SummaryInterval = override.SummaryInterval
+	}
+	if override.DownAfter != nil {
+		o.DownAfter = override.DownAfter
+	}
+	if override.Windows != nil {
+		o.Windows = override.Windows
+	}
+	return o
+}
+
+// carryOver restores the statistics of old into p, if there is an old
+// pinger and they ping the same address.
+func carryOver(old, p *ping.Pinger) {
+	if old == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := old.Save(&buf); err != nil {
+		return
+	}
+	p.Load(&buf)
+}
+
+// Watch reloads the configuration when the process receives SIGHUP, or when
+// the modification time of the file changes, which is checked every poll
+// interval. A zero poll interval only reloads on SIGHUP. On platforms without
+// SIGHUP, the file is only polled. This is a blocking function that will exit
+// when ctx is done.
+func (r *Reloader) Watch(ctx context.Context, poll time.Duration) {
+	hup := make(chan os.Signal, 1)
+	if len(reloadSignals) > 0 {
+		signal.Notify(hup, reloadSignals...)
+		defer signal.Stop(hup)
+	}
+
+	var tick <-chan time.Time
+	if poll > 0 {
+		t := time.NewTicker(poll)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload()
+		case <-tick:
+			info, err := os.Stat(r.path)
+			if err == nil && !info.ModTime().Equal(r.modTime) {
+				r.Reload()
+			}
+		}
+	}
+}
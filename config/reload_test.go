@@ -0,0 +1,152 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sparrc/go-ping"
+)
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+targets:
+  - host: 127.0.0.1
+  - host: 127.0.0.2
+  - host: 127.0.0.3
+`)
+
+	r, err := NewReloader(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := r.MultiPinger()
+	if names := m.Names(); len(names) != 3 {
+		t.Fatalf("Expected 3 targets, got %v", names)
+	}
+	unchanged := m.Pinger("127.0.0.1")
+	m.Pinger("127.0.0.2").PacketsSent = 7
+
+	var added, removed, changed []string
+	r.OnReload = func(a, r, c []string, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		added, removed, changed = a, r, c
+	}
+	write(`
+targets:
+  - host: 127.0.0.1
+  - host: 127.0.0.2
+    interval: 200ms
+  - host: 127.0.0.4
+`)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(added, []string{"127.0.0.4"}) ||
+		!reflect.DeepEqual(removed, []string{"127.0.0.3"}) ||
+		!reflect.DeepEqual(changed, []string{"127.0.0.2"}) {
+		t.Errorf("Expected [127.0.0.4] [127.0.0.3] [127.0.0.2], got %v %v %v",
+			added, removed, changed)
+	}
+	if m.Pinger("127.0.0.1") != unchanged {
+		t.Errorf("Expected 127.0.0.1 to be left untouched")
+	}
+	p := m.Pinger("127.0.0.2")
+	if p.Interval != 200*time.Millisecond || p.PacketsSent != 7 {
+		t.Errorf("Expected 200ms and 7 packets sent, got %v and %v",
+			p.Interval, p.PacketsSent)
+	}
+
+	// An invalid file leaves the targets as they were
+	write(`targets: [{name: broken}]`)
+	r.OnReload = nil
+	if err := r.Reload(); err == nil {
+		t.Errorf("Expected Error but got nil")
+	}
+	if names := m.Names(); len(names) != 3 {
+		t.Errorf("Expected 3 targets, got %v", names)
+	}
+}
+
+func TestReloadRunning(t *testing.T) {
+	probe, err := ping.NewPinger(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe.SetPrivileged(true)
+	probe.Count = 1
+	probe.Timeout = time.Second
+	if err := probe.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+defaults:
+  privileged: true
+targets:
+  - host: 127.0.0.1
+    interval: 20ms
+`)
+	r, err := NewReloader(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := r.MultiPinger()
+	done := make(chan error)
+	go func() {
+		done <- m.Run(context.Background())
+	}()
+	running := func(p *ping.Pinger) {
+		for deadline := time.Now().Add(2 * time.Second); !p.Running(); {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %s to run", p.Addr())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	running(m.Pinger("127.0.0.1"))
+
+	write(`
+defaults:
+  privileged: true
+targets:
+  - host: 127.0.0.1
+    interval: 30ms
+`)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	p := m.Pinger("127.0.0.1")
+	if p.Interval != 30*time.Millisecond {
+		t.Errorf("Expected %v, got %v", 30*time.Millisecond, p.Interval)
+	}
+	running(p)
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the MultiPinger to keep running, got %v", err)
+	default:
+	}
+
+	m.Stop()
+	if err := <-done; err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+}
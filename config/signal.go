@@ -0,0 +1,10 @@
+//go:build !js && !plan9 && !wasip1
+
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+var reloadSignals = []os.Signal{syscall.SIGHUP}
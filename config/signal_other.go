@@ -0,0 +1,8 @@
+//go:build js || plan9 || wasip1
+
+package config
+
+import "os"
+
+// No SIGHUP, Watch only polls the file
+var reloadSignals []os.Signal
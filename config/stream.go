@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sparrc/go-ping"
+)
+
+// ParseTarget parses a target line: a host followed by optional key=value
+// options, such as
+//
+//	192.168.1.1 name=router interval=200ms count=10 window="Mon-Fri 08:00-18:00"
+//
+// The keys are the option names of the configuration files, and window may
+// be repeated. Values containing spaces must be double-quoted.
+func ParseTarget(line string) (*Target, error) {
+	fields, err := splitFields(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Empty target line")
+	}
+
+	t := &Target{Host: fields[0]}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid option %q, expected key=value", field)
+		}
+		if err := t.set(kv[0], kv[1]); err != nil {
+			return nil, err
+		}
+	}
+	if t.Name == "" {
+		t.Name = t.Host
+	}
+	return t, nil
+}
+
+func (t *Target) set(key, value string) error {
+	var err error
+	switch key {
+	case "name":
+		t.Name = value
+	case "interval":
+		t.Interval, err = parseDuration(value)
+	case "timeout":
+		t.Timeout, err = parseDuration(value)
+	case "summary_interval":
+		t.SummaryInterval, err = parseDuration(value)
+	case "count":
+		t.Count, err = parseInt(value)
+	case "down_after":
+		t.DownAfter, err = parseInt(value)
+	case "privileged":
+		t.Privileged, err = parseBool(value)
+	case "isochronous":
+		t.Isochronous, err = parseBool(value)
+	case "window":
+		t.Windows = append(t.Windows, value)
+	default:
+		return fmt.Errorf("Unknown option %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid %s %q: %s", key, value, err)
+	}
+	return nil
+}
+
+func parseDuration(s string) (*Duration, error) {
+	d := new(Duration)
+	if err := d.UnmarshalText([]byte(s)); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func parseInt(s string) (*int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func parseBool(s string) (*bool, error) {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// splitFields splits line on white space, keeping double-quoted strings
+// together.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField, quoted := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case unicode.IsSpace(r) && !quoted:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("Unterminated quote in %q", line)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// Stream reads target lines from r, such as a file or stdin, and adds them to
+// m as they arrive, with the defaults of c. Empty lines and lines starting
+// with '#' are skipped. Lines that fail to parse, resolve or be added are
+// reported to onError, if not nil, and skipped. m may be running already,
+// even without targets, for them to be started as they arrive.
+//
+// Stream returns at the end of r, or when ctx is done and the next line is
+// read.
+func (c *Config) Stream(ctx context.Context, r io.Reader, m *ping.MultiPinger,
+	onError func(line int, err error)) error {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		err := c.addTarget(ctx, line, m)
+		if err != nil && onError != nil {
+			onError(n, err)
+		}
+	}
+	return s.Err()
+}
+
+func (c *Config) addTarget(ctx context.Context, line string, m *ping.MultiPinger) error {
+	t, err := ParseTarget(line)
+	if err != nil {
+		return err
+	}
+	p, err := c.NewPinger(ctx, *t)
+	if err != nil {
+		return err
+	}
+	return m.Add(t.Name, p)
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sparrc/go-ping"
+)
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget(`192.168.1.1 name=router interval=200ms count=10 ` +
+		`privileged=true window="Mon-Fri 08:00-18:00" window="Sat 10:00-12:00"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Host != "192.168.1.1" || target.Name != "router" {
+		t.Errorf("Expected router 192.168.1.1, got %s %s", target.Name, target.Host)
+	}
+	if time.Duration(*target.Interval) != 200*time.Millisecond ||
+		*target.Count != 10 || !*target.Privileged {
+		t.Errorf("Expected 200ms, 10 and true, got %v, %v and %v",
+			time.Duration(*target.Interval), *target.Count, *target.Privileged)
+	}
+	if len(target.Windows) != 2 || target.Windows[0] != "Mon-Fri 08:00-18:00" {
+		t.Errorf("Expected 2 windows, got %q", target.Windows)
+	}
+
+	target, err = ParseTarget("  10.0.0.1  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Name != "10.0.0.1" || target.Interval != nil {
+		t.Errorf("Expected defaults for 10.0.0.1, got %+v", target)
+	}
+
+	for _, invalid := range []string{"", "10.0.0.1 interval", "10.0.0.1 foo=bar",
+		"10.0.0.1 count=many", `10.0.0.1 window="Mon`} {
+		if _, err := ParseTarget(invalid); err == nil {
+			t.Errorf("Expected Error for %q but got nil", invalid)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	input := `# discovered hosts
+127.0.0.1
+127.0.0.2 name=two count=3
+
+127.0.0.3 bogus=1
+127.0.0.1
+`
+	c := &Config{}
+	m := ping.NewMultiPinger()
+	var errLines []int
+	err := c.Stream(context.Background(), strings.NewReader(input), m,
+		func(line int, err error) {
+			errLines = append(errLines, line)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := m.Names()
+	if len(names) != 2 || names[0] != "127.0.0.1" || names[1] != "two" {
+		t.Errorf("Expected [127.0.0.1 two], got %v", names)
+	}
+	if m.Pinger("two").Count != 3 {
+		t.Errorf("Expected %v, got %v", 3, m.Pinger("two").Count)
+	}
+	if len(errLines) != 2 || errLines[0] != 5 || errLines[1] != 6 {
+		t.Errorf("Expected errors on lines [5 6], got %v", errLines)
+	}
+}
+
+func TestStreamEmpty(t *testing.T) {
+	probe, err := ping.NewPinger(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe.SetPrivileged(true)
+	probe.Count = 1
+	probe.Timeout = time.Second
+	if err := probe.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+
+	// Running before the first target arrives
+	m := ping.NewMultiPinger()
+	done := make(chan error)
+	go func() {
+		done <- m.Run(context.Background())
+	}()
+	r, w := io.Pipe()
+	streamed := make(chan error)
+	go func() {
+		streamed <- (&Config{}).Stream(context.Background(), r, m, nil)
+	}()
+
+	if _, err := io.WriteString(w, "127.0.0.1 privileged=true interval=20ms\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if err := <-streamed; err != nil {
+		t.Fatal(err)
+	}
+	p := m.Pinger("127.0.0.1")
+	for deadline := time.Now().Add(2 * time.Second); p.Statistics().PacketsRecv == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the streamed target to be pinged")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the MultiPinger to keep running, got %v", err)
+	default:
+	}
+
+	m.Stop()
+	if err := <-done; err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package ping
+
+import "net"
+
+// listenDgram is only supported where unprivileged ICMP sockets exist.
+func listenDgram(ipv4 bool, address string, control func(fd uintptr) error) (net.PacketConn, error) {
+	return nil, ErrUnsupportedPlatform
+}
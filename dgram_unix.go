@@ -0,0 +1,75 @@
+//go:build linux || darwin
+
+package ping
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// ipStripHdr is IP_STRIPHDR of darwin, needed for datagram ICMP sockets to
+// receive the messages without their IPv4 header.
+const ipStripHdr = 0x17
+
+// listenDgram opens an unprivileged datagram ICMP socket bound to address,
+// as icmp.ListenPacket does, calling control on it before it is bound.
+func listenDgram(ipv4 bool, address string, control func(fd uintptr) error) (net.PacketConn, error) {
+	family, proto := syscall.AF_INET6, protocolIPv6ICMP
+	if ipv4 {
+		family, proto = syscall.AF_INET, protocolICMP
+	}
+	var sa syscall.Sockaddr
+	var ip netip.Addr
+	if address != "" {
+		var err error
+		if ip, err = netip.ParseAddr(address); err != nil {
+			return nil, fmt.Errorf("Invalid source address %q", address)
+		}
+	}
+	if ipv4 {
+		sa4 := &syscall.SockaddrInet4{}
+		if ip.IsValid() {
+			sa4.Addr = ip.Unmap().As4()
+		}
+		sa = sa4
+	} else {
+		sa6 := &syscall.SockaddrInet6{}
+		if ip.IsValid() {
+			sa6.Addr = ip.As16()
+			if zone := ip.Zone(); zone != "" {
+				ifi, err := net.InterfaceByName(zone)
+				if err != nil {
+					return nil, err
+				}
+				sa6.ZoneId = uint32(ifi.Index)
+			}
+		}
+		sa = sa6
+	}
+
+	s, err := syscall.Socket(family, syscall.SOCK_DGRAM, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if runtime.GOOS == "darwin" && ipv4 {
+		if err := syscall.SetsockoptInt(s, syscall.IPPROTO_IP, ipStripHdr, 1); err != nil {
+			syscall.Close(s)
+			return nil, os.NewSyscallError("setsockopt", err)
+		}
+	}
+	if err := control(uintptr(s)); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	if err := syscall.Bind(s, sa); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	f := os.NewFile(uintptr(s), "datagram-oriented icmp")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
@@ -0,0 +1,44 @@
+package discover
+
+import (
+	"context"
+	"net"
+
+	"github.com/sparrc/go-ping"
+)
+
+// Gateway is a default gateway of the system.
+type Gateway struct {
+	// IP is the address of the gateway.
+	IP net.IP
+
+	// Interface is the name of the interface the gateway is reached through.
+	Interface string
+}
+
+// Addr returns the address of the gateway in a form accepted by
+// ping.NewPinger, with the interface as zone for IPv6 link-local addresses.
+func (g Gateway) Addr() string {
+	if g.IP.To4() == nil && g.IP.IsLinkLocalUnicast() && g.Interface != "" {
+		return g.IP.String() + "%" + g.Interface
+	}
+	return g.IP.String()
+}
+
+// GatewayPingers returns a Pinger for each default gateway of the system, as
+// returned by Gateways. The pingers are created with ctx.
+func GatewayPingers(ctx context.Context) ([]*ping.Pinger, error) {
+	gateways, err := Gateways()
+	if err != nil {
+		return nil, err
+	}
+	var pingers []*ping.Pinger
+	for _, g := range gateways {
+		p, err := ping.NewPinger(ctx, g.Addr())
+		if err != nil {
+			return nil, err
+		}
+		pingers = append(pingers, p)
+	}
+	return pingers, nil
+}
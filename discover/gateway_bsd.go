@@ -0,0 +1,63 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package discover
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/net/route"
+)
+
+// Gateways returns the default gateways of the system from its routing
+// table, IPv4 ones first.
+func Gateways() ([]Gateway, error) {
+	var gateways []Gateway
+	for _, af := range []int{syscall.AF_INET, syscall.AF_INET6} {
+		rib, err := route.FetchRIB(af, route.RIBTypeRoute, 0)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := route.ParseRIB(route.RIBTypeRoute, rib)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			rm, ok := m.(*route.RouteMessage)
+			if !ok || rm.Flags&syscall.RTF_GATEWAY == 0 ||
+				len(rm.Addrs) <= syscall.RTAX_NETMASK {
+				continue
+			}
+			if !isDefault(rm.Addrs[syscall.RTAX_DST]) {
+				continue
+			}
+
+			var ip net.IP
+			switch a := rm.Addrs[syscall.RTAX_GATEWAY].(type) {
+			case *route.Inet4Addr:
+				ip = net.IP(a.IP[:])
+			case *route.Inet6Addr:
+				ip = net.IP(a.IP[:])
+			default:
+				continue
+			}
+			var name string
+			if ifi, err := net.InterfaceByIndex(rm.Index); err == nil {
+				name = ifi.Name
+			}
+			gateways = append(gateways, Gateway{IP: ip, Interface: name})
+		}
+	}
+	return gateways, nil
+}
+
+// isDefault reports whether the destination a is the default route.
+func isDefault(a route.Addr) bool {
+	switch a := a.(type) {
+	case *route.Inet4Addr:
+		return a.IP == [4]byte{}
+	case *route.Inet6Addr:
+		return a.IP == [16]byte{}
+	}
+	return false
+}
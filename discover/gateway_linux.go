@@ -0,0 +1,96 @@
+package discover
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const rtfGateway = 0x2
+
+// Gateways returns the default gateways of the system from its IPv4 and IPv6
+// route tables, IPv4 ones first.
+func Gateways() ([]Gateway, error) {
+	var gateways []Gateway
+	for _, t := range []struct {
+		path  string
+		parse func(io.Reader) ([]Gateway, error)
+	}{
+		{"/proc/net/route", parseRoute},
+		{"/proc/net/ipv6_route", parseIPv6Route},
+	} {
+		f, err := os.Open(t.path)
+		if os.IsNotExist(err) {
+			// IPv6 disabled
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		g, err := t.parse(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		gateways = append(gateways, g...)
+	}
+	return gateways, nil
+}
+
+// parseRoute parses the IPv4 route table in the format of /proc/net/route.
+func parseRoute(r io.Reader) ([]Gateway, error) {
+	var gateways []Gateway
+	s := bufio.NewScanner(r)
+	s.Scan() // header
+	for s.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(s.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		// The address is in host byte order
+		ip := make(net.IP, net.IPv4len)
+		binary.NativeEndian.PutUint32(ip, uint32(gw))
+		gateways = append(gateways, Gateway{IP: ip, Interface: fields[0]})
+	}
+	return gateways, s.Err()
+}
+
+// parseIPv6Route parses the IPv6 route table in the format of
+// /proc/net/ipv6_route.
+func parseIPv6Route(r io.Reader) ([]Gateway, error) {
+	var gateways []Gateway
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// Destination PrefixLen Source SourcePrefixLen NextHop Metric RefCnt
+		// Use Flags Iface
+		fields := strings.Fields(s.Text())
+		if len(fields) < 10 || fields[1] != "00" ||
+			fields[0] != strings.Repeat("0", 32) {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		ip, err := hex.DecodeString(fields[4])
+		if err != nil || len(ip) != net.IPv6len {
+			continue
+		}
+		gateways = append(gateways, Gateway{IP: net.IP(ip), Interface: fields[9]})
+	}
+	return gateways, s.Err()
+}
@@ -0,0 +1,55 @@
+package discover
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	// Addresses are in host byte order, 0101A8C0 on little endian hosts
+	gw := binary.NativeEndian.Uint32([]byte{192, 168, 1, 1})
+	dst := binary.NativeEndian.Uint32([]byte{192, 168, 1, 0})
+	mask := binary.NativeEndian.Uint32([]byte{255, 255, 255, 0})
+	table := fmt.Sprintf(`Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	%08X	0003	0	0	100	00000000	0	0	0
+eth0	%08X	00000000	0001	0	0	100	%08X	0	0	0
+`, gw, dst, mask)
+	gateways, err := parseRoute(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gateways) != 1 {
+		t.Fatalf("Expected 1 gateway, got %d", len(gateways))
+	}
+	if got := gateways[0].IP.String(); got != "192.168.1.1" {
+		t.Errorf("Expected %s, got %s", "192.168.1.1", got)
+	}
+	if got := gateways[0].Interface; got != "eth0" {
+		t.Errorf("Expected %s, got %s", "eth0", got)
+	}
+	if got := gateways[0].Addr(); got != "192.168.1.1" {
+		t.Errorf("Expected %s, got %s", "192.168.1.1", got)
+	}
+}
+
+func TestParseIPv6Route(t *testing.T) {
+	table := "" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 wlan0\n" +
+		"fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001 wlan0\n" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200 lo\n"
+	gateways, err := parseIPv6Route(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gateways) != 1 {
+		t.Fatalf("Expected 1 gateway, got %d", len(gateways))
+	}
+	if got := gateways[0].IP.String(); got != "fe80::1" {
+		t.Errorf("Expected %s, got %s", "fe80::1", got)
+	}
+	if got := gateways[0].Addr(); got != "fe80::1%wlan0" {
+		t.Errorf("Expected %s, got %s", "fe80::1%wlan0", got)
+	}
+}
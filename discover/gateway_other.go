@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package discover
+
+import "github.com/sparrc/go-ping"
+
+// Gateways returns the default gateways of the system. It is not supported on
+// this platform, and returns ping.ErrUnsupportedPlatform.
+func Gateways() ([]Gateway, error) {
+	return nil, ping.ErrUnsupportedPlatform
+}
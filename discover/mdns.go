@@ -0,0 +1,235 @@
+// Package discover finds hosts worth pinging: hosts announcing themselves on
+// the local network and default gateways.
+package discover
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sparrc/go-ping"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ServicesQuery is the DNS-SD meta query enumerating the service types
+// announced on the local network.
+const ServicesQuery = "_services._dns-sd._udp.local."
+
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Host is a host found on the local network.
+type Host struct {
+	// Name is the host name, without the trailing dot.
+	Name string
+
+	// IP is the address of the host.
+	IP net.IP
+}
+
+// MDNS finds hosts on the local network by sending mDNS queries for the
+// addresses of the instances of the given DNS-SD service types, such as
+// "_http._tcp.local.", and collecting the addresses in the answers. Without
+// service types, all the announced types are enumerated with ServicesQuery
+// and queried. MDNS listens for answers until ctx is done, so ctx should have
+// a deadline.
+//
+// Only IPv4 is queried, and IPv6 addresses in the answers are ignored.
+func MDNS(ctx context.Context, services ...string) ([]Host, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// Interrupts the reads once ctx is done, and exits with MDNS
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	d := newDiscovery()
+	if len(services) == 0 {
+		services = []string{ServicesQuery}
+	}
+	for _, s := range services {
+		d.queried[s] = true
+		if err := query(conn, s, dnsmessage.TypePTR); err != nil {
+			return nil, err
+		}
+	}
+
+	b := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		for _, q := range d.parse(b[:n]) {
+			query(conn, q.name, q.typ)
+		}
+	}
+	return d.hosts(), nil
+}
+
+// MonitorLAN finds hosts on the local network with MDNS for the given
+// duration, and adds a Pinger for each of them to m, named after the host.
+// configure, if not nil, is called on each Pinger before it is added. The
+// pingers are created with ctx. m may be running already, even without
+// pingers, for them to be started as they are added. It returns the hosts
+// found. It can be called again to monitor the hosts found since: the
+// pingers of known hosts are kept, or replaced if their address changed.
+func MonitorLAN(ctx context.Context, duration time.Duration, m *ping.MultiPinger,
+	configure func(*ping.Pinger)) ([]Host, error) {
+	dctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	hosts, err := MDNS(dctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := addHosts(ctx, m, hosts, configure); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// addHosts adds a Pinger for each of hosts to m, as MonitorLAN does.
+func addHosts(ctx context.Context, m *ping.MultiPinger, hosts []Host,
+	configure func(*ping.Pinger)) error {
+	named := make(map[string]bool)
+	for _, h := range hosts {
+		// The other addresses of a host are named after them too
+		name := h.Name
+		if named[name] {
+			name += " " + h.IP.String()
+		}
+		named[h.Name] = true
+		if old := m.Pinger(name); old != nil && old.IPAddr().IP.Equal(h.IP) {
+			continue
+		}
+
+		p, err := ping.NewPinger(ctx, h.IP.String())
+		if err != nil {
+			return err
+		}
+		if configure != nil {
+			configure(p)
+		}
+		m.Replace(name, p, nil)
+	}
+	return nil
+}
+
+func query(conn *net.UDPConn, name string, typ dnsmessage.Type) error {
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return err
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{
+			Name:  n,
+			Type:  typ,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteTo(b, mdnsAddr)
+	return err
+}
+
+type question struct {
+	name string
+	typ  dnsmessage.Type
+}
+
+// discovery accumulates the records of mDNS answers.
+type discovery struct {
+	queried map[string]bool
+	addrs   map[string][]net.IP
+	order   []string
+}
+
+func newDiscovery() *discovery {
+	return &discovery{
+		queried: make(map[string]bool),
+		addrs:   make(map[string][]net.IP),
+	}
+}
+
+// parse records the addresses found in the mDNS message b, and returns the
+// follow-up queries to send for the names it points to.
+func (d *discovery) parse(b []byte) []question {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(b); err != nil || !msg.Header.Response {
+		return nil
+	}
+
+	var next []question
+	follow := func(name string, typ dnsmessage.Type) {
+		if !d.queried[name] {
+			d.queried[name] = true
+			next = append(next, question{name, typ})
+		}
+	}
+
+	records := append(append(msg.Answers, msg.Authorities...), msg.Additionals...)
+	for _, r := range records {
+		name := r.Header.Name.String()
+		switch body := r.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name == ServicesQuery {
+				// Service type, query its instances
+				follow(body.PTR.String(), dnsmessage.TypePTR)
+			} else {
+				// Service instance, query its host
+				follow(body.PTR.String(), dnsmessage.TypeSRV)
+			}
+		case *dnsmessage.SRVResource:
+			follow(body.Target.String(), dnsmessage.TypeA)
+		case *dnsmessage.AResource:
+			d.add(name, net.IP(body.A[:]))
+		}
+	}
+
+	// Names with addresses need no more queries
+	var filtered []question
+	for _, q := range next {
+		if _, ok := d.addrs[q.name]; !ok {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered
+}
+
+func (d *discovery) add(name string, ip net.IP) {
+	d.queried[name] = true
+	for _, known := range d.addrs[name] {
+		if known.Equal(ip) {
+			return
+		}
+	}
+	if _, ok := d.addrs[name]; !ok {
+		d.order = append(d.order, name)
+	}
+	d.addrs[name] = append(d.addrs[name], ip)
+}
+
+func (d *discovery) hosts() []Host {
+	var hosts []Host
+	for _, name := range d.order {
+		for _, ip := range d.addrs[name] {
+			hosts = append(hosts, Host{Name: strings.TrimSuffix(name, "."), IP: ip})
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,154 @@
+package discover
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sparrc/go-ping"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func mustName(s string) dnsmessage.Name {
+	return dnsmessage.MustNewName(s)
+}
+
+func pack(t *testing.T, answers ...dnsmessage.Resource) []byte {
+	msg := dnsmessage.Message{
+		Header:  dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: answers,
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func header(name string, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: mustName(name), Type: typ,
+		Class: dnsmessage.ClassINET}
+}
+
+func TestDiscoveryParse(t *testing.T) {
+	d := newDiscovery()
+	d.queried[ServicesQuery] = true
+
+	next := d.parse(pack(t, dnsmessage.Resource{
+		Header: header(ServicesQuery, dnsmessage.TypePTR),
+		Body:   &dnsmessage.PTRResource{PTR: mustName("_ipp._tcp.local.")},
+	}))
+	if len(next) != 1 || next[0].name != "_ipp._tcp.local." ||
+		next[0].typ != dnsmessage.TypePTR {
+		t.Fatalf("Expected a PTR query for _ipp._tcp.local., got %v", next)
+	}
+
+	next = d.parse(pack(t, dnsmessage.Resource{
+		Header: header("_ipp._tcp.local.", dnsmessage.TypePTR),
+		Body:   &dnsmessage.PTRResource{PTR: mustName("printer._ipp._tcp.local.")},
+	}))
+	if len(next) != 1 || next[0].typ != dnsmessage.TypeSRV {
+		t.Fatalf("Expected a SRV query for the instance, got %v", next)
+	}
+
+	// SRV with the address in the same answer needs no more queries
+	next = d.parse(pack(t, dnsmessage.Resource{
+		Header: header("printer._ipp._tcp.local.", dnsmessage.TypeSRV),
+		Body:   &dnsmessage.SRVResource{Target: mustName("printer.local."), Port: 631},
+	}, dnsmessage.Resource{
+		Header: header("printer.local.", dnsmessage.TypeA),
+		Body:   &dnsmessage.AResource{A: [4]byte{192, 168, 1, 20}},
+	}))
+	if len(next) != 0 {
+		t.Errorf("Expected no queries, got %v", next)
+	}
+
+	// Duplicate announcements are ignored
+	d.parse(pack(t, dnsmessage.Resource{
+		Header: header("printer.local.", dnsmessage.TypeA),
+		Body:   &dnsmessage.AResource{A: [4]byte{192, 168, 1, 20}},
+	}))
+
+	// So are IPv6 addresses
+	d.parse(pack(t, dnsmessage.Resource{
+		Header: header("printer.local.", dnsmessage.TypeAAAA),
+		Body:   &dnsmessage.AAAAResource{AAAA: [16]byte{0xfe, 0x80, 15: 1}},
+	}))
+
+	hosts := d.hosts()
+	if len(hosts) != 1 || hosts[0].Name != "printer.local" ||
+		hosts[0].IP.String() != "192.168.1.20" {
+		t.Errorf("Expected printer.local 192.168.1.20, got %v", hosts)
+	}
+}
+
+func TestAddHosts(t *testing.T) {
+	m := ping.NewMultiPinger()
+	ctx := context.Background()
+	hosts := []Host{
+		{Name: "printer.local", IP: net.IPv4(192, 168, 1, 20)},
+		{Name: "printer.local", IP: net.IPv4(192, 168, 1, 21)},
+		{Name: "nas.local", IP: net.IPv4(192, 168, 1, 30)},
+	}
+	if err := addHosts(ctx, m, hosts, nil); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	printer := m.Pinger("printer.local")
+
+	// Found again, the NAS with a new address
+	hosts[2].IP = net.IPv4(192, 168, 1, 31)
+	if err := addHosts(ctx, m, hosts, nil); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	names := m.Names()
+	if len(names) != 3 || names[0] != "nas.local" || names[1] != "printer.local" ||
+		names[2] != "printer.local 192.168.1.21" {
+		t.Errorf("Expected 3 pingers, got %v", names)
+	}
+	if m.Pinger("printer.local") != printer {
+		t.Errorf("Expected the pinger of printer.local to be kept")
+	}
+	if ip := m.Pinger("nas.local").IPAddr().IP; !ip.Equal(hosts[2].IP) {
+		t.Errorf("Expected %v, got %v", hosts[2].IP, ip)
+	}
+}
+
+func TestMDNSExit(t *testing.T) {
+	before := runtime.NumGoroutine()
+	// An invalid service name fails before ctx is done
+	if _, err := MDNS(context.Background(), strings.Repeat("a", 300)); err == nil {
+		t.Fatalf("Expected Error but got nil")
+	}
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %v goroutines, got %v", before, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMonitorLANRunning(t *testing.T) {
+	m := ping.NewMultiPinger()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	if _, err := MonitorLAN(ctx, 100*time.Millisecond, m, nil); err != nil {
+		cancel()
+		<-done
+		t.Skipf("Can't send mDNS queries, skipping: %s", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the MultiPinger to keep running, got %v", err)
+	default:
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
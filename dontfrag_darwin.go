@@ -0,0 +1,17 @@
+package ping
+
+import "syscall"
+
+// IP_DONTFRAG and IPV6_DONTFRAG, missing from package syscall
+const (
+	ipDontFrag   = 0x1c
+	ipv6DontFrag = 0x3e
+)
+
+// setDontFragment sets the don't fragment flag on socket fd.
+func setDontFragment(fd uintptr, ipv4 bool) error {
+	if ipv4 {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, ipDontFrag, 1)
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, ipv6DontFrag, 1)
+}
@@ -0,0 +1,11 @@
+package ping
+
+import "syscall"
+
+// setDontFragment sets the don't fragment flag on socket fd.
+func setDontFragment(fd uintptr, ipv4 bool) error {
+	if ipv4 {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_DONTFRAG, 1)
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_DONTFRAG, 1)
+}
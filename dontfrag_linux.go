@@ -0,0 +1,21 @@
+package ping
+
+import "syscall"
+
+// ipv6DontFrag is IPV6_DONTFRAG, missing from package syscall.
+const ipv6DontFrag = 0x3e
+
+// setDontFragment sets the don't fragment flag on socket fd. The path MTU
+// cached by the kernel is ignored, so that larger sizes can still be
+// probed.
+func setDontFragment(fd uintptr, ipv4 bool) error {
+	if ipv4 {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP,
+			syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+	}
+	if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6,
+		syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE); err != nil {
+		return err
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, ipv6DontFrag, 1)
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package ping
+
+func setDontFragment(fd uintptr, ipv4 bool) error {
+	return ErrUnsupportedPlatform
+}
@@ -0,0 +1,17 @@
+package ping
+
+import "syscall"
+
+// IP_DONTFRAGMENT and IPV6_DONTFRAG, missing from package syscall
+const (
+	ipDontFragment = 14
+	ipv6DontFrag   = 14
+)
+
+// setDontFragment sets the don't fragment flag on socket fd.
+func setDontFragment(fd uintptr, ipv4 bool) error {
+	if ipv4 {
+		return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, ipDontFragment, 1)
+	}
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IPV6, ipv6DontFrag, 1)
+}
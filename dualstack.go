@@ -0,0 +1,134 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultFallbackDelay is the delay before the IPv4 address of a dual-stack
+// pinger is tried, as in the Happy Eyeballs algorithm of RFC 8305.
+const DefaultFallbackDelay = 300 * time.Millisecond
+
+// dualStack is the addresses of both families of a dual-stack pinger.
+type dualStack struct {
+	ipv6, ipv4 *net.IPAddr
+	delay      time.Duration
+}
+
+// SetDualStack resolves both the IPv6 and IPv4 addresses of the target host
+// and makes Run choose between them like Happy Eyeballs: echo requests are
+// sent to the IPv6 address first, then also to the IPv4 one after
+// fallbackDelay, or at once if the IPv6 socket can't be opened, and the
+// first family to answer is the one pinged. If neither answers within the
+// ProbeTimeout after the fallback, the IPv6 address is pinged. OnFamily is
+// called with the chosen address. Zero or less uses DefaultFallbackDelay.
+// It has no effect on a pinger after Listen or on the pingers of a
+// PingerPool, whose socket is of a single family.
+func (p *Pinger) SetDualStack(fallbackDelay time.Duration) error {
+	addrs, err := p.lookup(p.ctx, p.addr)
+	if err != nil {
+		return err
+	}
+	ds := &dualStack{delay: fallbackDelay}
+	if ds.delay <= 0 {
+		ds.delay = DefaultFallbackDelay
+	}
+	for i := range addrs {
+		switch {
+		case isIPv4(addrs[i].IP):
+			if ds.ipv4 == nil {
+				ds.ipv4 = &addrs[i]
+			}
+		case ds.ipv6 == nil:
+			ds.ipv6 = &addrs[i]
+		}
+	}
+	if ds.ipv4 == nil || ds.ipv6 == nil {
+		return fmt.Errorf("%s doesn't have both IPv4 and IPv6 addresses", p.addr)
+	}
+	p.dualStack = ds
+	p.setFamily(ds.ipv6)
+	return nil
+}
+
+// setFamily sets the address of the target host to the one of its family,
+// keeping its name.
+func (p *Pinger) setFamily(ipaddr *net.IPAddr) {
+	addr := p.addr
+	p.SetIPAddr(ipaddr)
+	p.addr = addr
+	if p.source != "" && p.SetSource(p.source) != nil {
+		// Of the other family
+		p.source = ""
+	}
+}
+
+// chooseFamily sends echo requests to both addresses of a dual-stack pinger,
+// and returns the first one to answer. It returns nil if the pinger was
+// stopped, with the error of the context if it is done.
+func (p *Pinger) chooseFamily(ctx context.Context) (*net.IPAddr, error) {
+	ds := p.dualStack
+	timeout := p.ProbeTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	race, cancel := context.WithTimeout(ctx, ds.delay+timeout)
+	defer cancel()
+
+	// answered is nil if a family didn't answer
+	answered := make(chan *net.IPAddr, 2)
+	start := func(ipaddr *net.IPAddr) {
+		q := p.clone()
+		q.dualStack = nil
+		q.setFamily(ipaddr)
+		q.Count = -1
+		q.OnRecv = func(*Packet) {
+			q.Stop()
+		}
+		q.OnError = func(error) {}
+		go func() {
+			q.Run(race)
+			if q.PacketsRecv > 0 {
+				answered <- ipaddr
+			} else {
+				answered <- nil
+			}
+		}()
+	}
+
+	start(ds.ipv6)
+	fallback := time.NewTimer(ds.delay)
+	defer fallback.Stop()
+	started, pending := 1, 1
+	for {
+		select {
+		case <-p.done:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.ctx.Done():
+			return nil, p.ctx.Err()
+		case <-fallback.C:
+			if started == 1 {
+				start(ds.ipv4)
+				started++
+				pending++
+			}
+		case ipaddr := <-answered:
+			pending--
+			if ipaddr != nil {
+				return ipaddr, nil
+			}
+			if started == 1 {
+				// The IPv6 socket failed, fall back at once
+				start(ds.ipv4)
+				started++
+				pending++
+			} else if pending == 0 {
+				return ds.ipv6, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetDualStack(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertError(t, p.SetDualStack(0), "IPv4 address only")
+	if p.dualStack != nil {
+		t.Errorf("Expected no dual-stack mode")
+	}
+}
+
+func TestDualStack(t *testing.T) {
+	for _, tt := range []struct {
+		ipv6, expected string
+	}{
+		{"::1", "::1"},
+		// Discard prefix, never answered
+		{"100::1", "127.0.0.1"},
+	} {
+		p, err := NewPinger(context.Background(), "localhost")
+		AssertNoError(t, err)
+		p.SetPrivileged(true)
+		p.dualStack = &dualStack{
+			ipv6:  &net.IPAddr{IP: net.ParseIP(tt.ipv6)},
+			ipv4:  &net.IPAddr{IP: net.ParseIP("127.0.0.1")},
+			delay: 50 * time.Millisecond,
+		}
+		p.Count = 1
+		p.Timeout = time.Second
+		var family *net.IPAddr
+		p.OnFamily = func(ipaddr *net.IPAddr) {
+			family = ipaddr
+		}
+		if err := p.Run(context.Background()); err != nil {
+			t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+		}
+		if family == nil || family.String() != tt.expected {
+			t.Errorf("Expected %v, got %v", tt.expected, family)
+		}
+		AssertEqualStrings(t, tt.expected, p.IPAddr().String())
+		AssertEqualStrings(t, "localhost", p.Addr())
+		if p.PacketsRecv != 1 {
+			t.Errorf("Expected %v, got %v", 1, p.PacketsRecv)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package ping
+
+import "net"
+
+// useEchoAPI is only set on Windows, which has no datagram ICMP sockets.
+const useEchoAPI = false
+
+func listenEchoAPI(p *Pinger) (net.PacketConn, error) {
+	return nil, ErrUnsupportedPlatform
+}
@@ -0,0 +1,306 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// useEchoAPI makes unprivileged pingers send their echo requests with the
+// ICMP API of Windows, which has no datagram ICMP sockets. Privileged
+// pingers use raw sockets, which need administrator rights.
+const useEchoAPI = true
+
+var (
+	iphlpapi = syscall.NewLazyDLL("iphlpapi.dll")
+
+	procIcmpCreateFile  = iphlpapi.NewProc("IcmpCreateFile")
+	procIcmp6CreateFile = iphlpapi.NewProc("Icmp6CreateFile")
+	procIcmpCloseHandle = iphlpapi.NewProc("IcmpCloseHandle")
+	procIcmpSendEcho2Ex = iphlpapi.NewProc("IcmpSendEcho2Ex")
+	procIcmp6SendEcho2  = iphlpapi.NewProc("Icmp6SendEcho2")
+)
+
+// IP_STATUS of the replies, and the don't fragment flag of the requests
+const (
+	ipSuccess             = 0
+	ipDestNetUnreachable  = 11002
+	ipDestHostUnreachable = 11003
+	ipDestProtUnreachable = 11004
+	ipDestPortUnreachable = 11005
+	ipPacketTooBig        = 11009
+	ipTTLExpiredTransit   = 11013
+	ipTTLExpiredReassem   = 11014
+	ipParamProblem        = 11015
+
+	ipFlagDF = 0x2
+)
+
+// ipOptionInformation is IP_OPTION_INFORMATION.
+type ipOptionInformation struct {
+	TTL         uint8
+	Tos         uint8
+	Flags       uint8
+	OptionsSize uint8
+	OptionsData uintptr
+}
+
+// icmpEchoReply is ICMP_ECHO_REPLY, with the data of the reply pointing in
+// the reply buffer.
+type icmpEchoReply struct {
+	Address       [4]byte
+	Status        uint32
+	RoundTripTime uint32
+	DataSize      uint16
+	Reserved      uint16
+	Data          uintptr
+	Options       ipOptionInformation
+}
+
+// sockaddrIn6 is SOCKADDR_IN6.
+type sockaddrIn6 struct {
+	Family   uint16
+	Port     uint16
+	Flowinfo uint32
+	Addr     [16]byte
+	ScopeID  uint32
+}
+
+// icmpv6EchoReplyLen is the size of ICMPV6_ECHO_REPLY, its source address
+// and status followed by the data of the reply.
+const icmpv6EchoReplyLen = 36
+
+// listenEchoAPI returns a socket sending the echo requests written to it
+// with the ICMP API, and returning the replies as ICMP messages.
+func listenEchoAPI(p *Pinger) (net.PacketConn, error) {
+	create, network := procIcmp6CreateFile, "ip6:ipv6-icmp"
+	if p.ipv4 {
+		create, network = procIcmpCreateFile, "ip4:icmp"
+	}
+	if err := create.Find(); err != nil {
+		return nil, err
+	}
+	h, _, err := create.Call()
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return nil, err
+	}
+
+	e := &echoAPI{
+		handle:  h,
+		ipv4:    p.ipv4,
+		timeout: uint32(p.probeTimeout() / time.Millisecond),
+	}
+	if p.ttl > 0 || p.tos > 0 || p.dontFragment {
+		// Windows defaults to a TTL of 128
+		e.options = &ipOptionInformation{TTL: 128, Tos: p.tos}
+		if p.ttl > 0 {
+			e.options.TTL = uint8(p.ttl)
+		}
+		if p.dontFragment {
+			e.options.Flags = ipFlagDF
+		}
+	}
+	if p.source != "" {
+		e.source = net.ParseIP(p.source)
+	}
+	conn := newDialConn(network, e.send)
+	conn.release = func() {
+		procIcmpCloseHandle.Call(h)
+	}
+	return conn, nil
+}
+
+// echoAPI sends echo requests with the ICMP API.
+type echoAPI struct {
+	handle  uintptr
+	ipv4    bool
+	timeout uint32
+	options *ipOptionInformation
+	source  net.IP
+}
+
+// send sends echo request b to dst and returns the reply, or the ICMP error
+// message about it, as a message received on an ICMP socket.
+func (e *echoAPI) send(ctx context.Context, dst net.Addr, b []byte) *dialAnswer {
+	proto := protocolIPv6ICMP
+	if e.ipv4 {
+		proto = protocolICMP
+	}
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return nil
+	}
+	echo, ok := m.Body.(*icmp.Echo)
+	if !ok {
+		return nil
+	}
+	var ip net.IP
+	var zone string
+	switch a := dst.(type) {
+	case *net.UDPAddr:
+		ip, zone = a.IP, a.Zone
+	case *net.IPAddr:
+		ip, zone = a.IP, a.Zone
+	}
+
+	// Room for the reply, its data and an IO_STATUS_BLOCK
+	buf := make([]byte, unsafe.Sizeof(icmpEchoReply{})+uintptr(len(echo.Data))+8+64)
+	var status uint32
+	var from net.IP
+	var ttl int
+	var data []byte
+	if e.ipv4 {
+		status, from, ttl, data = e.send4(ip, echo.Data, buf)
+	} else {
+		status, from, data = e.send6(ip, zone, echo.Data, buf)
+	}
+	if from == nil {
+		from = ip
+	}
+
+	reply := &icmp.Message{Body: &icmp.Echo{ID: echo.ID, Seq: echo.Seq, Data: data}}
+	if e.ipv4 {
+		reply.Type = ipv4.ICMPTypeEchoReply
+	} else {
+		reply.Type = ipv6.ICMPTypeEchoReply
+	}
+	if status != ipSuccess {
+		reply = e.errorMessage(status, ip, b)
+		if reply == nil {
+			// Timed out, or failed
+			return nil
+		}
+	}
+	answer, err := reply.Marshal(nil)
+	if err != nil {
+		return nil
+	}
+	return &dialAnswer{b: answer, addr: &net.IPAddr{IP: from}, ttl: ttl}
+}
+
+func (e *echoAPI) send4(ip net.IP, data, buf []byte) (status uint32, from net.IP, ttl int, reply []byte) {
+	var src, dst uint32
+	if ip4 := e.source.To4(); ip4 != nil {
+		src = binary.LittleEndian.Uint32(ip4)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		dst = binary.LittleEndian.Uint32(ip4)
+	}
+	n, _, err := procIcmpSendEcho2Ex.Call(e.handle, 0, 0, 0, uintptr(src), uintptr(dst),
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)),
+		uintptr(unsafe.Pointer(e.options)),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), uintptr(e.timeout))
+	if n == 0 {
+		return errnoStatus(err), nil, 0, nil
+	}
+	r := (*icmpEchoReply)(unsafe.Pointer(&buf[0]))
+	from = net.IPv4(r.Address[0], r.Address[1], r.Address[2], r.Address[3])
+	// The data of the reply is in buf
+	off := int(r.Data - uintptr(unsafe.Pointer(&buf[0])))
+	if r.Status == ipSuccess && off >= 0 && off+int(r.DataSize) <= len(buf) {
+		reply = append([]byte(nil), buf[off:off+int(r.DataSize)]...)
+	}
+	return r.Status, from, int(r.Options.TTL), reply
+}
+
+func (e *echoAPI) send6(ip net.IP, zone string, data, buf []byte) (status uint32, from net.IP, reply []byte) {
+	src := sockaddrIn6{Family: syscall.AF_INET6}
+	copy(src.Addr[:], e.source.To16())
+	dst := sockaddrIn6{Family: syscall.AF_INET6}
+	copy(dst.Addr[:], ip.To16())
+	if ifi, err := net.InterfaceByName(zone); zone != "" && err == nil {
+		dst.ScopeID = uint32(ifi.Index)
+	}
+	n, _, err := procIcmp6SendEcho2.Call(e.handle, 0, 0, 0,
+		uintptr(unsafe.Pointer(&src)), uintptr(unsafe.Pointer(&dst)),
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)),
+		uintptr(unsafe.Pointer(e.options)),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), uintptr(e.timeout))
+	if n == 0 {
+		return errnoStatus(err), nil, nil
+	}
+	// IPV6_ADDRESS_EX: port, flow info, address and scope, packed
+	from = append(net.IP(nil), buf[6:22]...)
+	status = binary.LittleEndian.Uint32(buf[28:32])
+	if status == ipSuccess && icmpv6EchoReplyLen+len(data) <= len(buf) {
+		reply = append([]byte(nil), buf[icmpv6EchoReplyLen:icmpv6EchoReplyLen+len(data)]...)
+	}
+	return status, from, reply
+}
+
+// errnoStatus returns the IP_STATUS of a failed request.
+func errnoStatus(err error) uint32 {
+	if errno, ok := err.(syscall.Errno); ok && errno != 0 {
+		return uint32(errno)
+	}
+	return ^uint32(0)
+}
+
+// errorMessage returns the ICMP error message reported by status about
+// echo request b sent to ip, quoting it, or nil if status is not that of an
+// ICMP error.
+func (e *echoAPI) errorMessage(status uint32, ip net.IP, b []byte) *icmp.Message {
+	var quoted []byte
+	if e.ipv4 {
+		quoted = make([]byte, ipv4.HeaderLen, ipv4.HeaderLen+icmpHeaderLen)
+		quoted[0] = 0x45
+		copy(quoted[16:20], ip.To4())
+	} else {
+		quoted = make([]byte, ipv6.HeaderLen, ipv6.HeaderLen+icmpHeaderLen)
+		quoted[0] = 0x60
+		copy(quoted[24:40], ip.To16())
+	}
+	quoted = append(quoted, b[:icmpHeaderLen]...)
+
+	if e.ipv4 {
+		unreach := map[uint32]int{
+			ipDestNetUnreachable:  0,
+			ipDestHostUnreachable: 1,
+			ipDestProtUnreachable: 2,
+			ipDestPortUnreachable: 3,
+			ipPacketTooBig:        4,
+		}
+		if code, ok := unreach[status]; ok {
+			return &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: code,
+				Body: &icmp.DstUnreach{Data: quoted}}
+		}
+		switch status {
+		case ipTTLExpiredTransit, ipTTLExpiredReassem:
+			return &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded,
+				Code: int(status - ipTTLExpiredTransit), Body: &icmp.TimeExceeded{Data: quoted}}
+		case ipParamProblem:
+			return &icmp.Message{Type: ipv4.ICMPTypeParameterProblem,
+				Body: &icmp.ParamProb{Data: quoted}}
+		}
+		return nil
+	}
+
+	unreach := map[uint32]int{
+		ipDestNetUnreachable:  0,
+		ipDestHostUnreachable: 3,
+		ipDestPortUnreachable: 4,
+	}
+	if code, ok := unreach[status]; ok {
+		return &icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: code,
+			Body: &icmp.DstUnreach{Data: quoted}}
+	}
+	switch status {
+	case ipPacketTooBig:
+		return &icmp.Message{Type: ipv6.ICMPTypePacketTooBig,
+			Body: &icmp.PacketTooBig{Data: quoted}}
+	case ipTTLExpiredTransit, ipTTLExpiredReassem:
+		return &icmp.Message{Type: ipv6.ICMPTypeTimeExceeded,
+			Code: int(status - ipTTLExpiredTransit), Body: &icmp.TimeExceeded{Data: quoted}}
+	case ipParamProblem:
+		return &icmp.Message{Type: ipv6.ICMPTypeParameterProblem,
+			Body: &icmp.ParamProb{Data: quoted}}
+	}
+	return nil
+}
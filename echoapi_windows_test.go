@@ -0,0 +1,50 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEchoAPIError(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	var errs []*ICMPError
+	p.OnRecvError = func(e *ICMPError) {
+		errs = append(errs, e)
+	}
+
+	p.sequence = 3
+	probe, _, err := p.prober.Marshal(p, p.sequence)
+	AssertNoError(t, err)
+	p.trackSent(p.sequence, time.Now())
+
+	e := &echoAPI{ipv4: true}
+	m := e.errorMessage(ipDestHostUnreachable, p.ipaddr.IP, probe)
+	if m == nil {
+		t.Fatalf("Expected an ICMP error, got %v", m)
+	}
+	b, err := m.Marshal(nil)
+	AssertNoError(t, err)
+	AssertNoError(t, p.processPacket(&packet{bytes: b, nbytes: len(b), rAddr: "127.0.0.1"}))
+	if len(errs) != 1 || errs[0].Seq != 3 {
+		t.Fatalf("Expected an error about request %v, got %v", 3, errs)
+	}
+	AssertEqualStrings(t, "Destination host unreachable", errs[0].Reason())
+
+	// Timeouts are not ICMP errors
+	if m := e.errorMessage(11010, p.ipaddr.IP, probe); m != nil {
+		t.Errorf("Expected %v, got %v", nil, m)
+	}
+}
+
+func TestEchoAPI(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.Count = 1
+	p.Timeout = 5 * time.Second
+	AssertNoError(t, p.Run(context.Background()))
+	if p.PacketsRecv != 1 {
+		t.Errorf("Expected %v, got %v", 1, p.PacketsRecv)
+	}
+}
@@ -0,0 +1,33 @@
+package ping
+
+import "fmt"
+
+// Event IDs written by EventLogSink, on Windows.
+const (
+	EventIDUp    = 1
+	EventIDDown  = 2
+	EventIDError = 3
+)
+
+// Event types of the Windows Event Log
+const (
+	eventlogErrorType       = 0x0001
+	eventlogWarningType     = 0x0002
+	eventlogInformationType = 0x0004
+)
+
+// stateChangeEvent returns the type, ID and message of the event logging a
+// state transition of the host addr.
+func stateChangeEvent(addr string, old, new State) (uint16, uint32, string) {
+	typ, id := uint16(eventlogInformationType), uint32(EventIDUp)
+	if new != StateUp {
+		typ, id = eventlogWarningType, EventIDDown
+	}
+	return typ, id, fmt.Sprintf("host=%s state=%s previous=%s", addr, new, old)
+}
+
+// errorEvent returns the type, ID and message of the event logging an error
+// that occurred while pinging addr.
+func errorEvent(addr string, err error) (uint16, uint32, string) {
+	return eventlogErrorType, EventIDError, fmt.Sprintf("host=%s error=%s", addr, err)
+}
@@ -0,0 +1,33 @@
+package ping
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEventLogEvents(t *testing.T) {
+	typ, id, msg := stateChangeEvent("example.com", StateDown, StateUp)
+	if typ != eventlogInformationType || id != EventIDUp {
+		t.Errorf("Expected %v/%v, got %v/%v", eventlogInformationType, EventIDUp, typ, id)
+	}
+	if want := fmt.Sprintf("host=example.com state=%s previous=%s", StateUp, StateDown); msg != want {
+		t.Errorf("Expected %q, got %q", want, msg)
+	}
+
+	typ, id, msg = stateChangeEvent("example.com", StateUp, StateDown)
+	if typ != eventlogWarningType || id != EventIDDown {
+		t.Errorf("Expected %v/%v, got %v/%v", eventlogWarningType, EventIDDown, typ, id)
+	}
+	if want := fmt.Sprintf("host=example.com state=%s previous=%s", StateDown, StateUp); msg != want {
+		t.Errorf("Expected %q, got %q", want, msg)
+	}
+
+	typ, id, msg = errorEvent("example.com", errors.New("No route to host"))
+	if typ != eventlogErrorType || id != EventIDError {
+		t.Errorf("Expected %v/%v, got %v/%v", eventlogErrorType, EventIDError, typ, id)
+	}
+	if want := "host=example.com error=No route to host"; msg != want {
+		t.Errorf("Expected %q, got %q", want, msg)
+	}
+}
@@ -0,0 +1,91 @@
+package ping
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modadvapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procRegisterEventSourceW  = modadvapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = modadvapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = modadvapi32.NewProc("ReportEventW")
+)
+
+// EventLogSink writes state changes and errors of a Pinger to the Windows
+// Event Log. Up transitions are logged as information events, down
+// transitions as warnings and errors as error events.
+type EventLogSink struct {
+	h syscall.Handle
+}
+
+// NewEventLogSink registers source as an event source on the local machine
+// and returns a sink writing to it. The source should have been installed in
+// the registry beforehand for the messages to render nicely in the Event
+// Viewer.
+func NewEventLogSink(source string) (*EventLogSink, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	h, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, fmt.Errorf("Error registering event source %s: %s", source, err)
+	}
+	return &EventLogSink{h: syscall.Handle(h)}, nil
+}
+
+// Attach hooks the sink into p's OnStateChange and OnError callbacks,
+// keeping any callbacks already set.
+func (s *EventLogSink) Attach(p *Pinger) {
+	onStateChange := p.OnStateChange
+	p.OnStateChange = func(old, new State) {
+		if onStateChange != nil {
+			onStateChange(old, new)
+		}
+		s.StateChange(p.Addr(), old, new)
+	}
+
+	onError := p.OnError
+	p.OnError = func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+		s.Error(p.Addr(), err)
+	}
+}
+
+// StateChange writes a state transition of the host addr to the event log.
+func (s *EventLogSink) StateChange(addr string, old, new State) error {
+	return s.report(stateChangeEvent(addr, old, new))
+}
+
+// Error writes an error that occurred while pinging addr to the event log.
+func (s *EventLogSink) Error(addr string, err error) error {
+	return s.report(errorEvent(addr, err))
+}
+
+func (s *EventLogSink) report(typ uint16, id uint32, msg string) error {
+	str, err := syscall.UTF16PtrFromString(msg)
+	if err != nil {
+		return err
+	}
+	strs := []*uint16{str}
+	r, _, err := procReportEventW.Call(uintptr(s.h), uintptr(typ), 0,
+		uintptr(id), 0, 1, 0, uintptr(unsafe.Pointer(&strs[0])), 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// Close deregisters the event source.
+func (s *EventLogSink) Close() error {
+	r, _, err := procDeregisterEventSource.Call(uintptr(s.h))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package ping
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"expvar"
+	"io"
+	"strconv"
+	"time"
+)
+
+// statisticsJSON is the JSON encoding of Statistics, with stable field
+// names and the durations in milliseconds.
+type statisticsJSON struct {
+	Addr                  string  `json:"addr"`
+	IPAddr                string  `json:"ip_addr,omitempty"`
+	RAddr                 string  `json:"raddr,omitempty"`
+	PacketsSent           int     `json:"packets_sent"`
+	PacketsRecv           int     `json:"packets_recv"`
+	PacketsRecvDuplicates int     `json:"packets_recv_duplicates"`
+	PacketsRecvErrors     int     `json:"packets_recv_errors"`
+	PacketsRecvCorrupted  int     `json:"packets_recv_corrupted"`
+	PacketsLost           int     `json:"packets_lost"`
+	PacketLoss            float64 `json:"packet_loss"`
+
+	MinRtt    float64 `json:"min_rtt_ms"`
+	MaxRtt    float64 `json:"max_rtt_ms"`
+	AvgRtt    float64 `json:"avg_rtt_ms"`
+	StdDevRtt float64 `json:"stddev_rtt_ms"`
+	MedianRtt float64 `json:"median_rtt_ms"`
+	Jitter    float64 `json:"jitter_ms"`
+
+	Percentiles []percentileJSON `json:"percentiles,omitempty"`
+	Sizes       []sizeJSON       `json:"sizes,omitempty"`
+	Histogram   []bucketJSON     `json:"histogram,omitempty"`
+	Rtts        []float64        `json:"rtts_ms,omitempty"`
+
+	RateLimit        float64 `json:"rate_limit,omitempty"`
+	RateLimitedLoss  int     `json:"rate_limited_loss,omitempty"`
+	TimeToFirstReply float64 `json:"time_to_first_reply_ms"`
+	ProbesMissed     int     `json:"probes_missed,omitempty"`
+	AvgSendError     float64 `json:"avg_send_error_ms,omitempty"`
+	MaxSendError     float64 `json:"max_send_error_ms,omitempty"`
+	SendRate         float64 `json:"send_rate,omitempty"`
+}
+
+type percentileJSON struct {
+	Percentile float64 `json:"percentile"`
+	Rtt        float64 `json:"rtt_ms"`
+}
+
+type sizeJSON struct {
+	Size        int     `json:"size"`
+	PacketsSent int     `json:"packets_sent"`
+	PacketsRecv int     `json:"packets_recv"`
+	PacketLoss  float64 `json:"packet_loss"`
+	MinRtt      float64 `json:"min_rtt_ms"`
+	AvgRtt      float64 `json:"avg_rtt_ms"`
+	MaxRtt      float64 `json:"max_rtt_ms"`
+}
+
+type bucketJSON struct {
+	UpperBound float64 `json:"upper_bound_ms"`
+	Count      int     `json:"count"`
+}
+
+// milliseconds returns d in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// MarshalJSON encodes the statistics with stable, snake case field names and
+// the durations in fractional milliseconds, for programs and dashboards
+// consuming them. The per-probe results are left out, see CSVWriter.
+func (s *Statistics) MarshalJSON() ([]byte, error) {
+	out := statisticsJSON{
+		Addr:                  s.Addr,
+		RAddr:                 s.RAddr,
+		PacketsSent:           s.PacketsSent,
+		PacketsRecv:           s.PacketsRecv,
+		PacketsRecvDuplicates: s.PacketsRecvDuplicates,
+		PacketsRecvErrors:     s.PacketsRecvErrors,
+		PacketsRecvCorrupted:  s.PacketsRecvCorrupted,
+		PacketsLost:           s.PacketsLost,
+		PacketLoss:            s.PacketLoss,
+
+		MinRtt:    milliseconds(s.MinRtt),
+		MaxRtt:    milliseconds(s.MaxRtt),
+		AvgRtt:    milliseconds(s.AvgRtt),
+		StdDevRtt: milliseconds(s.StdDevRtt),
+		MedianRtt: milliseconds(s.MedianRtt),
+		Jitter:    milliseconds(s.Jitter),
+
+		RateLimit:        s.RateLimit,
+		RateLimitedLoss:  s.RateLimitedLoss,
+		TimeToFirstReply: milliseconds(s.TimeToFirstReply),
+		ProbesMissed:     s.ProbesMissed,
+		AvgSendError:     milliseconds(s.AvgSendError),
+		MaxSendError:     milliseconds(s.MaxSendError),
+		SendRate:         s.SendRate,
+	}
+	if s.IPAddr != nil {
+		out.IPAddr = s.IPAddr.String()
+	}
+	for _, p := range s.Percentiles {
+		out.Percentiles = append(out.Percentiles, percentileJSON{p.Percentile, milliseconds(p.Rtt)})
+	}
+	for _, size := range s.Sizes {
+		out.Sizes = append(out.Sizes, sizeJSON{size.Size, size.PacketsSent, size.PacketsRecv,
+			size.PacketLoss, milliseconds(size.MinRtt), milliseconds(size.AvgRtt),
+			milliseconds(size.MaxRtt)})
+	}
+	for _, h := range s.Histogram {
+		out.Histogram = append(out.Histogram, bucketJSON{milliseconds(h.UpperBound), h.Count})
+	}
+	for _, rtt := range s.Rtts {
+		out.Rtts = append(out.Rtts, milliseconds(rtt))
+	}
+	return json.Marshal(&out)
+}
+
+// Expvar returns the statistics of the pinger as an expvar variable, to be
+// published with expvar.Publish, read while Run is active.
+func (p *Pinger) Expvar() expvar.Var {
+	return expvar.Func(func() interface{} {
+		return p.Statistics()
+	})
+}
+
+// CSVWriter writes per-packet records as CSV, with a header line before the
+// first one. The packets written with WritePacket are the replies, and have
+// the columns time, ip, raddr, seq, bytes, ttl and rtt_ms. The probes
+// written with WriteProbe are the echo requests, answered or not, and have
+// the columns seq, sent, received, rtt_ms and lost. The times are in RFC
+// 3339 format. A CSVWriter should only be used for one kind of record.
+type CSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVWriter returns a CSVWriter writing to w.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// write writes record, after header if it is the first one.
+func (c *CSVWriter) write(header, record []string) error {
+	if !c.header {
+		c.header = true
+		if err := c.w.Write(header); err != nil {
+			return err
+		}
+	}
+	return c.w.Write(record)
+}
+
+// WritePacket writes a reply received at the time of the call, and is meant
+// to be called from OnRecv.
+func (c *CSVWriter) WritePacket(pkt *Packet) error {
+	var ip string
+	if pkt.IPAddr != nil {
+		ip = pkt.IPAddr.String()
+	}
+	return c.write(
+		[]string{"time", "ip", "raddr", "seq", "bytes", "ttl", "rtt_ms"},
+		[]string{
+			time.Now().Format(time.RFC3339Nano), ip, pkt.RAddr,
+			strconv.Itoa(pkt.Seq), strconv.Itoa(pkt.Nbytes), strconv.Itoa(pkt.Ttl),
+			formatMilliseconds(pkt.Rtt),
+		})
+}
+
+// WriteProbe writes the outcome of an echo request, like those of
+// Statistics.Probes.
+func (c *CSVWriter) WriteProbe(pr ProbeResult) error {
+	var received string
+	if !pr.Received.IsZero() {
+		received = pr.Received.Format(time.RFC3339Nano)
+	}
+	return c.write(
+		[]string{"seq", "sent", "received", "rtt_ms", "lost"},
+		[]string{
+			strconv.Itoa(pr.Seq), pr.Sent.Format(time.RFC3339Nano), received,
+			formatMilliseconds(pr.Rtt), strconv.FormatBool(pr.Lost),
+		})
+}
+
+// Flush writes the buffered records, and returns any error writing them.
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatMilliseconds(d time.Duration) string {
+	return strconv.FormatFloat(milliseconds(d), 'f', -1, 64)
+}
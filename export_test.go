@@ -0,0 +1,77 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatisticsJSON(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.PacketsSent = 4
+	p.PacketsRecv = 2
+	p.PacketsRecvErrors = 1
+	p.rtts = []time.Duration{time.Millisecond, 3 * time.Millisecond}
+
+	b, err := json.Marshal(p.Statistics())
+	AssertNoError(t, err)
+	var out map[string]interface{}
+	AssertNoError(t, json.Unmarshal(b, &out))
+	for key, expected := range map[string]interface{}{
+		"addr":                "127.0.0.1",
+		"ip_addr":             "127.0.0.1",
+		"packets_sent":        4.0,
+		"packets_recv":        2.0,
+		"packets_recv_errors": 1.0,
+		"packet_loss":         50.0,
+		"min_rtt_ms":          1.0,
+		"avg_rtt_ms":          2.0,
+	} {
+		if out[key] != expected {
+			t.Errorf("Expected %v for %s, got %v", expected, key, out[key])
+		}
+	}
+	if rtts, ok := out["rtts_ms"].([]interface{}); !ok || len(rtts) != 2 {
+		t.Errorf("Expected %v, got %v", 2, out["rtts_ms"])
+	}
+
+	var stats map[string]interface{}
+	AssertNoError(t, json.Unmarshal([]byte(p.Expvar().String()), &stats))
+	if stats["packets_recv"] != 2.0 {
+		t.Errorf("Expected %v, got %v", 2, stats["packets_recv"])
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	var b bytes.Buffer
+	w := NewCSVWriter(&b)
+	for seq := 0; seq < 2; seq++ {
+		AssertNoError(t, w.WritePacket(&Packet{
+			Rtt: 1500 * time.Microsecond, IPAddr: p.IPAddr(), RAddr: "127.0.0.1",
+			Nbytes: 24, Seq: seq, Ttl: 64,
+		}))
+	}
+	AssertNoError(t, w.Flush())
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected %v lines, got %v", 3, lines)
+	}
+	AssertEqualStrings(t, "time,ip,raddr,seq,bytes,ttl,rtt_ms", lines[0])
+	if !strings.HasSuffix(lines[2], ",127.0.0.1,127.0.0.1,1,24,64,1.5") {
+		t.Errorf("Expected the second reply, got %v", lines[2])
+	}
+
+	b.Reset()
+	w = NewCSVWriter(&b)
+	sent := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	AssertNoError(t, w.WriteProbe(ProbeResult{Seq: 7, Sent: sent, Lost: true}))
+	AssertNoError(t, w.Flush())
+	AssertEqualStrings(t, "seq,sent,received,rtt_ms,lost\n7,2020-01-02T03:04:05Z,,0,true\n",
+		b.String())
+}
@@ -0,0 +1,71 @@
+package ping
+
+import "time"
+
+// bufferbloatGrades are the latency increase upper bounds of each grade,
+// matching the ones used by popular bufferbloat tests.
+var bufferbloatGrades = []struct {
+	grade string
+	max   time.Duration
+}{
+	{"A+", 5 * time.Millisecond},
+	{"A", 30 * time.Millisecond},
+	{"B", 60 * time.Millisecond},
+	{"C", 200 * time.Millisecond},
+	{"D", 400 * time.Millisecond},
+}
+
+// BufferbloatGrade summarizes a latency-under-load measurement for display.
+type BufferbloatGrade struct {
+	// Grade is the letter grade, from "A+" to "F", which is also the grade
+	// of a measurement without replies.
+	Grade string
+
+	// Score goes from 100 for no latency increase down to 0 for an increase
+	// of 400ms or more, or no replies.
+	Score int
+
+	// IdleRtt is the average round-trip time without load.
+	IdleRtt time.Duration
+
+	// LoadedRtt is the average round-trip time under load.
+	LoadedRtt time.Duration
+
+	// LatencyIncrease is LoadedRtt minus IdleRtt.
+	LatencyIncrease time.Duration
+
+	// Jitter is the Jitter of the statistics under load.
+	Jitter time.Duration
+}
+
+// Grade returns the bufferbloat grade of r.
+func (r *LoadResult) Grade() *BufferbloatGrade {
+	g := &BufferbloatGrade{
+		Grade:           "F",
+		IdleRtt:         r.Idle.AvgRtt,
+		LoadedRtt:       r.Loaded.AvgRtt,
+		LatencyIncrease: r.LatencyIncrease,
+		Jitter:          r.Loaded.Jitter,
+	}
+	if r.Idle.PacketsRecv == 0 || r.Loaded.PacketsRecv == 0 {
+		// The latency increase is unknown
+		return g
+	}
+
+	increase := r.LatencyIncrease
+	if increase < 0 {
+		increase = 0
+	}
+	for _, b := range bufferbloatGrades {
+		if increase < b.max {
+			g.Grade = b.grade
+			break
+		}
+	}
+
+	limit := bufferbloatGrades[len(bufferbloatGrades)-1].max
+	if increase < limit {
+		g.Score = int(100 - 100*float64(increase)/float64(limit))
+	}
+	return g
+}
@@ -0,0 +1,80 @@
+package ping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBufferbloatGrade(t *testing.T) {
+	tests := []struct {
+		Idle   time.Duration
+		Loaded []time.Duration
+		Grade  string
+		Score  int
+		Jitter time.Duration
+	}{
+		{
+			Idle:   10 * time.Millisecond,
+			Loaded: []time.Duration{10 * time.Millisecond, 14 * time.Millisecond},
+			Grade:  "A+",
+			Score:  99,
+			// Smoothed over 16 replies
+			Jitter: 4 * time.Millisecond / 16,
+		}, {
+			Idle:   10 * time.Millisecond,
+			Loaded: []time.Duration{60 * time.Millisecond, 60 * time.Millisecond},
+			Grade:  "B",
+			Score:  87,
+		}, {
+			Idle:   10 * time.Millisecond,
+			Loaded: []time.Duration{500 * time.Millisecond},
+			Grade:  "F",
+			Score:  0,
+		}, {
+			Idle:   10 * time.Millisecond,
+			Loaded: []time.Duration{5 * time.Millisecond},
+			Grade:  "A+",
+			Score:  100,
+		},
+	}
+
+	for _, set := range tests {
+		idle := statistics(1, 1, []time.Duration{set.Idle})
+		loaded := statistics(len(set.Loaded), len(set.Loaded), set.Loaded)
+		r := &LoadResult{
+			Idle:            idle,
+			Loaded:          loaded,
+			LatencyIncrease: loaded.AvgRtt - idle.AvgRtt,
+		}
+		g := r.Grade()
+		AssertEqualStrings(t, set.Grade, g.Grade)
+		if g.Score != set.Score {
+			t.Errorf("Expected %v, got %v", set.Score, g.Score)
+		}
+		if g.Jitter != set.Jitter {
+			t.Errorf("Expected %v, got %v", set.Jitter, g.Jitter)
+		}
+	}
+
+	// Without round-trip times, under a MemoryBudget
+	loaded := &Statistics{PacketsRecv: 1, AvgRtt: 12 * time.Millisecond,
+		Jitter: 3 * time.Millisecond}
+	idle := &Statistics{PacketsRecv: 1, AvgRtt: 10 * time.Millisecond}
+	r := &LoadResult{Idle: idle, Loaded: loaded}
+	if g := r.Grade(); g.Jitter != loaded.Jitter {
+		t.Errorf("Expected %v, got %v", loaded.Jitter, g.Jitter)
+	}
+
+	// Without replies, under load or idle
+	lost := statistics(4, 0, nil)
+	for _, r := range []*LoadResult{
+		{Idle: idle, Loaded: lost, LatencyIncrease: -idle.AvgRtt},
+		{Idle: lost, Loaded: loaded, LatencyIncrease: loaded.AvgRtt},
+	} {
+		g := r.Grade()
+		AssertEqualStrings(t, "F", g.Grade)
+		if g.Score != 0 {
+			t.Errorf("Expected %v, got %v", 0, g.Score)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package ping
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPError is an ICMP error message received about one of the echo
+// requests: Destination Unreachable, Time Exceeded, Parameter Problem, or
+// Packet Too Big for IPv6. It is only received by privileged pingers.
+type ICMPError struct {
+	// Seq is the sequence number of the echo request.
+	Seq int
+
+	// Type and Code are the type and code of the message.
+	Type icmp.Type
+	Code int
+
+	// RAddr is the address of the host that sent the message, usually a
+	// router on the path.
+	RAddr string
+
+	// MTU is the next hop MTU of Fragmentation Needed and Packet Too Big
+	// messages, zero if unknown.
+	MTU int
+}
+
+func (e *ICMPError) Error() string {
+	return fmt.Sprintf("%s from %s for echo request %d", e.Reason(), e.RAddr, e.Seq)
+}
+
+// Reason returns a description of the error, like "Destination host
+// unreachable".
+func (e *ICMPError) Reason() string {
+	switch e.Type {
+	case ipv4.ICMPTypeDestinationUnreachable:
+		switch e.Code {
+		case 0:
+			return "Destination net unreachable"
+		case 1:
+			return "Destination host unreachable"
+		case 2:
+			return "Destination protocol unreachable"
+		case 3:
+			return "Destination port unreachable"
+		case 4:
+			return "Fragmentation needed"
+		case 9, 10, 13:
+			return "Communication administratively prohibited"
+		}
+		return fmt.Sprintf("Destination unreachable, code %d", e.Code)
+	case ipv6.ICMPTypeDestinationUnreachable:
+		switch e.Code {
+		case 0:
+			return "No route to destination"
+		case 1:
+			return "Communication administratively prohibited"
+		case 3:
+			return "Destination address unreachable"
+		case 4:
+			return "Destination port unreachable"
+		}
+		return fmt.Sprintf("Destination unreachable, code %d", e.Code)
+	case ipv4.ICMPTypeTimeExceeded:
+		if e.Code == 0 {
+			return "Time to live exceeded"
+		}
+		return "Fragment reassembly time exceeded"
+	case ipv6.ICMPTypeTimeExceeded:
+		if e.Code == 0 {
+			return "Hop limit exceeded"
+		}
+		return "Fragment reassembly time exceeded"
+	case ipv4.ICMPTypeParameterProblem, ipv6.ICMPTypeParameterProblem:
+		return fmt.Sprintf("Parameter problem, code %d", e.Code)
+	case ipv6.ICMPTypePacketTooBig:
+		return "Packet too big"
+	}
+	return fmt.Sprintf("ICMP error type %d, code %d", e.Type.Protocol(), e.Code)
+}
+
+// quotedRequest returns the packet quoted by ICMP error message m, nil if m
+// is not an error message. b is the raw message.
+func quotedRequest(m *icmp.Message, b []byte) (quoted []byte, mtu int) {
+	switch body := m.Body.(type) {
+	case *icmp.DstUnreach:
+		if m.Type == ipv4.ICMPTypeDestinationUnreachable && m.Code == 4 {
+			// The next hop MTU is in the unused half of the header
+			mtu = int(binary.BigEndian.Uint16(b[6:8]))
+		}
+		return body.Data, mtu
+	case *icmp.TimeExceeded:
+		return body.Data, 0
+	case *icmp.ParamProb:
+		return body.Data, 0
+	case *icmp.PacketTooBig:
+		return body.Data, body.MTU
+	}
+	return nil, 0
+}
+
+// quotedDst returns the destination of the IP packet quoted by an ICMP error.
+func quotedDst(quoted []byte, ipv4Quoted bool) net.IP {
+	if ipv4Quoted {
+		if len(quoted) < ipv4.HeaderLen {
+			return nil
+		}
+		return net.IP(quoted[16:20])
+	}
+	if len(quoted) < ipv6.HeaderLen {
+		return nil
+	}
+	return net.IP(quoted[24:40])
+}
+
+// parseICMPError returns the error reported by m if it is an ICMP error
+// message about one of our echo requests to the target, and nil otherwise. b
+// is the raw message.
+func (p *Pinger) parseICMPError(m *icmp.Message, b []byte) *ICMPError {
+	quoted, mtu := quotedRequest(m, b)
+	if quoted == nil || !quotedDst(quoted, p.ipv4).Equal(p.ipaddr.IP) {
+		return nil
+	}
+	id, seq, ok := quotedEcho(quoted, p.ipv4)
+	if !ok || (p.network != "udp" && id != p.id) {
+		return nil
+	}
+	return &ICMPError{Seq: seq, Type: m.Type, Code: m.Code, MTU: mtu}
+}
+
+// processICMPError counts and reports an ICMP error message about an
+// outstanding echo request. For a message about fragmentation it returns the
+// FragmentationNeededError to report to OnError.
+func (p *Pinger) processICMPError(e *ICMPError) error {
+	pr := p.probes[e.Seq&0xffff]
+	if pr == nil || !pr.Received.IsZero() || pr.Lost {
+		// Not outstanding
+		return nil
+	}
+	e.Seq = pr.Seq
+	p.PacketsRecvErrors += 1
+	if handler := p.OnRecvError; handler != nil {
+		p.callback(func() { handler(e) })
+	}
+	if (e.Type == ipv4.ICMPTypeDestinationUnreachable && e.Code == 4) ||
+		e.Type == ipv6.ICMPTypePacketTooBig {
+		return &FragmentationNeededError{Seq: e.Seq, Size: p.ipPacketSize(p.sizeOf(e.Seq)), MTU: e.MTU}
+	}
+	return nil
+}
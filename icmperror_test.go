@@ -0,0 +1,102 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPError(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	var errs []*ICMPError
+	p.OnRecvError = func(e *ICMPError) {
+		errs = append(errs, e)
+	}
+
+	p.sequence = 0x10003
+	probe, _, err := p.prober.Marshal(p, p.sequence)
+	AssertNoError(t, err)
+	p.trackSent(p.sequence, time.Now())
+
+	// IPv4 header of the request, followed by its first 8 bytes
+	quoted := append(make([]byte, ipv4.HeaderLen), probe[:8]...)
+	quoted[0] = 0x45
+	copy(quoted[16:20], net.ParseIP("127.0.0.1").To4())
+	for _, m := range []*icmp.Message{
+		{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 1, Body: &icmp.DstUnreach{Data: quoted}},
+		{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}},
+		{Type: ipv4.ICMPTypeParameterProblem, Body: &icmp.ParamProb{Data: quoted}},
+	} {
+		b, err := m.Marshal(nil)
+		AssertNoError(t, err)
+		AssertNoError(t, p.processPacket(&packet{bytes: b, nbytes: len(b), rAddr: "192.0.2.1"}))
+	}
+	if len(errs) != 3 || p.Statistics().PacketsRecvErrors != 3 {
+		t.Fatalf("Expected %v errors, got %v", 3, errs)
+	}
+	if errs[0].Seq != 0x10003 || errs[0].RAddr != "192.0.2.1" {
+		t.Errorf("Expected request %v from 192.0.2.1, got %+v", 0x10003, errs[0])
+	}
+	AssertEqualStrings(t, "Destination host unreachable from 192.0.2.1 for echo request 65539",
+		errs[0].Error())
+	AssertEqualStrings(t, "Time to live exceeded", errs[1].Reason())
+	if dst := quotedDst(quoted, true); !dst.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Expected %v, got %v", "127.0.0.1", dst)
+	}
+
+	// Errors about requests to another host are ignored
+	other := append([]byte(nil), quoted...)
+	copy(other[16:20], net.ParseIP("127.0.0.2").To4())
+	b, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeDestinationUnreachable, Code: 1, Body: &icmp.DstUnreach{Data: other},
+	}).Marshal(nil)
+	AssertNoError(t, err)
+	AssertNoError(t, p.processPacket(&packet{bytes: b, nbytes: len(b)}))
+	if p.PacketsRecvErrors != 3 {
+		t.Errorf("Expected %v, got %v", 3, p.PacketsRecvErrors)
+	}
+
+	// Errors about answered requests are ignored
+	p.trackReply(p.sequence, time.Now(), time.Millisecond)
+	b, err = (&icmp.Message{
+		Type: ipv4.ICMPTypeDestinationUnreachable, Code: 1, Body: &icmp.DstUnreach{Data: quoted},
+	}).Marshal(nil)
+	AssertNoError(t, err)
+	AssertNoError(t, p.processPacket(&packet{bytes: b, nbytes: len(b)}))
+	if p.PacketsRecvErrors != 3 {
+		t.Errorf("Expected %v, got %v", 3, p.PacketsRecvErrors)
+	}
+}
+
+func TestICMPErrorIPv6(t *testing.T) {
+	p, err := NewPinger(context.Background(), "::1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	var last *ICMPError
+	p.OnRecvError = func(e *ICMPError) {
+		last = e
+	}
+	probe, _, err := p.prober.Marshal(p, 2)
+	AssertNoError(t, err)
+	p.trackSent(2, time.Now())
+
+	quoted := append(make([]byte, ipv6.HeaderLen), probe[:8]...)
+	copy(quoted[24:40], net.ParseIP("::1"))
+	b, err := (&icmp.Message{
+		Type: ipv6.ICMPTypeDestinationUnreachable, Code: 3,
+		Body: &icmp.DstUnreach{Data: quoted},
+	}).Marshal(nil)
+	AssertNoError(t, err)
+	AssertNoError(t, p.processPacket(&packet{bytes: b, nbytes: len(b), rAddr: "2001:db8::1"}))
+	if last == nil || last.Seq != 2 {
+		t.Fatalf("Expected an error about request 2, got %v", last)
+	}
+	AssertEqualStrings(t, "Destination address unreachable", last.Reason())
+}
@@ -0,0 +1,195 @@
+package ping
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// IPOption is an IPv4 option of the echo requests, recorded by the hops
+// along the path and echoed back in the replies.
+type IPOption int
+
+const (
+	// IPOptionNone sends echo requests without IP options.
+	IPOptionNone IPOption = iota
+
+	// IPOptionRecordRoute records the addresses of up to 9 hops, as ping -R.
+	IPOptionRecordRoute
+
+	// IPOptionTimestamp records the timestamps of up to 9 hops, as
+	// ping -T tsonly.
+	IPOptionTimestamp
+
+	// IPOptionTimestampAddr records the addresses and timestamps of up to 4
+	// hops, as ping -T tsandaddr.
+	IPOptionTimestampAddr
+)
+
+// Option types and lengths of RFC 791
+const (
+	ipOptEnd         = 0
+	ipOptNop         = 1
+	ipOptRecordRoute = 7
+	ipOptTimestamp   = 68
+
+	// maxIPOptionsLen is the room for options in the IPv4 header.
+	maxIPOptionsLen = 40
+)
+
+// IPOptions is the data recorded by the IP option of the echo requests,
+// parsed from the header of a reply.
+type IPOptions struct {
+	// Route is the addresses recorded by IPOptionRecordRoute, or with the
+	// timestamps by IPOptionTimestampAddr.
+	Route []net.IP
+
+	// Timestamps are the timestamps recorded by IPOptionTimestamp and
+	// IPOptionTimestampAddr, in milliseconds since midnight UT, or a
+	// non-standard time if their high bit is set.
+	Timestamps []uint32
+
+	// Overflow is the number of hops that couldn't record a timestamp for
+	// lack of room.
+	Overflow int
+}
+
+// SetIPOption sets the IPv4 option of the echo requests. The data recorded
+// is reported in the Options of the replies, once the hops along the path
+// and the target have filled it in. Many routers ignore or drop packets with
+// options, so it is best used where traceroute isn't possible. Options are
+// only reported in privileged mode, where the IPv4 header of replies is
+// received, and only supported on Linux, macOS and FreeBSD.
+func (p *Pinger) SetIPOption(option IPOption) error {
+	if option < IPOptionNone || option > IPOptionTimestampAddr {
+		return fmt.Errorf("Unknown IP option %d", option)
+	}
+	if option != IPOptionNone && !p.ipv4 {
+		return fmt.Errorf("IP options are only supported with IPv4, not %s", p.ipaddr)
+	}
+	p.ipOption = option
+	return nil
+}
+
+// IPOption returns the IP option set by SetIPOption.
+func (p *Pinger) IPOption() IPOption {
+	return p.ipOption
+}
+
+// marshal returns the option as set on the socket, with room for the hops
+// to record their data.
+func (o IPOption) marshal() []byte {
+	switch o {
+	case IPOptionRecordRoute:
+		// Aligned on 4 bytes by a leading no-op, as ping does
+		b := make([]byte, maxIPOptionsLen)
+		b[0] = ipOptNop
+		b[1], b[2], b[3] = ipOptRecordRoute, maxIPOptionsLen-1, 4
+		return b
+	case IPOptionTimestamp:
+		b := make([]byte, maxIPOptionsLen)
+		b[0], b[1], b[2] = ipOptTimestamp, maxIPOptionsLen, 5
+		return b
+	case IPOptionTimestampAddr:
+		b := make([]byte, 4+4*8)
+		b[0], b[1], b[2], b[3] = ipOptTimestamp, byte(len(b)), 5, 1
+		return b
+	}
+	return nil
+}
+
+// parseIPOptions parses the options of an IPv4 header, returning nil if it
+// has neither Record Route nor Timestamp.
+func parseIPOptions(b []byte) *IPOptions {
+	var opts *IPOptions
+	for i := 0; i < len(b); {
+		typ := b[i]
+		if typ == ipOptEnd {
+			break
+		}
+		if typ == ipOptNop {
+			i++
+			continue
+		}
+		if i+2 > len(b) || b[i+1] < 2 || i+int(b[i+1]) > len(b) {
+			break
+		}
+		opt := b[i : i+int(b[i+1])]
+		i += len(opt)
+		if len(opt) < 4 {
+			continue
+		}
+		// The pointer is one past the last byte recorded, counting from 1
+		end := int(opt[2]) - 1
+		if end > len(opt) {
+			end = len(opt)
+		}
+		switch typ {
+		case ipOptRecordRoute:
+			if opts == nil {
+				opts = &IPOptions{}
+			}
+			for j := 3; j+4 <= end; j += 4 {
+				opts.Route = append(opts.Route, net.IP(append([]byte(nil), opt[j:j+4]...)))
+			}
+		case ipOptTimestamp:
+			if opts == nil {
+				opts = &IPOptions{}
+			}
+			opts.Overflow = int(opt[3] >> 4)
+			step := 4
+			if opt[3]&0x0f != 0 {
+				// Address and timestamp
+				step = 8
+			}
+			for j := 4; j+step <= end; j += step {
+				if step == 8 {
+					opts.Route = append(opts.Route, net.IP(append([]byte(nil), opt[j:j+4]...)))
+				}
+				opts.Timestamps = append(opts.Timestamps, binary.BigEndian.Uint32(opt[j+step-4:j+step]))
+			}
+		}
+	}
+	return opts
+}
+
+// readHeader reads a packet received on a raw IPv4 socket like readPacket,
+// with the TOS and options of its IPv4 header.
+func readHeader(c ipConn, b []byte) (*packet, net.Addr, error) {
+	// Unlike ReadFrom, ReadBatch keeps the IPv4 header
+	ms := []ipv4.Message{{
+		Buffers: [][]byte{b},
+		OOB:     make([]byte, len(ipv4.NewControlMessage(ipv4.FlagTTL))+timestampSpace),
+	}}
+	if _, err := c.IPv4PacketConn().ReadBatch(ms, 0); err != nil {
+		return nil, nil, err
+	}
+	m := ms[0]
+	pkt := &packet{bytes: b}
+	var cm ipv4.ControlMessage
+	if cm.Parse(m.OOB[:m.NN]) == nil {
+		pkt.ttl = cm.TTL
+	}
+	pkt.stripHeader(m.N)
+	return pkt, m.Addr, nil
+}
+
+// stripHeader strips the IPv4 header off the n bytes of pkt read on a raw
+// socket, keeping its TOS and options.
+func (pkt *packet) stripHeader(n int) {
+	b := pkt.bytes
+	hdrlen := ipv4.HeaderLen
+	if n > 0 {
+		hdrlen = int(b[0]&0x0f) << 2
+	}
+	if hdrlen < ipv4.HeaderLen || hdrlen > n {
+		return
+	}
+	pkt.tos = int(b[1])
+	if hdrlen > ipv4.HeaderLen {
+		pkt.options = append([]byte(nil), b[ipv4.HeaderLen:hdrlen]...)
+	}
+	pkt.nbytes = copy(b, b[hdrlen:n])
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package ping
+
+const rawHeaders = false
+
+func setIPOptions(fd uintptr, options []byte) error {
+	return ErrUnsupportedPlatform
+}
@@ -0,0 +1,73 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetIPOption(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertNoError(t, p.SetIPOption(IPOptionRecordRoute))
+	if p.IPOption() != IPOptionRecordRoute {
+		t.Errorf("Expected %v, got %v", IPOptionRecordRoute, p.IPOption())
+	}
+	AssertError(t, p.SetIPOption(IPOption(7)), "unknown option")
+
+	p6, err := NewPinger(context.Background(), "::1")
+	AssertNoError(t, err)
+	AssertError(t, p6.SetIPOption(IPOptionTimestamp), "IPv6 target")
+	AssertNoError(t, p6.SetIPOption(IPOptionNone))
+}
+
+func TestParseIPOptions(t *testing.T) {
+	// Record Route filled by 2 of 9 hops
+	rr := IPOptionRecordRoute.marshal()
+	rr[3] = 4 + 2*4
+	copy(rr[4:], []byte{192, 0, 2, 1, 198, 51, 100, 1})
+	opts := parseIPOptions(rr)
+	if opts == nil || len(opts.Route) != 2 || !opts.Route[1].Equal(net.ParseIP("198.51.100.1")) {
+		t.Fatalf("Expected 2 hops, got %+v", opts)
+	}
+
+	// Addresses and timestamps of 4 hops, and 3 more without room
+	ts := IPOptionTimestampAddr.marshal()
+	ts[2] = 5 + 4*8
+	ts[3] |= 3 << 4
+	copy(ts[4:], []byte{192, 0, 2, 1, 0, 0, 0x30, 0x39})
+	opts = parseIPOptions(ts)
+	if opts == nil || len(opts.Route) != 4 || len(opts.Timestamps) != 4 || opts.Overflow != 3 {
+		t.Fatalf("Expected 4 hops and 3 overflowing, got %+v", opts)
+	}
+	if opts.Timestamps[0] != 12345 || !opts.Route[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("Expected 192.0.2.1 at %v, got %v at %v", 12345, opts.Route[0], opts.Timestamps[0])
+	}
+
+	if opts := parseIPOptions([]byte{ipOptNop, ipOptEnd, 0, 0}); opts != nil {
+		t.Errorf("Expected %v, got %+v", nil, opts)
+	}
+}
+
+func TestRecordRoute(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	AssertNoError(t, p.SetIPOption(IPOptionRecordRoute))
+	p.Count = 1
+	p.Timeout = time.Second
+	var opts *IPOptions
+	p.OnRecv = func(pkt *Packet) {
+		opts = pkt.Options
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if p.PacketsRecv != 1 {
+		t.Fatalf("Expected %v, got %v", 1, p.PacketsRecv)
+	}
+	if opts == nil || len(opts.Route) == 0 || !opts.Route[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Expected the route through 127.0.0.1, got %+v", opts)
+	}
+}
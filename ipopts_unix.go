@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package ping
+
+import "syscall"
+
+// rawHeaders is whether the IPv4 header of the packets received on raw
+// sockets can be read.
+const rawHeaders = true
+
+// setIPOptions sets the IPv4 options of the packets sent on socket fd.
+func setIPOptions(fd uintptr, options []byte) error {
+	return syscall.SetsockoptString(int(fd), syscall.IPPROTO_IP, syscall.IP_OPTIONS, string(options))
+}
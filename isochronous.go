@@ -0,0 +1,77 @@
+package ping
+
+import (
+	"net"
+	"time"
+)
+
+// schedule fires at fixed times start + n*interval, regardless of how long
+// sending takes, for isochronous probing.
+type schedule struct {
+	start    time.Time
+	interval time.Duration
+	slot     int
+	clock    Clock
+	timer    Timer
+}
+
+func newSchedule(clock Clock, start time.Time, interval time.Duration) *schedule {
+	s := &schedule{start: start, interval: interval, slot: 1, clock: clock}
+	s.timer = clock.NewTimer(s.next().Sub(clock.Now()))
+	return s
+}
+
+// next returns the scheduled time of the current slot.
+func (s *schedule) next() time.Time {
+	return s.start.Add(time.Duration(s.slot) * s.interval)
+}
+
+// due returns the scheduled time of the probe to send at now, skipping the
+// slots that are already over. It returns how many slots were skipped.
+func (s *schedule) due(now time.Time) (time.Time, int) {
+	missed := 0
+	if late := now.Sub(s.next()); late >= s.interval {
+		missed = int(late / s.interval)
+		s.slot += missed
+	}
+	return s.next(), missed
+}
+
+// advance moves to the next slot and rearms the timer.
+func (s *schedule) advance() {
+	s.slot++
+	s.timer.Reset(s.next().Sub(s.clock.Now()))
+}
+
+// setInterval changes the interval of the slots after the current one.
+func (s *schedule) setInterval(interval time.Duration) {
+	s.start = s.next().Add(-time.Duration(s.slot) * interval)
+	s.interval = interval
+}
+
+func (s *schedule) stop() {
+	s.timer.Stop()
+}
+
+// sendScheduled sends the probe due on sched, accounting for missed slots
+// and the send-time error. Slots outside of the pinger's windows are skipped
+// without being counted as missed.
+func (p *Pinger) sendScheduled(conn net.PacketConn, sched *schedule) error {
+	now := p.now()
+	scheduled, missed := sched.due(now)
+	defer sched.advance()
+	if !p.active(now) {
+		return nil
+	}
+	p.probesMissed += missed
+	p.recordSendError(now.Sub(scheduled))
+	return p.sendProbe(conn)
+}
+
+func (p *Pinger) recordSendError(d time.Duration) {
+	p.sendErrorCount++
+	p.sendErrorTotal += d
+	if d > p.sendErrorMax {
+		p.sendErrorMax = d
+	}
+}
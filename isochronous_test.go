@@ -0,0 +1,30 @@
+package ping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleDue(t *testing.T) {
+	start := time.Now()
+	s := newSchedule(SystemClock, start, time.Second)
+	defer s.stop()
+
+	scheduled, missed := s.due(start.Add(1100 * time.Millisecond))
+	if !scheduled.Equal(start.Add(time.Second)) || missed != 0 {
+		t.Errorf("Expected %v and 0 missed, got %v and %v",
+			start.Add(time.Second), scheduled, missed)
+	}
+	s.advance()
+
+	// Slots 2 and 3 are over by the time the pinger gets to them
+	scheduled, missed = s.due(start.Add(4200 * time.Millisecond))
+	if !scheduled.Equal(start.Add(4*time.Second)) || missed != 2 {
+		t.Errorf("Expected %v and 2 missed, got %v and %v",
+			start.Add(4*time.Second), scheduled, missed)
+	}
+	s.advance()
+	if !s.next().Equal(start.Add(5 * time.Second)) {
+		t.Errorf("Expected %v, got %v", start.Add(5*time.Second), s.next())
+	}
+}
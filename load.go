@@ -0,0 +1,153 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Load generates network load while a Pinger measures latency under load.
+// Start is called when the loaded phase begins and Stop when it ends.
+type Load interface {
+	Start() error
+	Stop() error
+}
+
+// LoadFunc adapts an external load signal to the Load interface. It is called
+// with true when the loaded phase begins and false when it ends, so programs
+// can drive their own load generator.
+type LoadFunc func(loaded bool) error
+
+// Start calls f(true).
+func (f LoadFunc) Start() error {
+	return f(true)
+}
+
+// Stop calls f(false).
+func (f LoadFunc) Stop() error {
+	return f(false)
+}
+
+// UDPLoad is a Load sending UDP datagrams to Addr at a configurable rate.
+type UDPLoad struct {
+	// Addr is the "host:port" address the datagrams are sent to.
+	Addr string
+
+	// Rate is the load in bytes per second. Zero sends as fast as possible.
+	Rate int
+
+	// Size is the size of each datagram. Default is 1400.
+	Size int
+
+	conn net.Conn
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Start starts sending datagrams.
+func (l *UDPLoad) Start() error {
+	conn, err := net.Dial("udp", l.Addr)
+	if err != nil {
+		return err
+	}
+	size := l.Size
+	if size <= 0 {
+		size = 1400
+	}
+
+	l.conn = conn
+	l.stop = make(chan struct{})
+	l.wg.Add(1)
+	go l.send(make([]byte, size))
+	return nil
+}
+
+func (l *UDPLoad) send(b []byte) {
+	defer l.wg.Done()
+	start := time.Now()
+	var sent int64
+	for {
+		select {
+		case <-l.stop:
+			return
+		default:
+		}
+
+		if l.Rate > 0 {
+			next := start.Add(time.Duration(sent * int64(time.Second) / int64(l.Rate)))
+			if d := time.Until(next); d > 0 {
+				time.Sleep(d)
+			}
+		}
+		// Errors such as ECONNREFUSED from ICMP port unreachable replies
+		// are expected, the load is what matters.
+		l.conn.Write(b)
+		sent += int64(len(b))
+	}
+}
+
+// Stop stops sending datagrams. It does nothing if the load isn't started.
+func (l *UDPLoad) Stop() error {
+	if l.stop == nil {
+		return nil
+	}
+	close(l.stop)
+	l.wg.Wait()
+	l.stop = nil
+	return l.conn.Close()
+}
+
+// LoadResult holds the outcome of a latency-under-load measurement.
+type LoadResult struct {
+	// Idle are the statistics measured before the load was started.
+	Idle *Statistics
+
+	// Loaded are the statistics measured while the load was running.
+	Loaded *Statistics
+
+	// LatencyIncrease is the loaded average round-trip time minus the idle
+	// one, a simple bufferbloat indicator.
+	LatencyIncrease time.Duration
+}
+
+// RunUnderLoad pings the target for the idle duration, then starts load and
+// keeps pinging for the loaded duration, and returns the statistics of both
+// phases. The options and OnRecv callback of p are used for both phases, but
+// p itself is not run and its statistics are left untouched. This is a
+// blocking function, returning the error of ctx if it is done first. It is
+// not available in a pingminimal build, as each phase opens its socket.
+func (p *Pinger) RunUnderLoad(ctx context.Context, load Load, idle, loaded time.Duration) (*LoadResult, error) {
+	if MinimalSyscalls {
+		return nil, ErrMinimalSyscalls
+	}
+	idlePinger := p.clone()
+	idlePinger.Count = -1
+	idlePinger.Timeout = idle
+	idlePinger.OnRecv = p.OnRecv
+	if err := idlePinger.Run(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := load.Start(); err != nil {
+		return nil, err
+	}
+	loadedPinger := p.clone()
+	loadedPinger.Count = -1
+	loadedPinger.Timeout = loaded
+	loadedPinger.OnRecv = p.OnRecv
+	err := loadedPinger.Run(ctx)
+	if stopErr := load.Stop(); err == nil {
+		err = stopErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &LoadResult{
+		Idle:   idlePinger.Statistics(),
+		Loaded: loadedPinger.Statistics(),
+	}
+	r.LatencyIncrease = r.Loaded.AvgRtt - r.Idle.AvgRtt
+	return r, nil
+}
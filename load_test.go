@@ -0,0 +1,74 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunUnderLoad(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Interval = 50 * time.Millisecond
+
+	var signals []bool
+	load := LoadFunc(func(loaded bool) error {
+		signals = append(signals, loaded)
+		return nil
+	})
+
+	r, err := p.RunUnderLoad(context.Background(), load, 300*time.Millisecond, 300*time.Millisecond)
+	if MinimalSyscalls {
+		if err != ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+		}
+		return
+	}
+	AssertNoError(t, err)
+	if len(signals) != 2 || !signals[0] || signals[1] {
+		t.Errorf("Expected [true false], got %v", signals)
+	}
+	if r.Idle.PacketsRecv == 0 || r.Loaded.PacketsRecv == 0 {
+		t.Skipf("No replies from 127.0.0.1, can't check statistics")
+	}
+	if r.LatencyIncrease != r.Loaded.AvgRtt-r.Idle.AvgRtt {
+		t.Errorf("Expected %v, got %v", r.Loaded.AvgRtt-r.Idle.AvgRtt,
+			r.LatencyIncrease)
+	}
+	if p.PacketsSent != 0 {
+		t.Errorf("Expected %v, got %v", 0, p.PacketsSent)
+	}
+}
+
+func TestRunUnderLoadCancel(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var signals []bool
+	load := LoadFunc(func(loaded bool) error {
+		signals = append(signals, loaded)
+		return nil
+	})
+	_, err = p.RunUnderLoad(ctx, load, time.Second, time.Second)
+	if err == nil {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+	if err != context.Canceled {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if len(signals) != 0 {
+		t.Errorf("Expected no load, got %v", signals)
+	}
+}
+
+func TestUDPLoadStop(t *testing.T) {
+	l := &UDPLoad{Addr: "127.0.0.1:9"}
+	// Not started
+	AssertNoError(t, l.Stop())
+	AssertNoError(t, l.Start())
+	AssertNoError(t, l.Stop())
+	AssertNoError(t, l.Stop())
+}
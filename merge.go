@@ -0,0 +1,364 @@
+package ping
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// statisticsVersion is the version of the binary format of Statistics.
+const statisticsVersion = 1
+
+// Merge adds the results of other to s, so that the statistics of several
+// pingers probing the same target, possibly in different processes, can be
+// combined. Round-trip times are merged exactly while both sides retain
+// them, and through their histograms otherwise, in which case the outcome
+// of each echo request is dropped but the PacketsLost after ProbeTimeout are
+// still added up.
+func (s *Statistics) Merge(other *Statistics) {
+	s.PacketsSent += other.PacketsSent
+	s.PacketsRecv += other.PacketsRecv
+	s.PacketsRecvDuplicates += other.PacketsRecvDuplicates
+	s.PacketsRecvErrors += other.PacketsRecvErrors
+	s.PacketsRecvCorrupted += other.PacketsRecvCorrupted
+	s.PacketsLost += other.PacketsLost
+	s.PacketLoss = packetLoss(s.PacketsSent, s.PacketsRecv)
+	if s.Addr == "" {
+		s.Addr, s.IPAddr = other.Addr, other.IPAddr
+	}
+
+	if s.Histogram == nil && other.Histogram == nil {
+		rtts := append(append([]time.Duration(nil), s.Rtts...), other.Rtts...)
+		merged := statistics(s.PacketsSent, s.PacketsRecv, rtts)
+		s.Rtts = merged.Rtts
+		s.MinRtt, s.MaxRtt = merged.MinRtt, merged.MaxRtt
+		s.AvgRtt, s.StdDevRtt = merged.AvgRtt, merged.StdDevRtt
+		s.Probes = append(append([]ProbeResult(nil), s.Probes...), other.Probes...)
+	} else {
+		stream := s.stream()
+		stream.merge(other.stream())
+		s.Rtts = nil
+		s.Histogram = nil
+		s.Probes = nil
+		stream.fill(s)
+	}
+	// Statistics without percentiles, like a zero value, take those of other
+	merged := s.Percentiles
+	if len(merged) == 0 {
+		merged = other.Percentiles
+	}
+	var percentiles []float64
+	for _, p := range merged {
+		percentiles = append(percentiles, p.Percentile)
+	}
+	s.setPercentiles(percentiles)
+
+	weight := func(d time.Duration, n int) float64 { return float64(d) * float64(n) }
+	if recv := s.PacketsRecv; recv > 0 {
+		s.Jitter = time.Duration((weight(s.Jitter, recv-other.PacketsRecv) +
+			weight(other.Jitter, other.PacketsRecv)) / float64(recv))
+	}
+	if sent := s.PacketsSent; sent > 0 {
+		s.AvgSendError = time.Duration((weight(s.AvgSendError, sent-other.PacketsSent) +
+			weight(other.AvgSendError, other.PacketsSent)) / float64(sent))
+	}
+	if other.MaxSendError > s.MaxSendError {
+		s.MaxSendError = other.MaxSendError
+	}
+	s.ProbesMissed += other.ProbesMissed
+	// The pingers send concurrently
+	s.SendRate += other.SendRate
+	s.Sizes = mergeSizes(s.Sizes, other.Sizes)
+	s.RateLimitedLoss += other.RateLimitedLoss
+	if other.RateLimit > s.RateLimit {
+		s.RateLimit = other.RateLimit
+	}
+	if s.TimeToFirstReply == 0 ||
+		(other.TimeToFirstReply != 0 && other.TimeToFirstReply < s.TimeToFirstReply) {
+		s.TimeToFirstReply = other.TimeToFirstReply
+	}
+}
+
+// stream returns the round-trip times of s as an rttStream.
+func (s *Statistics) stream() *rttStream {
+	stream := &rttStream{}
+	if s.Histogram == nil {
+		for _, rtt := range s.Rtts {
+			stream.add(rtt)
+		}
+		return stream
+	}
+	for _, b := range s.Histogram {
+		stream.Count += b.Count
+		stream.Buckets[bucket(b.UpperBound)] += b.Count
+	}
+	stream.Mean = float64(s.AvgRtt)
+	stream.M2 = float64(s.StdDevRtt) * float64(s.StdDevRtt) * float64(stream.Count)
+	stream.Min, stream.Max = s.MinRtt, s.MaxRtt
+	return stream
+}
+
+// merge adds the round-trip times of other to s, combining the means and
+// variances as in Chan et al.'s parallel algorithm.
+func (s *rttStream) merge(other *rttStream) {
+	if other.Count == 0 {
+		return
+	}
+	if s.Count == 0 {
+		*s = *other
+		return
+	}
+	n := s.Count + other.Count
+	delta := other.Mean - s.Mean
+	s.M2 += other.M2 + delta*delta*float64(s.Count)*float64(other.Count)/float64(n)
+	s.Mean += delta * float64(other.Count) / float64(n)
+	s.Count = n
+	if other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if other.Max > s.Max {
+		s.Max = other.Max
+	}
+	for i, c := range other.Buckets {
+		s.Buckets[i] += c
+	}
+}
+
+// MarshalBinary encodes the statistics in a stable, versioned binary format,
+// to be exchanged between processes and decoded with UnmarshalBinary.
+func (s *Statistics) MarshalBinary() ([]byte, error) {
+	b := []byte{statisticsVersion}
+	var ipaddr string
+	if s.IPAddr != nil {
+		ipaddr = s.IPAddr.String()
+	}
+	for _, str := range []string{s.Addr, ipaddr, s.RAddr} {
+		b = binary.AppendUvarint(b, uint64(len(str)))
+		b = append(b, str...)
+	}
+	for _, v := range []int64{
+		int64(s.PacketsSent), int64(s.PacketsRecv),
+		int64(s.PacketsRecvDuplicates), int64(s.PacketsRecvErrors),
+		int64(s.PacketsRecvCorrupted), int64(s.PacketsLost),
+		int64(s.ProbesMissed), int64(s.RateLimitedLoss),
+		int64(s.MinRtt), int64(s.MaxRtt), int64(s.AvgRtt), int64(s.StdDevRtt),
+		int64(s.MedianRtt), int64(s.Jitter),
+		int64(s.AvgSendError), int64(s.MaxSendError), int64(s.TimeToFirstReply),
+	} {
+		b = binary.AppendVarint(b, v)
+	}
+	b = binary.BigEndian.AppendUint64(b, math.Float64bits(s.RateLimit))
+	b = binary.BigEndian.AppendUint64(b, math.Float64bits(s.SendRate))
+
+	b = binary.AppendUvarint(b, uint64(len(s.Rtts)))
+	for _, rtt := range s.Rtts {
+		b = binary.AppendVarint(b, int64(rtt))
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.Histogram)))
+	for _, h := range s.Histogram {
+		b = binary.AppendVarint(b, int64(h.UpperBound))
+		b = binary.AppendUvarint(b, uint64(h.Count))
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.Percentiles)))
+	for _, p := range s.Percentiles {
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(p.Percentile))
+		b = binary.AppendVarint(b, int64(p.Rtt))
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.Probes)))
+	for _, pr := range s.Probes {
+		for _, v := range []int64{int64(pr.Seq), unixNano(pr.Sent),
+			unixNano(pr.Received), int64(pr.Rtt)} {
+			b = binary.AppendVarint(b, v)
+		}
+		lost := byte(0)
+		if pr.Lost {
+			lost = 1
+		}
+		b = append(b, lost)
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.Sizes)))
+	for _, size := range s.Sizes {
+		for _, v := range []int64{int64(size.Size), int64(size.PacketsSent),
+			int64(size.PacketsRecv), int64(size.MinRtt), int64(size.AvgRtt), int64(size.MaxRtt)} {
+			b = binary.AppendVarint(b, v)
+		}
+	}
+	return b, nil
+}
+
+var errShortStatistics = errors.New("Truncated statistics")
+
+// UnmarshalBinary decodes statistics encoded by MarshalBinary.
+func (s *Statistics) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return errShortStatistics
+	}
+	if b[0] != statisticsVersion {
+		return fmt.Errorf("Unsupported statistics version %d", b[0])
+	}
+	d := decoder{b: b[1:]}
+
+	var out Statistics
+	out.Addr = d.string()
+	if ipaddr := d.string(); ipaddr != "" && d.err == nil {
+		addr, err := net.ResolveIPAddr("ip", ipaddr)
+		if err != nil {
+			return err
+		}
+		out.IPAddr = addr
+	}
+	out.RAddr = d.string()
+	for _, v := range []*int{&out.PacketsSent, &out.PacketsRecv,
+		&out.PacketsRecvDuplicates, &out.PacketsRecvErrors,
+		&out.PacketsRecvCorrupted, &out.PacketsLost,
+		&out.ProbesMissed, &out.RateLimitedLoss} {
+		*v = int(d.varint())
+	}
+	for _, v := range []*time.Duration{&out.MinRtt, &out.MaxRtt, &out.AvgRtt,
+		&out.StdDevRtt, &out.MedianRtt, &out.Jitter,
+		&out.AvgSendError, &out.MaxSendError, &out.TimeToFirstReply} {
+		*v = time.Duration(d.varint())
+	}
+	out.RateLimit = math.Float64frombits(d.uint64())
+	out.SendRate = math.Float64frombits(d.uint64())
+
+	if n := d.len(); n > 0 {
+		out.Rtts = make([]time.Duration, n)
+		for i := range out.Rtts {
+			out.Rtts[i] = time.Duration(d.varint())
+		}
+	}
+	if n := d.len(); n > 0 {
+		out.Histogram = make([]HistogramBucket, n)
+		for i := range out.Histogram {
+			out.Histogram[i].UpperBound = time.Duration(d.varint())
+			out.Histogram[i].Count = int(d.uvarint())
+		}
+	}
+	if n := d.len(); n > 0 {
+		out.Percentiles = make([]PercentileRtt, n)
+		for i := range out.Percentiles {
+			out.Percentiles[i].Percentile = math.Float64frombits(d.uint64())
+			out.Percentiles[i].Rtt = time.Duration(d.varint())
+		}
+	}
+	if n := d.len(); n > 0 {
+		out.Probes = make([]ProbeResult, n)
+		for i := range out.Probes {
+			pr := &out.Probes[i]
+			pr.Seq = int(d.varint())
+			pr.Sent = fromUnixNano(d.varint())
+			pr.Received = fromUnixNano(d.varint())
+			pr.Rtt = time.Duration(d.varint())
+			pr.Lost = d.byte() == 1
+		}
+	}
+	if n := d.len(); n > 0 {
+		out.Sizes = make([]SizeStatistics, n)
+		for i := range out.Sizes {
+			size := &out.Sizes[i]
+			size.Size = int(d.varint())
+			size.PacketsSent = int(d.varint())
+			size.PacketsRecv = int(d.varint())
+			size.MinRtt = time.Duration(d.varint())
+			size.AvgRtt = time.Duration(d.varint())
+			size.MaxRtt = time.Duration(d.varint())
+			size.setLoss()
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	out.PacketLoss = packetLoss(out.PacketsSent, out.PacketsRecv)
+	*s = out
+	return nil
+}
+
+// decoder reads the fields of encoded statistics, remembering the first
+// error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if len(d.b) < 8 {
+		d.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) < 1 {
+		d.fail()
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+// len reads a length, which can't exceed the remaining bytes.
+func (d *decoder) len() int {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.len()
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errShortStatistics
+	}
+	d.b = nil
+}
+
+// unixNano returns t in nanoseconds since the epoch, 0 for the zero time.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,210 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func rttsOf(ms ...int) []time.Duration {
+	var rtts []time.Duration
+	for _, m := range ms {
+		rtts = append(rtts, time.Duration(m)*time.Millisecond)
+	}
+	return rtts
+}
+
+func TestMerge(t *testing.T) {
+	a := statistics(4, 3, rttsOf(10, 20, 30))
+	a.ProbesMissed = 1
+	b := statistics(2, 2, rttsOf(5, 50))
+	b.ProbesMissed = 2
+	// The jitter of separate series is averaged
+	jitter := (3*a.Jitter + 2*b.Jitter) / 5
+	a.Merge(b)
+
+	expected := statistics(6, 5, rttsOf(10, 20, 30, 5, 50))
+	expected.ProbesMissed = 3
+	expected.Jitter = jitter
+	if !reflect.DeepEqual(a, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, a)
+	}
+}
+
+func TestMergeIntoZero(t *testing.T) {
+	b := statistics(2, 2, rttsOf(5, 50))
+	b.Addr = "127.0.0.1"
+	s := &Statistics{}
+	s.Merge(b)
+	if len(s.Percentiles) != len(DefaultPercentiles) {
+		t.Errorf("Expected the percentiles of %v, got %v", b.Percentiles, s.Percentiles)
+	}
+	if !reflect.DeepEqual(s, b) {
+		t.Errorf("Expected %+v, got %+v", b, s)
+	}
+}
+
+func TestMergeHistogram(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.MemoryBudget = 8
+	all := rttsOf(10, 20, 30, 40)
+	for _, rtt := range all[:3] {
+		p.recordRtt(rtt)
+	}
+	p.PacketsSent, p.PacketsRecv = 3, 3
+	a := p.Statistics()
+	if a.Histogram == nil {
+		t.Fatalf("Expected a histogram")
+	}
+
+	a.Merge(statistics(2, 1, all[3:]))
+	expected := statistics(5, 4, all)
+	if a.PacketsSent != 5 || a.PacketsRecv != 4 || a.PacketLoss != expected.PacketLoss {
+		t.Errorf("Expected %v/%v, got %v/%v", expected.PacketsRecv,
+			expected.PacketsSent, a.PacketsRecv, a.PacketsSent)
+	}
+	if a.Rtts != nil {
+		t.Errorf("Expected no Rtts, got %v", a.Rtts)
+	}
+	if a.MinRtt != expected.MinRtt || a.MaxRtt != expected.MaxRtt {
+		t.Errorf("Expected %v-%v, got %v-%v", expected.MinRtt, expected.MaxRtt,
+			a.MinRtt, a.MaxRtt)
+	}
+	for _, d := range [][2]time.Duration{
+		{expected.AvgRtt, a.AvgRtt},
+		{expected.StdDevRtt, a.StdDevRtt},
+	} {
+		if diff := d[0] - d[1]; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("Expected %v, got %v", d[0], d[1])
+		}
+	}
+	count := 0
+	for _, b := range a.Histogram {
+		count += b.Count
+	}
+	if count != 4 {
+		t.Errorf("Expected %v, got %v", 4, count)
+	}
+}
+
+// lossyStatistics returns the statistics of 4 echo requests, 2 of them
+// answered in 10ms and 2 declared lost after their ProbeTimeout, retained
+// within budget.
+func lossyStatistics(t *testing.T, budget int) *Statistics {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.MemoryBudget = budget
+	p.ProbeTimeout = time.Second
+	start := time.Unix(0, 0)
+	for seq := 0; seq < 4; seq++ {
+		p.trackSent(seq, start)
+		p.PacketsSent++
+	}
+	for seq := 0; seq < 4; seq += 2 {
+		p.trackReply(seq, start.Add(10*time.Millisecond), 10*time.Millisecond)
+		p.PacketsRecv++
+		p.recordRtt(10 * time.Millisecond)
+	}
+	p.expire(start.Add(2 * time.Second))
+	return p.Statistics()
+}
+
+func TestMergeLost(t *testing.T) {
+	// A histogram merged with round-trip times, and the other way around
+	for _, budgets := range [][2]int{{8, 0}, {0, 8}} {
+		a := lossyStatistics(t, budgets[0])
+		b := lossyStatistics(t, budgets[1])
+		if (a.Histogram == nil) == (b.Histogram == nil) {
+			t.Fatalf("Expected a histogram on one side only")
+		}
+		a.Merge(b)
+		if a.PacketsSent != 8 || a.PacketsRecv != 4 || a.PacketsLost != 4 {
+			t.Errorf("Expected %v/%v/%v, got %v/%v/%v", 8, 4, 4,
+				a.PacketsSent, a.PacketsRecv, a.PacketsLost)
+		}
+		if a.PacketLoss != 50 {
+			t.Errorf("Expected %v, got %v", 50, a.PacketLoss)
+		}
+		if a.Rtts != nil || a.Probes != nil {
+			t.Errorf("Expected no Rtts nor Probes, got %v and %v", a.Rtts, a.Probes)
+		}
+		count := 0
+		for _, h := range a.Histogram {
+			count += h.Count
+		}
+		if count != 4 || a.AvgRtt != 10*time.Millisecond {
+			t.Errorf("Expected %v round-trip times of %v, got %v of %v", 4,
+				10*time.Millisecond, count, a.AvgRtt)
+		}
+	}
+}
+
+func TestMergeNothingSent(t *testing.T) {
+	a := statistics(0, 0, nil)
+	if a.PacketLoss != 0 {
+		t.Errorf("Expected %v, got %v", 0, a.PacketLoss)
+	}
+	a.Merge(statistics(0, 0, nil))
+	if a.PacketLoss != 0 {
+		t.Errorf("Expected %v, got %v", 0, a.PacketLoss)
+	}
+
+	b, err := a.MarshalBinary()
+	AssertNoError(t, err)
+	var decoded Statistics
+	AssertNoError(t, decoded.UnmarshalBinary(b))
+	if decoded.PacketLoss != 0 {
+		t.Errorf("Expected %v, got %v", 0, decoded.PacketLoss)
+	}
+
+	w := newStatWindow(4)
+	if s := w.statistics(); s.PacketLoss != 0 {
+		t.Errorf("Expected %v, got %v", 0, s.PacketLoss)
+	}
+}
+
+func TestStatisticsBinary(t *testing.T) {
+	raw := statistics(4, 3, rttsOf(10, 20, 30))
+	raw.Addr = "localhost"
+	raw.IPAddr = &net.IPAddr{IP: net.ParseIP("::1")}
+	raw.RateLimit = 12.5
+	raw.MaxSendError = time.Millisecond
+	raw.PacketsRecvDuplicates = 2
+	raw.PacketsLost = 1
+	raw.PacketsRecvErrors = 3
+	raw.PacketsRecvCorrupted = 4
+	raw.SendRate = 9.5
+	raw.Sizes = []SizeStatistics{
+		{Size: 8, PacketsSent: 2, PacketsRecv: 1, PacketLoss: 50,
+			MinRtt: time.Millisecond, AvgRtt: time.Millisecond, MaxRtt: time.Millisecond},
+	}
+	sent := time.Unix(1500000000, 0)
+	raw.Probes = []ProbeResult{
+		{Seq: 0, Sent: sent, Received: sent.Add(10 * time.Millisecond), Rtt: 10 * time.Millisecond},
+		{Seq: 1, Sent: sent.Add(time.Second), Lost: true},
+	}
+
+	histogram := statistics(3, 3, nil)
+	stream := &rttStream{}
+	for _, rtt := range rttsOf(10, 20, 30) {
+		stream.add(rtt)
+	}
+	stream.fill(histogram)
+
+	for _, s := range []*Statistics{raw, histogram} {
+		b, err := s.MarshalBinary()
+		AssertNoError(t, err)
+		decoded := &Statistics{}
+		AssertNoError(t, decoded.UnmarshalBinary(b))
+		if !reflect.DeepEqual(decoded, s) {
+			t.Errorf("Expected %+v, got %+v", s, decoded)
+		}
+
+		AssertError(t, decoded.UnmarshalBinary(b[:len(b)-1]), "truncated")
+		b[0] = 0
+		AssertError(t, decoded.UnmarshalBinary(b), "version 0")
+	}
+}
@@ -0,0 +1,226 @@
+// Package mobile is a gomobile-friendly wrapper of the ping package, for
+// network diagnostic apps on Android and iOS. Its API only uses the types
+// gomobile bind supports.
+//
+// Apps can't open raw sockets, so pings are sent with the unprivileged ICMP
+// sockets both platforms provide to apps. Where these are unavailable, the
+// pinger falls back to measuring the time to open a TCP connection, except
+// in a pingminimal build.
+package mobile
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sparrc/go-ping"
+)
+
+// Probing modes.
+const (
+	// ModeAuto uses ICMP, or TCP when ICMP sockets are unavailable.
+	ModeAuto = "auto"
+
+	// ModeICMP only uses ICMP.
+	ModeICMP = "icmp"
+
+	// ModeTCP only uses TCP.
+	ModeTCP = "tcp"
+)
+
+// Listener receives the results of a Pinger.
+type Listener interface {
+	// OnReply is called for every reply, with its round-trip time in
+	// milliseconds.
+	OnReply(seq int, rttMillis float64)
+
+	// OnFinish is called when the pinger finishes.
+	OnFinish(stats *Statistics)
+}
+
+// Statistics are the results of a Pinger, with times in milliseconds.
+type Statistics struct {
+	Addr        string
+	Protocol    string
+	PacketsSent int
+	PacketsRecv int
+	PacketLoss  float64
+	MinRtt      float64
+	AvgRtt      float64
+	MaxRtt      float64
+	StdDevRtt   float64
+}
+
+// Pinger pings a host.
+type Pinger struct {
+	host     string
+	mode     string
+	count    int
+	interval time.Duration
+	timeout  time.Duration
+	tcpPort  int
+	listener Listener
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewPinger returns a Pinger for host, a DNS name or IP address. It sends
+// one probe per second until stopped, over ICMP or TCP port 443.
+func NewPinger(host string) *Pinger {
+	return &Pinger{
+		host:     host,
+		mode:     ModeAuto,
+		count:    -1,
+		interval: time.Second,
+		timeout:  100000 * time.Second,
+		tcpPort:  443,
+	}
+}
+
+// SetMode sets the probing mode, one of ModeAuto, ModeICMP and ModeTCP.
+func (p *Pinger) SetMode(mode string) error {
+	switch mode {
+	case ModeAuto, ModeICMP, ModeTCP:
+		p.mode = mode
+		return nil
+	}
+	return errors.New("Unknown mode " + mode)
+}
+
+// SetCount sets the number of replies after which the pinger stops. A
+// negative count pings until stopped.
+func (p *Pinger) SetCount(count int) {
+	p.count = count
+}
+
+// SetIntervalMillis sets the time between probes.
+func (p *Pinger) SetIntervalMillis(ms int64) {
+	p.interval = time.Duration(ms) * time.Millisecond
+}
+
+// SetTimeoutMillis sets the time after which the pinger stops, regardless of
+// how many replies were received.
+func (p *Pinger) SetTimeoutMillis(ms int64) {
+	p.timeout = time.Duration(ms) * time.Millisecond
+}
+
+// SetTCPPort sets the port probed over TCP.
+func (p *Pinger) SetTCPPort(port int) {
+	p.tcpPort = port
+}
+
+// SetListener sets the listener receiving the results.
+func (p *Pinger) SetListener(l Listener) {
+	p.listener = l
+}
+
+// Run runs the pinger, and returns its statistics. This is a blocking
+// function that will exit when it's done or stopped.
+func (p *Pinger) Run() (*Statistics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	p.mu.Lock()
+	p.cancel = cancel
+	p.mu.Unlock()
+
+	var stats *Statistics
+	var err error
+	if p.mode != ModeTCP {
+		var unavailable bool
+		stats, unavailable, err = p.runICMP(ctx)
+		if unavailable && p.mode == ModeAuto {
+			stats, err = p.runTCP(ctx)
+		}
+	} else {
+		stats, err = p.runTCP(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.listener != nil {
+		p.listener.OnFinish(stats)
+	}
+	return stats, nil
+}
+
+// Stop stops the pinger.
+func (p *Pinger) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
+// runICMP pings the host over ICMP. It reports whether ICMP sockets are
+// unavailable.
+func (p *Pinger) runICMP(ctx context.Context) (*Statistics, bool, error) {
+	pinger, err := p.newPinger(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	pinger.SetPrivileged(false)
+	if err := pinger.Listen(); err != nil {
+		return nil, true, err
+	}
+	stats, err := p.run(ctx, pinger, ModeICMP)
+	return stats, false, err
+}
+
+// runTCP probes the host by timing TCP connections. A refused connection
+// counts as a reply, as the host answered.
+func (p *Pinger) runTCP(ctx context.Context) (*Statistics, error) {
+	pinger, err := p.newPinger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := pinger.SetProtocol("tcp"); err != nil {
+		return nil, err
+	}
+	pinger.SetPort(p.tcpPort)
+	return p.run(ctx, pinger, ModeTCP)
+}
+
+// newPinger returns a ping.Pinger with the options of p.
+func (p *Pinger) newPinger(ctx context.Context) (*ping.Pinger, error) {
+	pinger, err := ping.NewPinger(ctx, p.host)
+	if err != nil {
+		return nil, err
+	}
+	pinger.Count = p.count
+	pinger.Interval = p.interval
+	return pinger, nil
+}
+
+// run runs pinger, reporting its replies to the listener, and returns its
+// statistics.
+func (p *Pinger) run(ctx context.Context, pinger *ping.Pinger, protocol string) (*Statistics, error) {
+	pinger.OnRecv = func(pkt *ping.Packet) {
+		if p.listener != nil {
+			p.listener.OnReply(pkt.Seq, millis(pkt.Rtt))
+		}
+	}
+	pinger.OnError = func(error) {}
+	if err := pinger.Run(ctx); err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+
+	s := pinger.Statistics()
+	return &Statistics{
+		Addr:        s.Addr,
+		Protocol:    protocol,
+		PacketsSent: s.PacketsSent,
+		PacketsRecv: s.PacketsRecv,
+		PacketLoss:  s.PacketLoss,
+		MinRtt:      millis(s.MinRtt),
+		AvgRtt:      millis(s.AvgRtt),
+		MaxRtt:      millis(s.MaxRtt),
+		StdDevRtt:   millis(s.StdDevRtt),
+	}, nil
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
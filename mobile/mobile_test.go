@@ -0,0 +1,109 @@
+package mobile
+
+import (
+	"net"
+	"testing"
+
+	"github.com/sparrc/go-ping"
+)
+
+type recorder struct {
+	replies []int
+	stats   *Statistics
+}
+
+func (r *recorder) OnReply(seq int, rttMillis float64) {
+	r.replies = append(r.replies, seq)
+}
+
+func (r *recorder) OnFinish(stats *Statistics) {
+	r.stats = stats
+}
+
+func TestTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	p := NewPinger("127.0.0.1")
+	if err := p.SetMode(ModeTCP); err != nil {
+		t.Fatal(err)
+	}
+	p.SetTCPPort(l.Addr().(*net.TCPAddr).Port)
+	p.SetCount(3)
+	p.SetIntervalMillis(10)
+	r := &recorder{}
+	p.SetListener(r)
+	stats, err := p.Run()
+	if ping.MinimalSyscalls {
+		if err != ping.ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ping.ErrMinimalSyscalls, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.replies) != 3 {
+		t.Fatalf("Expected %v, got %v", 3, len(r.replies))
+	}
+	if r.stats != stats {
+		t.Errorf("Expected OnFinish to receive the statistics")
+	}
+	if stats.Protocol != ModeTCP || stats.PacketsSent != 3 || stats.PacketsRecv != 3 {
+		t.Errorf("Expected 3/3 TCP replies, got %+v", stats)
+	}
+	if stats.MinRtt <= 0 || stats.MinRtt > stats.MaxRtt {
+		t.Errorf("Expected valid round-trip times, got %+v", stats)
+	}
+}
+
+func TestTCPRefused(t *testing.T) {
+	// A closed port answers with a reset
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	p := NewPinger("127.0.0.1")
+	p.SetMode(ModeTCP)
+	p.SetTCPPort(port)
+	p.SetCount(1)
+	stats, err := p.Run()
+	if ping.MinimalSyscalls {
+		if err != ping.ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ping.ErrMinimalSyscalls, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PacketsRecv != 1 {
+		t.Errorf("Expected %v, got %v", 1, stats.PacketsRecv)
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	p := NewPinger("127.0.0.1")
+	if err := p.SetMode("udp"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+	if p.mode != ModeAuto {
+		t.Errorf("Expected %v, got %v", ModeAuto, p.mode)
+	}
+}
@@ -0,0 +1,198 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MultiPinger runs a set of named Pingers concurrently. Pingers can be added
+// and removed while it is running.
+type MultiPinger struct {
+	mu       sync.Mutex
+	pingers  map[string]*Pinger
+	finished map[*Pinger]chan bool
+	running  bool
+	ctx      context.Context
+	wg       sync.WaitGroup
+	done     chan bool
+	once     sync.Once
+}
+
+// NewMultiPinger returns an empty MultiPinger.
+func NewMultiPinger() *MultiPinger {
+	return &MultiPinger{
+		pingers:  make(map[string]*Pinger),
+		finished: make(map[*Pinger]chan bool),
+		done:     make(chan bool),
+	}
+}
+
+// Add adds the pinger p under name, and starts it if the MultiPinger is
+// running. Names must be unique.
+func (m *MultiPinger) Add(name string, p *Pinger) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pingers[name]; ok {
+		return fmt.Errorf("Pinger %s already exists", name)
+	}
+	m.pingers[name] = p
+	if m.running {
+		m.start(p)
+	}
+	return nil
+}
+
+// Remove stops and removes the pinger added under name, waits for it to
+// finish, and returns it. It returns nil if there is no such pinger.
+func (m *MultiPinger) Remove(name string) *Pinger {
+	m.mu.Lock()
+	p, ok := m.pingers[name]
+	finished := m.finished[p]
+	delete(m.pingers, name)
+	delete(m.finished, p)
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	p.Stop()
+	if finished != nil {
+		<-finished
+	}
+	return p
+}
+
+// Replace replaces the pinger added under name with p, or adds p if there is
+// none, and returns the pinger replaced. The name is never missing in
+// between, and Run keeps running. If the MultiPinger is running, the old
+// pinger is stopped and finishes before p starts, and prepare, unless nil, is
+// called in between, for instance to carry the statistics over to p.
+func (m *MultiPinger) Replace(name string, p *Pinger, prepare func(old, p *Pinger)) *Pinger {
+	m.mu.Lock()
+	old := m.pingers[name]
+	finished := m.finished[old]
+	delete(m.finished, old)
+	m.pingers[name] = p
+	m.mu.Unlock()
+	if old != nil {
+		old.Stop()
+		if finished != nil {
+			<-finished
+		}
+	}
+	if prepare != nil {
+		prepare(old, p)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running && m.pingers[name] == p {
+		m.start(p)
+	}
+	return old
+}
+
+// Pinger returns the pinger added under name, or nil if there is none.
+func (m *MultiPinger) Pinger(name string) *Pinger {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pingers[name]
+}
+
+// Names returns the sorted names of the pingers.
+func (m *MultiPinger) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedNames(m.pingers)
+}
+
+func sortedNames(pingers map[string]*Pinger) []string {
+	names := make([]string, 0, len(pingers))
+	for name := range pingers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Listen opens the sockets of all the pingers, as Pinger.Listen does. Pingers
+// added afterwards open their own socket when they start.
+func (m *MultiPinger) Listen() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range sortedNames(m.pingers) {
+		if err := m.pingers[name].Listen(); err != nil {
+			return fmt.Errorf("Pinger %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Run runs all the pingers, and those added while it runs, with ctx. This is
+// a blocking function that will exit when Stop is called or ctx is done, in
+// which case it returns the context's error, once all the pingers finished.
+// It keeps running when the pingers finish or are all removed, and when it
+// has none to start with, so that they can be added later.
+func (m *MultiPinger) Run(ctx context.Context) error {
+	m.mu.Lock()
+	m.running = true
+	m.ctx = ctx
+	for _, p := range m.pingers {
+		m.start(p)
+	}
+	m.mu.Unlock()
+
+	var err error
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	m.mu.Lock()
+	m.running = false
+	for _, p := range m.pingers {
+		p.Stop()
+	}
+	m.finished = make(map[*Pinger]chan bool)
+	m.mu.Unlock()
+	m.wg.Wait()
+	return err
+}
+
+// start starts p, m.mu held, unless it is already running.
+func (m *MultiPinger) start(p *Pinger) {
+	if _, ok := m.finished[p]; ok {
+		return
+	}
+	finished := make(chan bool)
+	m.finished[p] = finished
+	m.wg.Add(1)
+	ctx := m.ctx
+	go func() {
+		defer m.wg.Done()
+		defer close(finished)
+		if err := p.Run(ctx); err != nil && ctx.Err() == nil && p.ctx.Err() == nil {
+			p.handleError(err)
+		}
+	}()
+}
+
+// Stop stops all the pingers and makes Run return.
+func (m *MultiPinger) Stop() {
+	m.once.Do(func() {
+		close(m.done)
+	})
+}
+
+// Statistics returns the statistics of every pinger, by name.
+func (m *MultiPinger) Statistics() map[string]*Statistics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]*Statistics, len(m.pingers))
+	for name, p := range m.pingers {
+		stats[name] = p.Statistics()
+	}
+	return stats
+}
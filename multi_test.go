@@ -0,0 +1,119 @@
+package ping
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runMulti runs m until its pingers finished, and returns the error of Run.
+func runMulti(t *testing.T, m *MultiPinger, pingers ...*Pinger) error {
+	var wg sync.WaitGroup
+	for _, p := range pingers {
+		wg.Add(1)
+		p.OnFinish = func(*Statistics) {
+			wg.Done()
+		}
+	}
+	done := make(chan error)
+	go func() {
+		done <- m.Run(context.Background())
+	}()
+	wg.Wait()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Run to keep running once the pingers finished, got %v", err)
+	default:
+	}
+	m.Stop()
+	return <-done
+}
+
+func TestMultiPinger(t *testing.T) {
+	ctx := context.Background()
+	m := NewMultiPinger()
+	var pingers []*Pinger
+	for _, host := range []string{"127.0.0.1", "127.0.0.2"} {
+		p, err := NewPinger(ctx, host)
+		AssertNoError(t, err)
+		p.SetPrivileged(true)
+		p.Count = 2
+		p.Interval = 50 * time.Millisecond
+		p.Timeout = time.Second
+		if err := p.Listen(); err != nil {
+			t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+		}
+		AssertNoError(t, m.Add(host, p))
+		pingers = append(pingers, p)
+	}
+	AssertError(t, m.Add("127.0.0.1", m.Pinger("127.0.0.1")), "duplicate")
+
+	AssertNoError(t, runMulti(t, m, pingers...))
+
+	stats := m.Statistics()
+	if len(stats) != 2 {
+		t.Fatalf("Expected %v, got %v", 2, len(stats))
+	}
+	for name, s := range stats {
+		if s.PacketsSent == 0 {
+			t.Errorf("%s: Expected packets to be sent", name)
+		}
+	}
+
+	if p := m.Remove("127.0.0.2"); p == nil {
+		t.Errorf("Expected to remove 127.0.0.2")
+	}
+	if p := m.Remove("127.0.0.2"); p != nil {
+		t.Errorf("Expected 127.0.0.2 to be gone")
+	}
+	if names := m.Names(); len(names) != 1 || names[0] != "127.0.0.1" {
+		t.Errorf("Expected [127.0.0.1], got %v", names)
+	}
+}
+
+func TestMultiPingerEmpty(t *testing.T) {
+	m := NewMultiPinger()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+
+	// Pingers added to an empty MultiPinger are started, and removing
+	// them all doesn't end it
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.Interval = 10 * time.Millisecond
+	started := make(chan bool, 1)
+	p.OnSend = func(*Packet) {
+		select {
+		case started <- true:
+		default:
+		}
+	}
+	p.SetPrivileged(true)
+	if err := p.Listen(); err != nil {
+		cancel()
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	AssertNoError(t, m.Add("a", p))
+	select {
+	case <-started:
+	case err := <-done:
+		t.Fatalf("Expected Run to keep running, got %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the pinger to be started")
+	}
+	m.Remove("a")
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Run to keep running, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
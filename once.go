@@ -0,0 +1,73 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoReply is returned by PingOnce when the echo request isn't answered
+// in time.
+var ErrNoReply = errors.New("No reply received")
+
+// PingOnce sends a single echo request and waits for its reply, up to the
+// ProbeTimeout of the pinger, or its Timeout if there is none. It returns
+// the reply, the ICMPError received instead, ErrNoReply if nothing came back,
+// or the error of ctx if it is done first. It uses the socket opened by
+// Listen, if any, like Run, but doesn't change the statistics of the pinger
+// nor call its callbacks. This is a blocking function.
+func (p *Pinger) PingOnce(ctx context.Context) (*Packet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	timeout := p.ProbeTimeout
+	if timeout <= 0 {
+		timeout = p.Timeout
+	}
+	q := p.clone()
+	q.conn, p.conn = p.conn, nil
+	q.Count = 1
+	q.Timeout = timeout
+	// Never due before the timeout
+	q.Interval = 2 * timeout
+	q.rate, q.flood, q.adaptive = 0, false, false
+	q.resolveInterval, q.resolveAfter = 0, 0
+
+	var reply *Packet
+	var failed error
+	q.OnRecv = func(pkt *Packet) {
+		reply = pkt
+	}
+	q.OnRecvError = func(e *ICMPError) {
+		failed = e
+		q.Stop()
+	}
+	q.OnError = func(err error) {
+		var e *ICMPError
+		if failed == nil && !errors.As(err, &e) {
+			failed = err
+			q.Stop()
+		}
+	}
+	if err := q.Run(ctx); err != nil {
+		return nil, err
+	}
+	if reply != nil {
+		return reply, nil
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	return nil, ErrNoReply
+}
+
+// Once resolves host and pings it once, waiting up to timeout for the
+// reply, as PingOnce. It sends an unprivileged echo request.
+func Once(ctx context.Context, host string, timeout time.Duration) (*Packet, error) {
+	p, err := NewPinger(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	p.ProbeTimeout = timeout
+	return p.PingOnce(ctx)
+}
@@ -0,0 +1,77 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestPingOnce(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	pkt, err := p.PingOnce(context.Background())
+	if err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if pkt.Rtt <= 0 || pkt.IPAddr.String() != "127.0.0.1" {
+		t.Errorf("Expected a reply from 127.0.0.1, got %+v", pkt)
+	}
+	if p.PacketsSent != 0 {
+		t.Errorf("Expected %v, got %v", 0, p.PacketsSent)
+	}
+}
+
+func TestPingOnceNoReply(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	// Nothing answers
+	p.conn = newDialConn("ip4:icmp", func(context.Context, net.Addr, []byte) *dialAnswer {
+		return nil
+	})
+	p.ProbeTimeout = 50 * time.Millisecond
+	start := time.Now()
+	if _, err := p.PingOnce(context.Background()); err != ErrNoReply {
+		t.Errorf("Expected %v, got %v", ErrNoReply, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to wait for the probe timeout, took %v", elapsed)
+	}
+	if p.conn != nil {
+		t.Errorf("Expected the socket to be used, got %v", p.conn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Once(ctx, "127.0.0.1", time.Second); err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestPingOnceError(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	// Every echo request is answered by a Destination Unreachable
+	p.conn = newDialConn("ip4:icmp", func(ctx context.Context, dst net.Addr, b []byte) *dialAnswer {
+		quoted := append(make([]byte, ipv4.HeaderLen), b[:icmpHeaderLen]...)
+		quoted[0] = 0x45
+		copy(quoted[16:20], net.ParseIP("127.0.0.1").To4())
+		m, err := (&icmp.Message{
+			Type: ipv4.ICMPTypeDestinationUnreachable, Code: 1, Body: &icmp.DstUnreach{Data: quoted},
+		}).Marshal(nil)
+		if err != nil {
+			return nil
+		}
+		return &dialAnswer{b: m, addr: &net.IPAddr{IP: net.ParseIP("192.0.2.1")}}
+	})
+	_, err = p.PingOnce(context.Background())
+	var e *ICMPError
+	if !errors.As(err, &e) || e.RAddr != "192.0.2.1" {
+		t.Errorf("Expected an ICMP error from 192.0.2.1, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	timestampBodyLen = 16
+	msPerDay         = 24 * 60 * 60 * 1000
+)
+
+// OWDStatistics holds one-way delay estimates computed from ICMP Timestamp
+// exchanges.
+type OWDStatistics struct {
+	// Forward are the statistics of the delays from this host to the target.
+	Forward *Statistics
+
+	// Backward are the statistics of the delays from the target back to this
+	// host.
+	Backward *Statistics
+
+	// Offset is the clock offset of the target relative to this host used to
+	// correct the timestamps.
+	Offset time.Duration
+
+	// EstimatedOffset is the clock offset estimated from the exchanges
+	// assuming symmetric paths, as NTP does. It is only meaningful when the
+	// paths are known to be symmetric, and is reported for reference.
+	EstimatedOffset time.Duration
+}
+
+// EstimateOWD sends count ICMP Timestamp requests, one every Interval, and
+// estimates the one-way delays in each direction from the replies. offset is
+// the clock offset of the target relative to this host, zero if both clocks
+// are synchronized. ICMP timestamps have millisecond resolution.
+//
+// Timestamp requests are answered by most kernels as well as by a Responder
+// with Timestamps enabled. This requires an IPv4 target and super-user
+// privileges. This is a blocking function, interrupted when ctx is done. It
+// is not available in a pingminimal build, as it opens its own socket.
+func (p *Pinger) EstimateOWD(ctx context.Context, count int, offset time.Duration) (*OWDStatistics, error) {
+	if MinimalSyscalls {
+		return nil, ErrMinimalSyscalls
+	}
+	if !p.ipv4 {
+		return nil, errors.New("ICMP timestamps are only available over IPv4")
+	}
+
+	conn, err := p.listenICMP(ipv4Proto["ip"], false)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer p.closeOnDone(ctx, conn)()
+
+	var forward, backward []time.Duration
+	var estimated time.Duration
+	bytes := make([]byte, 512)
+	for seq := 0; seq < count; seq++ {
+		if err := p.contextErr(ctx); err != nil {
+			return nil, err
+		}
+
+		originate := time.Now()
+		b := make([]byte, timestampBodyLen)
+		binary.BigEndian.PutUint16(b[0:2], uint16(p.id))
+		binary.BigEndian.PutUint16(b[2:4], uint16(seq))
+		binary.BigEndian.PutUint32(b[4:8], msSinceMidnight(originate))
+		msg, err := (&icmp.Message{
+			Type: ipv4.ICMPTypeTimestamp, Code: 0,
+			Body: &icmp.RawBody{Data: b},
+		}).Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.WriteTo(msg, p.ipaddr); err != nil {
+			if ctxErr := p.contextErr(ctx); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+
+		deadline := originate.Add(p.Interval)
+		conn.SetReadDeadline(deadline)
+		for {
+			n, _, err := conn.ReadFrom(bytes)
+			if err != nil {
+				break
+			}
+			arrival := time.Now()
+			recv, transmit, ok := p.parseTimestampReply(bytes[:n], seq)
+			if !ok {
+				continue
+			}
+
+			orig := msSinceMidnight(originate)
+			forward = append(forward, msDiff(recv, orig)-offset)
+			backward = append(backward, msDiff(msSinceMidnight(arrival), transmit)+offset)
+			estimated += (msDiff(recv, orig) + msDiff(transmit, msSinceMidnight(arrival))) / 2
+			p.sleepContext(ctx, deadline)
+			break
+		}
+	}
+
+	s := &OWDStatistics{
+		Forward:  statistics(count, len(forward), forward),
+		Backward: statistics(count, len(backward), backward),
+		Offset:   offset,
+	}
+	for _, stats := range []*Statistics{s.Forward, s.Backward} {
+		stats.Addr = p.addr
+		stats.IPAddr = p.ipaddr
+	}
+	if len(forward) > 0 {
+		s.EstimatedOffset = estimated / time.Duration(len(forward))
+	}
+	return s, nil
+}
+
+// parseTimestampReply returns the receive and transmit timestamps of b if it
+// is a reply to our timestamp request seq.
+func (p *Pinger) parseTimestampReply(b []byte, seq int) (recv, transmit uint32, ok bool) {
+	m, err := icmp.ParseMessage(protocolICMP, b)
+	if err != nil || m.Type != ipv4.ICMPTypeTimestampReply {
+		return 0, 0, false
+	}
+	body, ok := m.Body.(*icmp.RawBody)
+	if !ok || len(body.Data) < timestampBodyLen {
+		return 0, 0, false
+	}
+	if int(binary.BigEndian.Uint16(body.Data[0:2])) != p.id ||
+		int(binary.BigEndian.Uint16(body.Data[2:4])) != seq {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(body.Data[8:12]),
+		binary.BigEndian.Uint32(body.Data[12:16]), true
+}
+
+// timestampReply returns the reply to the ICMP timestamp request body b
+// received at received and transmitted at transmit, or nil if b is not a
+// valid request.
+func timestampReply(b []byte, received, transmit time.Time) []byte {
+	if len(b) < timestampBodyLen {
+		return nil
+	}
+	data := append([]byte(nil), b[:timestampBodyLen]...)
+	binary.BigEndian.PutUint32(data[8:12], msSinceMidnight(received))
+	binary.BigEndian.PutUint32(data[12:16], msSinceMidnight(transmit))
+	out, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeTimestampReply, Code: 0,
+		Body: &icmp.RawBody{Data: data},
+	}).Marshal(nil)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// msSinceMidnight returns t as an ICMP timestamp, in milliseconds since
+// midnight UT.
+func msSinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight) / time.Millisecond)
+}
+
+// msDiff returns a-b for two ICMP timestamps, accounting for the wrap at
+// midnight.
+func msDiff(a, b uint32) time.Duration {
+	d := (int64(a) - int64(b)) % msPerDay
+	if d >= msPerDay/2 {
+		d -= msPerDay
+	} else if d < -msPerDay/2 {
+		d += msPerDay
+	}
+	return time.Duration(d) * time.Millisecond
+}
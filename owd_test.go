@@ -0,0 +1,117 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestMsDiff(t *testing.T) {
+	tests := []struct {
+		A, B     uint32
+		Expected time.Duration
+	}{
+		{1000, 500, 500 * time.Millisecond},
+		{500, 1000, -500 * time.Millisecond},
+		{10, msPerDay - 10, 20 * time.Millisecond},
+		{msPerDay - 10, 10, -20 * time.Millisecond},
+	}
+
+	for _, set := range tests {
+		if d := msDiff(set.A, set.B); d != set.Expected {
+			t.Errorf("Expected %v, got %v", set.Expected, d)
+		}
+	}
+}
+
+func TestEstimateOWD(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.Interval = 100 * time.Millisecond
+
+	s, err := p.EstimateOWD(context.Background(), 3, 0)
+	if MinimalSyscalls {
+		if err != ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Skipf("Can't send ICMP timestamp requests, skipping: %s", err)
+	}
+	if s.Forward.PacketsSent != 3 {
+		t.Errorf("Expected %v, got %v", 3, s.Forward.PacketsSent)
+	}
+	if s.Forward.PacketsRecv == 0 {
+		t.Skipf("No timestamp replies from 127.0.0.1")
+	}
+	if s.Forward.MaxRtt > 10*time.Millisecond {
+		t.Errorf("Expected a forward delay under 10ms on localhost, got %v",
+			s.Forward.MaxRtt)
+	}
+
+	// Interrupted, waiting for a reply
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	p.Interval = 10 * time.Second
+	start := time.Now()
+	_, err = p.EstimateOWD(ctx, 3, 0)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected EstimateOWD to be interrupted, took %v", elapsed)
+	}
+}
+
+func TestResponderTimestamps(t *testing.T) {
+	r, err := NewResponder("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	r.Timestamps = true
+	r.Delay = 200 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	conn, err := p.listenICMP(ipv4Proto["ip"], false)
+	AssertNoError(t, err)
+	defer conn.Close()
+	b := make([]byte, timestampBodyLen)
+	binary.BigEndian.PutUint16(b[0:2], uint16(p.id))
+	msg, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeTimestamp, Code: 0,
+		Body: &icmp.RawBody{Data: b},
+	}).Marshal(nil)
+	AssertNoError(t, err)
+	_, err = conn.WriteTo(msg, p.ipaddr)
+	AssertNoError(t, err)
+
+	// The kernel may answer as well, at once
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	bytes := make([]byte, 512)
+	for {
+		n, _, err := conn.ReadFrom(bytes)
+		if err != nil {
+			t.Fatalf("Expected a delayed timestamp reply, got %s", err)
+		}
+		recv, transmit, ok := p.parseTimestampReply(bytes[:n], 0)
+		if !ok {
+			continue
+		}
+		// Stamped once sent, with millisecond resolution
+		if d := msDiff(transmit, recv); d >= 190*time.Millisecond {
+			if d > 300*time.Millisecond {
+				t.Errorf("Expected a transmit timestamp %v after the receive one, got %v", r.Delay, d)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package ping
+
+import "fmt"
+
+// PayloadGenerator produces the payloads of echo requests and validates the
+// payloads of their replies, to carry custom data such as application
+// correlation IDs. Payloads follow the timestamp the pinger puts at the start
+// of the data of each request.
+type PayloadGenerator interface {
+	// Payload returns the payload of echo request seq.
+	Payload(seq int) []byte
+
+	// Validate checks the payload of the reply to echo request seq. Replies
+	// failing validation are reported to OnError and not counted.
+	Validate(seq int, payload []byte) error
+}
+
+// maxPacketSize is the size of the largest IP packet, the one the replies
+// are read with when their payloads come from a PayloadGenerator.
+const maxPacketSize = 65535
+
+// SetPayloadGenerator makes the pinger send the payloads of g instead of
+// padding the requests to their size, which then doesn't limit them. Use nil
+// to restore the padding. It only applies to ICMP echo requests.
+func (p *Pinger) SetPayloadGenerator(g PayloadGenerator) {
+	p.payload = g
+}
+
+// CorruptReplyError is reported to OnError for a reply whose payload isn't
+// the one of its echo request, mangled or spoofed along the path. Such
+// replies are counted in PacketsRecvCorrupted rather than PacketsRecv.
+type CorruptReplyError struct {
+	// Seq is the sequence number of the reply.
+	Seq int
+
+	// RAddr is the address the reply was received from.
+	RAddr string
+
+	// Err is how the payload differs.
+	Err error
+}
+
+func (e *CorruptReplyError) Error() string {
+	return fmt.Sprintf("Invalid payload in reply %d: %s", e.Seq, e.Err)
+}
+
+func (e *CorruptReplyError) Unwrap() error {
+	return e.Err
+}
+
+// SetPayload makes the pinger pad its echo requests to their size with
+// pattern repeated, as ping -p, rather than with ones. Nil or empty restores
+// the ones. Either padding is checked in the replies, see
+// CorruptReplyError. A PayloadGenerator takes precedence over it.
+func (p *Pinger) SetPayload(pattern []byte) {
+	if len(pattern) == 0 {
+		pattern = nil
+	}
+	p.pattern = append([]byte(nil), pattern...)
+}
+
+// Payload returns the pattern set by SetPayload.
+func (p *Pinger) Payload() []byte {
+	return p.pattern
+}
+
+// padding returns the n bytes padding the echo requests after their
+// timestamp.
+func (p *Pinger) padding(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		if p.pattern != nil {
+			b[i] = p.pattern[i%len(p.pattern)]
+		} else {
+			b[i] = 1
+		}
+	}
+	return b
+}
+
+// validatePayload checks the payload of the reply to echo request seq
+// against the one the pinger sent.
+func (p *Pinger) validatePayload(seq int, payload []byte) error {
+	if p.payload != nil {
+		return p.payload.Validate(seq, payload)
+	}
+	expected := p.padding(p.sizeOf(seq) - timeSliceLength)
+	if len(payload) != len(expected) {
+		return fmt.Errorf("%d bytes instead of %d", len(payload), len(expected))
+	}
+	for i := range payload {
+		if payload[i] != expected[i] {
+			return fmt.Errorf("Byte %d is %#x instead of %#x", i, payload[i], expected[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type correlationPayload struct {
+	invalid bool
+}
+
+func (c correlationPayload) Payload(seq int) []byte {
+	return []byte(fmt.Sprintf("req-%d", seq))
+}
+
+func (c correlationPayload) Validate(seq int, payload []byte) error {
+	if c.invalid || string(payload) != fmt.Sprintf("req-%d", seq) {
+		return errors.New("unexpected correlation ID")
+	}
+	return nil
+}
+
+func TestPayloadGenerator(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetPayloadGenerator(correlationPayload{})
+	p.Count = 2
+	p.Interval = 10 * time.Millisecond
+	p.Timeout = time.Second
+	var payloads []string
+	p.OnRecv = func(pkt *Packet) {
+		payloads = append(payloads, string(pkt.Payload))
+	}
+	var errs []error
+	p.OnError = func(err error) {
+		errs = append(errs, err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't ping 127.0.0.1, skipping: %s", err)
+	}
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 replies, got %v (errors %v)", payloads, errs)
+	}
+	AssertEqualStrings(t, "req-0", payloads[0])
+	AssertEqualStrings(t, "req-1", payloads[1])
+
+	p, err = NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetPayloadGenerator(correlationPayload{invalid: true})
+	p.Count = 1
+	p.Timeout = 200 * time.Millisecond
+	errs = nil
+	p.OnError = func(err error) {
+		errs = append(errs, err)
+	}
+	p.Run(context.Background())
+	if p.PacketsRecv != 0 {
+		t.Errorf("Expected %v, got %v", 0, p.PacketsRecv)
+	}
+	if len(errs) == 0 {
+		t.Errorf("Expected the invalid payload to be reported")
+	}
+}
+
+// largePayload is a PayloadGenerator of payloads of size bytes.
+type largePayload struct {
+	size int
+}
+
+func (l largePayload) Payload(seq int) []byte {
+	return bytes.Repeat([]byte{byte(seq)}, l.size)
+}
+
+func (l largePayload) Validate(seq int, payload []byte) error {
+	if !bytes.Equal(payload, l.Payload(seq)) {
+		return fmt.Errorf("%d bytes instead of %d", len(payload), l.size)
+	}
+	return nil
+}
+
+func TestLargePayloadGenerator(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetPayloadGenerator(largePayload{size: 2000})
+	p.Count = 1
+	p.Timeout = time.Second
+	var errs []error
+	p.OnError = func(err error) {
+		errs = append(errs, err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't ping 127.0.0.1, skipping: %s", err)
+	}
+	if p.PacketsRecv != 1 || p.PacketsRecvCorrupted != 0 {
+		t.Errorf("Expected 1 reply, got %v and %v corrupted (errors %v)",
+			p.PacketsRecv, p.PacketsRecvCorrupted, errs)
+	}
+}
+
+func TestSetPayload(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetSize(timeSliceLength + 5)
+	p.SetPayload([]byte{0xab, 0xcd})
+	p.Count = 1
+	p.Timeout = time.Second
+	var payload []byte
+	p.OnRecv = func(pkt *Packet) {
+		payload = pkt.Payload
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't ping 127.0.0.1, skipping: %s", err)
+	}
+	AssertEqualStrings(t, "abcdabcdab", fmt.Sprintf("%x", payload))
+
+	p.SetPayload(nil)
+	if p.Payload() != nil || p.padding(2)[1] != 1 {
+		t.Errorf("Expected the padding of ones, got %v", p.padding(2))
+	}
+}
+
+func TestCorruptReply(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetSize(timeSliceLength + 4)
+	p.SetPayload([]byte("sig!"))
+	probe, _, err := p.prober.Marshal(p, 0)
+	AssertNoError(t, err)
+	p.trackSent(0, time.Now())
+
+	// A reply with its signature mangled
+	reply := append([]byte(nil), probe...)
+	reply[0] = 0
+	reply[len(reply)-1] = '?'
+	err = p.processPacket(&packet{bytes: reply, nbytes: len(reply), rAddr: "192.0.2.1"})
+	var corrupt *CorruptReplyError
+	if !errors.As(err, &corrupt) || corrupt.Seq != 0 || corrupt.RAddr != "192.0.2.1" {
+		t.Fatalf("Expected a corrupt reply from 192.0.2.1, got %v", err)
+	}
+	AssertEqualStrings(t, "Invalid payload in reply 0: Byte 3 is 0x3f instead of 0x21", err.Error())
+	s := p.Statistics()
+	if s.PacketsRecvCorrupted != 1 || s.PacketsRecv != 0 {
+		t.Errorf("Expected 1 corrupted reply and none received, got %v and %v",
+			s.PacketsRecvCorrupted, s.PacketsRecv)
+	}
+
+	// Truncated
+	err = p.processPacket(&packet{bytes: reply, nbytes: len(reply) - 2})
+	AssertError(t, err, "truncated reply")
+	if p.PacketsRecvCorrupted != 2 {
+		t.Errorf("Expected %v, got %v", 2, p.PacketsRecvCorrupted)
+	}
+}
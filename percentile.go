@@ -0,0 +1,93 @@
+package ping
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultPercentiles are the round-trip time percentiles computed by default.
+var DefaultPercentiles = []float64{90, 95, 99}
+
+// PercentileRtt is a percentile of the round-trip times.
+type PercentileRtt struct {
+	// Percentile is the percentile, between 0 and 100.
+	Percentile float64
+
+	// Rtt is the round-trip time below which Percentile percent of the
+	// round-trip times fall.
+	Rtt time.Duration
+}
+
+// Percentile returns the given percentile, between 0 and 100, of the
+// round-trip times, interpolating between the closest ones. Once the
+// pinger's MemoryBudget is exceeded, it is approximated by the upper bound
+// of the histogram bucket it falls in.
+func (s *Statistics) Percentile(percentile float64) time.Duration {
+	if s.Histogram != nil {
+		return s.histogramPercentile(percentile)
+	}
+	if len(s.Rtts) == 0 {
+		return 0
+	}
+	rtts := append([]time.Duration(nil), s.Rtts...)
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	rank := math.Max(0, math.Min(100, percentile)) / 100 * float64(len(rtts)-1)
+	lo := int(rank)
+	if lo == len(rtts)-1 {
+		return rtts[lo]
+	}
+	frac := rank - float64(lo)
+	return rtts[lo] + time.Duration(frac*float64(rtts[lo+1]-rtts[lo]))
+}
+
+func (s *Statistics) histogramPercentile(percentile float64) time.Duration {
+	total := 0
+	for _, b := range s.Histogram {
+		total += b.Count
+	}
+	rank := int(math.Ceil(percentile / 100 * float64(total)))
+	n := 0
+	for _, b := range s.Histogram {
+		n += b.Count
+		if n >= rank {
+			if b.UpperBound > s.MaxRtt {
+				return s.MaxRtt
+			}
+			return b.UpperBound
+		}
+	}
+	return s.MaxRtt
+}
+
+// setPercentiles computes the median and the given percentiles of the
+// round-trip times.
+func (s *Statistics) setPercentiles(percentiles []float64) {
+	s.MedianRtt = s.Percentile(50)
+	s.Percentiles = nil
+	for _, percentile := range percentiles {
+		s.Percentiles = append(s.Percentiles, PercentileRtt{
+			Percentile: percentile,
+			Rtt:        s.Percentile(percentile),
+		})
+	}
+}
+
+// recordJitter updates the jitter with the round-trip time of a reply.
+func (p *Pinger) recordJitter(rtt time.Duration) {
+	if p.PacketsRecv > 1 {
+		p.jitter = smoothJitter(p.jitter, p.lastRtt, rtt)
+	}
+	p.lastRtt = rtt
+}
+
+// smoothJitter returns jitter, in nanoseconds, updated with the difference
+// between the consecutive round-trip times prev and rtt, as the
+// interarrival jitter of RFC 3550.
+func smoothJitter(jitter float64, prev, rtt time.Duration) float64 {
+	d := rtt - prev
+	if d < 0 {
+		d = -d
+	}
+	return jitter + (float64(d)-jitter)/16
+}
@@ -0,0 +1,80 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	s := statistics(5, 5, rttsOf(50, 10, 40, 20, 30))
+	for _, tt := range []struct {
+		percentile float64
+		expected   time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{50, 30 * time.Millisecond},
+		{90, 46 * time.Millisecond},
+		{100, 50 * time.Millisecond},
+		{150, 50 * time.Millisecond},
+	} {
+		if rtt := s.Percentile(tt.percentile); rtt != tt.expected {
+			t.Errorf("Expected p%v %v, got %v", tt.percentile, tt.expected, rtt)
+		}
+	}
+	if s.MedianRtt != 30*time.Millisecond {
+		t.Errorf("Expected %v, got %v", 30*time.Millisecond, s.MedianRtt)
+	}
+	if len(s.Percentiles) != len(DefaultPercentiles) || s.Percentiles[0].Percentile != 90 {
+		t.Errorf("Expected the default percentiles, got %v", s.Percentiles)
+	}
+
+	if rtt := statistics(1, 0, nil).Percentile(50); rtt != 0 {
+		t.Errorf("Expected %v, got %v", 0, rtt)
+	}
+}
+
+func TestPercentileHistogram(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.MemoryBudget = 8
+	p.Percentiles = []float64{99}
+	for i := 1; i <= 100; i++ {
+		p.PacketsRecv++
+		p.recordRtt(time.Duration(i) * time.Millisecond)
+	}
+	s := p.Statistics()
+	// Within the 4 buckets per octave of the histogram
+	for _, tt := range []struct {
+		rtt, expected time.Duration
+	}{
+		{s.MedianRtt, 50 * time.Millisecond},
+		{s.Percentiles[0].Rtt, 99 * time.Millisecond},
+	} {
+		if tt.rtt < tt.expected || tt.rtt > tt.expected*6/5 {
+			t.Errorf("Expected about %v, got %v", tt.expected, tt.rtt)
+		}
+	}
+	if len(s.Percentiles) != 1 || s.Percentiles[0].Rtt > s.MaxRtt {
+		t.Errorf("Expected p99 up to %v, got %v", s.MaxRtt, s.Percentiles)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	rtts := rttsOf(10, 26, 10, 26)
+	for _, rtt := range rtts {
+		p.PacketsRecv++
+		p.recordRtt(rtt)
+		p.recordJitter(rtt)
+	}
+	// 16ms differences, smoothed by 1/16
+	expected := 16 * time.Millisecond * (4096 - 15*15*15) / 4096
+	if d := p.Statistics().Jitter - expected; d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("Expected %v, got %v", expected, p.Statistics().Jitter)
+	}
+	if jitter := statistics(4, 4, rtts).Jitter; jitter != p.Statistics().Jitter {
+		t.Errorf("Expected %v, got %v", p.Statistics().Jitter, jitter)
+	}
+}
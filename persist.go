@@ -0,0 +1,263 @@
+package ping
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// stateVersion is the version of the persisted state format of a Pinger.
+// Version 2 adds the stat window, the size sweep counters and the time to
+// the first reply. States of version 1 are still loaded.
+const stateVersion = 2
+
+// slaStateVersion is the version of the persisted state format of an SLA.
+const slaStateVersion = 1
+
+// pingerState is the persisted state of a Pinger.
+type pingerState struct {
+	Version      int             `json:"version"`
+	Addr         string          `json:"addr"`
+	PacketsSent  int             `json:"packets_sent"`
+	PacketsRecv  int             `json:"packets_recv"`
+	Duplicates   int             `json:"packets_recv_duplicates"`
+	Errors       int             `json:"packets_recv_errors"`
+	Corrupted    int             `json:"packets_recv_corrupted"`
+	PacketsLost  int             `json:"packets_lost"`
+	Rtts         []time.Duration `json:"rtts"`
+	Stream       *rttStream      `json:"stream,omitempty"`
+	State        State           `json:"state"`
+	Sequence     int             `json:"sequence"`
+	ProbesMissed int             `json:"probes_missed"`
+
+	SendErrorCount int           `json:"send_error_count"`
+	SendErrorTotal time.Duration `json:"send_error_total"`
+	SendErrorMax   time.Duration `json:"send_error_max"`
+
+	RateLimit       float64 `json:"rate_limit"`
+	RateLimitedLoss int     `json:"rate_limited_loss"`
+
+	Jitter  float64       `json:"jitter"`
+	LastRtt time.Duration `json:"last_rtt"`
+
+	FirstReply time.Duration `json:"first_reply,omitempty"`
+	WindowSize int           `json:"window_size,omitempty"`
+	Window     []slotState   `json:"window,omitempty"`
+	Sizes      []sizeState   `json:"sizes,omitempty"`
+}
+
+// slotState is the persisted state of an echo request in the stat window.
+type slotState struct {
+	Seq     int           `json:"seq"`
+	Replied bool          `json:"replied"`
+	Rtt     time.Duration `json:"rtt"`
+}
+
+// sizeState is the persisted state of the counters of a size, in size sweep
+// mode.
+type sizeState struct {
+	Size  int           `json:"size"`
+	Sent  int           `json:"sent"`
+	Recv  int           `json:"recv"`
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Total time.Duration `json:"total"`
+}
+
+// Save writes the counters, round-trip times and state of the pinger to w,
+// so that they can be restored with Load after a restart. It must not be
+// called concurrently with Run, except from one of the pinger's callbacks.
+func (p *Pinger) Save(w io.Writer) error {
+	s := &pingerState{
+		Version:      stateVersion,
+		Addr:         p.addr,
+		PacketsSent:  p.PacketsSent,
+		PacketsRecv:  p.PacketsRecv,
+		Duplicates:   p.PacketsRecvDuplicates,
+		Errors:       p.PacketsRecvErrors,
+		Corrupted:    p.PacketsRecvCorrupted,
+		PacketsLost:  p.packetsLost,
+		Rtts:         p.rtts,
+		Stream:       p.stream,
+		State:        p.state,
+		Sequence:     p.sequence,
+		ProbesMissed: p.probesMissed,
+
+		SendErrorCount: p.sendErrorCount,
+		SendErrorTotal: p.sendErrorTotal,
+		SendErrorMax:   p.sendErrorMax,
+
+		RateLimit:       p.rateLimit,
+		RateLimitedLoss: p.rateLimitedLoss,
+
+		Jitter:  p.jitter,
+		LastRtt: p.lastRtt,
+
+		FirstReply: p.firstReply,
+	}
+	if p.window != nil {
+		s.WindowSize = len(p.window.slots)
+		for _, slot := range p.window.slots {
+			if slot.used {
+				s.Window = append(s.Window, slotState{Seq: slot.seq, Replied: slot.replied, Rtt: slot.rtt})
+			}
+		}
+		sort.Slice(s.Window, func(i, j int) bool {
+			return s.Window[i].Seq < s.Window[j].Seq
+		})
+	}
+	for size, c := range p.sizes {
+		s.Sizes = append(s.Sizes, sizeState{Size: size, Sent: c.sent, Recv: c.recv,
+			Min: c.min, Max: c.max, Total: c.total})
+	}
+	sort.Slice(s.Sizes, func(i, j int) bool {
+		return s.Sizes[i].Size < s.Sizes[j].Size
+	})
+	return json.NewEncoder(w).Encode(s)
+}
+
+// Load restores the state written by Save. It must be called before Run, and
+// after SetStatWindow, the stat window being restored in the window of the
+// pinger or else one of the size it was saved with. The state must have been
+// saved for the same address.
+func (p *Pinger) Load(r io.Reader) error {
+	var s pingerState
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return err
+	}
+	if s.Version < 1 || s.Version > stateVersion {
+		return fmt.Errorf("Unsupported state version %d", s.Version)
+	}
+	if s.Addr != p.addr {
+		return fmt.Errorf("State was saved for %s, not %s", s.Addr, p.addr)
+	}
+
+	p.PacketsSent = s.PacketsSent
+	p.PacketsRecv = s.PacketsRecv
+	p.PacketsRecvDuplicates = s.Duplicates
+	p.PacketsRecvErrors = s.Errors
+	p.PacketsRecvCorrupted = s.Corrupted
+	p.packetsLost = s.PacketsLost
+	p.rtts = s.Rtts
+	p.stream = s.Stream
+	p.state = s.State
+	p.sequence = s.Sequence
+	p.lastRecvSent = s.PacketsSent
+	p.probesMissed = s.ProbesMissed
+	p.sendErrorCount = s.SendErrorCount
+	p.sendErrorTotal = s.SendErrorTotal
+	p.sendErrorMax = s.SendErrorMax
+	p.rateLimit = s.RateLimit
+	p.rateLimitedLoss = s.RateLimitedLoss
+	p.analyzed = s.Sequence
+	p.jitter = s.Jitter
+	p.lastRtt = s.LastRtt
+	p.firstReply = s.FirstReply
+
+	if p.window == nil && s.WindowSize > 0 {
+		p.window = newStatWindow(s.WindowSize)
+	}
+	if p.window != nil {
+		p.window = p.window.clone()
+		for _, slot := range s.Window {
+			p.window.send(slot.Seq)
+			if slot.Replied {
+				p.window.reply(slot.Seq, slot.Rtt)
+			}
+		}
+	}
+	p.sizes = nil
+	if len(s.Sizes) > 0 {
+		p.sizes = make(map[int]*sizeCounters, len(s.Sizes))
+		for _, c := range s.Sizes {
+			p.sizes[c.Size] = &sizeCounters{sent: c.Sent, recv: c.Recv,
+				min: c.Min, max: c.Max, total: c.Total}
+		}
+	}
+	return nil
+}
+
+// SaveFile saves the state of the pinger to the file at path, replacing it
+// atomically.
+func (p *Pinger) SaveFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := p.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFile restores the state of the pinger from the file at path. A
+// missing file is not an error, the pinger then starts afresh.
+func (p *Pinger) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.Load(f)
+}
+
+// slaState is the persisted state of an SLA.
+type slaState struct {
+	Version   int          `json:"version"`
+	Current   *SLAReport   `json:"current"`
+	Reports   []*SLAReport `json:"reports"`
+	Down      bool         `json:"down"`
+	DownSince time.Time    `json:"down_since"`
+}
+
+// Save writes the reports of the SLA to w, so that the availability history
+// survives a restart.
+func (s *SLA) Save(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.NewEncoder(w).Encode(&slaState{
+		Version:   slaStateVersion,
+		Current:   s.current,
+		Reports:   s.reports,
+		Down:      s.down,
+		DownSince: s.downSince,
+	})
+}
+
+// Load restores the reports written by Save. The time the process was not
+// running is not counted as downtime, unless the target was down when the
+// state was saved.
+func (s *SLA) Load(r io.Reader) error {
+	var state slaState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return err
+	}
+	if state.Version != slaStateVersion {
+		return fmt.Errorf("Unsupported state version %d", state.Version)
+	}
+	if c := state.Current; c != nil && !c.End.After(c.Start) {
+		return fmt.Errorf("Invalid current period %v-%v", c.Start, c.End)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = state.Current
+	s.reports = state.Reports
+	s.down = state.Down
+	s.downSince = state.DownSince
+	return nil
+}
@@ -0,0 +1,118 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPinger(ctx, "127.0.0.1")
+	AssertNoError(t, err)
+	p.PacketsSent = 3
+	p.PacketsRecv = 2
+	p.sequence = 3
+	p.rtts = []time.Duration{time.Duration(1000), time.Duration(3000)}
+	p.state = StateUp
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	AssertNoError(t, p.SaveFile(path))
+
+	restored, err := NewPinger(ctx, "127.0.0.1")
+	AssertNoError(t, err)
+	AssertNoError(t, restored.LoadFile(path))
+
+	stats := restored.Statistics()
+	if stats.PacketsSent != 3 || stats.PacketsRecv != 2 {
+		t.Errorf("Expected 3 sent and 2 received, got %v and %v",
+			stats.PacketsSent, stats.PacketsRecv)
+	}
+	if stats.AvgRtt != time.Duration(2000) {
+		t.Errorf("Expected %v, got %v", time.Duration(2000), stats.AvgRtt)
+	}
+	if restored.State() != StateUp || restored.sequence != 3 {
+		t.Errorf("Expected up at sequence 3, got %v at %v", restored.State(),
+			restored.sequence)
+	}
+
+	// A missing file starts afresh
+	AssertNoError(t, restored.LoadFile(filepath.Join(dir, "missing.json")))
+
+	// State of another target is rejected
+	other, err := NewPinger(ctx, "127.0.0.2")
+	AssertNoError(t, err)
+	AssertError(t, other.LoadFile(path), "127.0.0.2")
+}
+
+func TestSaveLoadWindow(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPinger(ctx, "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetStatWindow(3)
+	AssertNoError(t, p.SetSizeSweep(16, 32, 16))
+	for seq := 0; seq < 5; seq++ {
+		p.PacketsSent++
+		p.window.send(seq)
+		p.recordSizeSent(seq)
+		if seq != 3 {
+			rtt := time.Duration(seq+1) * time.Millisecond
+			p.PacketsRecv++
+			p.window.reply(seq, rtt)
+			p.recordSizeReply(seq, rtt)
+		}
+	}
+	p.sequence = 5
+	p.firstReply = 42 * time.Millisecond
+	want := p.Statistics()
+
+	var buf bytes.Buffer
+	AssertNoError(t, p.Save(&buf))
+	restored, err := NewPinger(ctx, "127.0.0.1")
+	AssertNoError(t, err)
+	AssertNoError(t, restored.Load(&buf))
+	got := restored.Statistics()
+	if got.PacketsSent != 3 || got.PacketsRecv != 2 || got.AvgRtt != want.AvgRtt ||
+		!reflect.DeepEqual(got.Rtts, want.Rtts) {
+		t.Errorf("Expected the window %+v, got %+v", want, got)
+	}
+	if !reflect.DeepEqual(got.Sizes, want.Sizes) {
+		t.Errorf("Expected %+v, got %+v", want.Sizes, got.Sizes)
+	}
+	if got.TimeToFirstReply != 42*time.Millisecond {
+		t.Errorf("Expected %v, got %v", 42*time.Millisecond, got.TimeToFirstReply)
+	}
+
+	// Version 1 states are still loaded
+	AssertNoError(t, restored.Load(strings.NewReader(`{"version":1,"addr":"127.0.0.1"}`)))
+	AssertError(t, restored.Load(strings.NewReader(`{"version":3,"addr":"127.0.0.1"}`)), "version 3")
+}
+
+func TestSLASaveLoad(t *testing.T) {
+	s := NewSLA(time.Hour)
+	t0 := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.stateChange(t0.Add(15*time.Minute), StateDown)
+	s.stateChange(t0.Add(75*time.Minute), StateUp)
+
+	var buf bytes.Buffer
+	AssertNoError(t, s.Save(&buf))
+
+	restored := NewSLA(time.Hour)
+	AssertNoError(t, restored.Load(&buf))
+	r := restored.report(t0.Add(90 * time.Minute))
+	if len(restored.reports) != 1 {
+		t.Fatalf("Expected %v, got %v", 1, len(restored.reports))
+	}
+	if restored.reports[0].Downtime != 45*time.Minute {
+		t.Errorf("Expected %v, got %v", 45*time.Minute,
+			restored.reports[0].Downtime)
+	}
+	if r.Downtime != 15*time.Minute {
+		t.Errorf("Expected %v, got %v", 15*time.Minute, r.Downtime)
+	}
+}
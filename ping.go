@@ -3,18 +3,18 @@
 //
 // Here is a very simple example that sends & receives 3 packets:
 //
-//  pinger, err := ping.NewPinger("www.google.com")
+//  pinger, err := ping.NewPinger(ctx, "www.google.com")
 //  if err != nil {
 //    panic(err)
 //  }
 //
 //  pinger.Count = 3
-//  pinger.Run() // blocks until finished
+//  pinger.Run(ctx) // blocks until finished
 //  stats := pinger.Statistics() // get send/receive/rtt stats
 //
 // Here is an example that emulates the unix ping command:
 //
-//  pinger, err := ping.NewPinger("www.google.com")
+//  pinger, err := ping.NewPinger(ctx, "www.google.com")
 //  if err != nil {
 //    fmt.Printf("ERROR: %s\n", err.Error())
 //    return
@@ -33,7 +33,7 @@
 //  }
 //
 //  fmt.Printf("PING %s (%s):\n", pinger.Addr(), pinger.IPAddr())
-//  pinger.Run()
+//  pinger.Run(ctx)
 //
 // It sends ICMP packet(s) and waits for a response. If it receives a response,
 // it calls the "receive" callback. When it's finished, it calls the "finish"
@@ -45,12 +45,13 @@ package ping
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"net"
 	"sync"
-	"syscall"
 	"time"
 
 	"golang.org/x/net/icmp"
@@ -71,39 +72,104 @@ var (
 
 // NewPinger returns a new Pinger struct pointer
 func NewPinger(ctx context.Context, addr string) (*Pinger, error) {
-	ipaddr, err := net.ResolveIPAddr("ip", addr)
-	if err != nil {
+	p := newPinger(ctx)
+	if err := p.SetAddr(addr); err != nil {
 		return nil, err
 	}
+	return p, nil
+}
 
-	var ipv4 bool
-	if isIPv4(ipaddr.IP) {
-		ipv4 = true
-	} else if isIPv6(ipaddr.IP) {
-		ipv4 = false
-	}
-
+// newPinger returns a new Pinger with the default options and no target.
+func newPinger(ctx context.Context) *Pinger {
 	return &Pinger{
-		ipaddr:   ipaddr,
-		addr:     addr,
 		Interval: time.Second,
 		Timeout:  time.Second * 100000,
 		Count:    -1,
 
+		DownAfter:    3,
+		ProbeTimeout: time.Second,
+		Percentiles:  DefaultPercentiles,
+
 		id:      rand.Intn(0xffff),
 		network: "udp",
-		ipv4:    ipv4,
 		size:    timeSliceLength,
 
 		ctx: ctx,
 
+		prober: icmpProber{},
+
 		done: make(chan bool),
-	}, nil
+	}
+}
+
+// clone returns a new Pinger with the same target and options as p, but
+// without its callbacks and statistics.
+func (p *Pinger) clone() *Pinger {
+	return &Pinger{
+		ipaddr:   p.ipaddr,
+		addr:     p.addr,
+		Interval: p.Interval,
+		Timeout:  p.Timeout,
+		Count:    p.Count,
+		Debug:    p.Debug,
+
+		Isochronous: p.Isochronous,
+		Windows:     p.Windows,
+
+		DownAfter:    p.DownAfter,
+		AutoPace:     p.AutoPace,
+		ProbeTimeout: p.ProbeTimeout,
+
+		MemoryBudget: p.MemoryBudget,
+		Percentiles:  p.Percentiles,
+		window:       p.window.clone(),
+
+		id:      rand.Intn(0xffff),
+		network: p.network,
+		ipv4:    p.ipv4,
+		source:  p.source,
+		size:    p.size,
+
+		sweepMin:  p.sweepMin,
+		sweepMax:  p.sweepMax,
+		sweepStep: p.sweepStep,
+		ttl:       p.ttl,
+		tos:       p.tos,
+
+		dontFragment: p.dontFragment,
+		iface:        p.iface,
+		dualStack:    p.dualStack,
+		ipOption:     p.ipOption,
+
+		resolveInterval: p.resolveInterval,
+		resolveAfter:    p.resolveAfter,
+		resolver:        p.resolver,
+		resolveNetwork:  p.resolveNetwork,
+
+		rate:          p.rate,
+		burst:         p.burst,
+		flood:         p.flood,
+		adaptive:      p.adaptive,
+		adaptiveFloor: p.adaptiveFloor,
+
+		ctx: p.ctx,
+
+		prober:    p.prober,
+		transport: p.transport,
+		clock:     p.clock,
+		port:      p.port,
+		payload:   p.payload,
+		pattern:   p.pattern,
+		waker:     p.waker,
+
+		done: make(chan bool),
+	}
 }
 
 // Pinger represents ICMP packet sender/receiver
 type Pinger struct {
 	// Interval is the wait time between each packet send. Default is 1s.
+	// SetRate, SetFlood and SetAdaptive pace the echo requests instead.
 	Interval time.Duration
 
 	// Timeout specifies a timeout before ping exits, regardless of how many
@@ -115,6 +181,18 @@ type Pinger struct {
 	// interrupted.
 	Count int
 
+	// Isochronous makes the pinger send every echo request exactly on a fixed
+	// schedule, start + n*Interval, instead of on a ticker. The time each
+	// request was sent late is reported separately from round-trip times,
+	// and slots that are already over when the pinger gets to them are
+	// skipped and counted as missed.
+	Isochronous bool
+
+	// Windows are the periods during which echo requests are sent. Outside
+	// of them probing pauses, until the next window starts. If empty,
+	// probing is always active.
+	Windows []Window
+
 	// Debug runs in debug mode
 	Debug bool
 
@@ -124,17 +202,143 @@ type Pinger struct {
 	// Number of packets received
 	PacketsRecv int
 
-	// rtts is all of the Rtts
-	rtts []time.Duration
+	// Number of duplicate replies received, not counted in PacketsRecv
+	PacketsRecvDuplicates int
+
+	// Number of ICMP error messages received about outstanding echo requests
+	PacketsRecvErrors int
+
+	// Number of replies whose payload isn't the one of their echo request,
+	// not counted in PacketsRecv
+	PacketsRecvCorrupted int
+
+	// MemoryBudget is the maximum number of bytes used to retain round-trip
+	// times and the outcome of each echo request, unanswered ones included.
+	// Once it is exceeded, the round-trip times are aggregated into a
+	// histogram in constant memory instead, and Statistics no longer returns
+	// individual Rtts nor Probes. Zero means no limit.
+	MemoryBudget int
+
+	// Percentiles are the round-trip time percentiles computed by
+	// Statistics, between 0 and 100. Default is DefaultPercentiles.
+	Percentiles []float64
+
+	// rtts is all of the Rtts, stream their aggregate once MemoryBudget is
+	// exceeded
+	rtts   []time.Duration
+	stream *rttStream
+
+	// window is the statistics of the last requests, set by SetStatWindow
+	window *statWindow
+
+	// jitter is the smoothed jitter in nanoseconds, lastRtt the round-trip
+	// time of the last reply
+	jitter  float64
+	lastRtt time.Duration
+
+	// OnSend is called when Pinger sends an echo request
+	OnSend func(*Packet)
 
 	// OnRecv is called when Pinger receives and processes a packet
 	OnRecv func(*Packet)
 
+	// ProbeTimeout is how long the reply to an echo request is waited for
+	// before the request is declared lost. Later replies are ignored. Zero
+	// never declares requests lost. Default is 1s.
+	ProbeTimeout time.Duration
+
+	// OnTimeout is called with the sequence number of an echo request
+	// declared lost
+	OnTimeout func(seq int)
+
+	// OnDuplicate is called when Pinger receives a reply to an echo request
+	// that was already answered
+	OnDuplicate func(*Packet)
+
+	// OnRecvError is called when Pinger receives an ICMP error message about
+	// an outstanding echo request, like Destination Unreachable
+	OnRecvError func(*ICMPError)
+
+	// OnFamily is called by Run in dual-stack mode with the address of the
+	// family chosen, the one then pinged
+	OnFamily func(*net.IPAddr)
+
+	// OnResolve is called when the target hostname, resolved again during
+	// Run, resolves to a new address, which is then pinged
+	OnResolve func(old, new *net.IPAddr)
+
 	// OnFinish is called when Pinger exits
 	OnFinish func(*Statistics)
 
+	// SummaryInterval is the wait time between each OnSummary call. Zero
+	// disables periodic summaries.
+	SummaryInterval time.Duration
+
+	// OnSummary is called every SummaryInterval with the current statistics
+	OnSummary func(*Statistics)
+
+	// DownAfter is the number of consecutive echo requests left unanswered
+	// before the target is considered down. Default is 3.
+	DownAfter int
+
+	// OnStateChange is called when the target goes up or down
+	OnStateChange func(old, new State)
+
+	// OnError is called when sending or processing a packet fails. If it is
+	// not set, errors are printed to stdout.
+	OnError func(error)
+
+	// AutoPace makes the pinger increase Interval when the target appears to
+	// rate limit its replies, so that echo requests are sent below the
+	// detected rate. Paced pingers, see SetRate, aren't slowed down.
+	AutoPace bool
+
+	// OnRateLimit is called when the target appears to rate limit its
+	// replies, with the detected reply rate in replies per second.
+	OnRateLimit func(rate float64)
+
+	// state is the current target state, lastRecvSent the value of
+	// PacketsSent when the last reply was received
+	state        State
+	lastRecvSent int
+
+	// send-time accounting of isochronous mode
+	probesMissed   int
+	sendErrorCount int
+	sendErrorTotal time.Duration
+	sendErrorMax   time.Duration
+
+	// started is when the pinger started, firstReply the time to the first
+	// reply
+	started    time.Time
+	firstReply time.Duration
+
+	waker *waker
+
+	// probes are the outcomes of the last 65536 echo requests by sequence
+	// number, inflight those awaiting a reply in send order, and results
+	// all of them until MemoryBudget, which counts them, is exceeded
+	probes      map[int]*ProbeResult
+	inflight    []*ProbeResult
+	results     []*ProbeResult
+	packetsLost int
+
+	// rate limit detection: the answered sequence numbers not analyzed yet,
+	// the first of them, and the results
+	answered        map[int]bool
+	analyzed        int
+	rateLimit       float64
+	rateLimitedLoss int
+
+	// mu is held by Run while it updates the pinger, except during the
+	// callbacks, locked while it does and running while Run is active
+	mu      sync.Mutex
+	locked  bool
+	running bool
+
 	// stop chan bool
-	done chan bool
+	done     chan bool
+	doneOnce sync.Once
 
 	ctx context.Context
 
@@ -142,18 +346,107 @@ type Pinger struct {
 	rAddr  string
 	addr   string
 
-	ipv4     bool
-	source   string
-	size     int
+	ipv4   bool
+	source string
+	size   int
+	ttl    int
+	tos    byte
+
+	// sweepMin, sweepMax and sweepStep are set by SetSizeSweep, and sizes
+	// accumulate the statistics of each size swept
+	sweepMin  int
+	sweepMax  int
+	sweepStep int
+	sizes     map[int]*sizeCounters
+
+	dontFragment bool
+	iface        *net.Interface
+	dualStack    *dualStack
+	ipOption     IPOption
+
+	// resolved receives the resolutions of the target hostname started
+	// every resolveInterval, or resolveAfter unanswered requests, while
+	// resolving
+	resolveInterval time.Duration
+	resolveAfter    int
+	resolved        chan resolution
+	resolving       bool
+
+	// resolver and resolveNetwork are set by SetResolver and
+	// SetResolveNetwork
+	resolver       Resolver
+	resolveNetwork string
+
+	// rate, burst, flood and adaptive pace the echo requests, see SetRate
+	rate          float64
+	burst         int
+	flood         bool
+	adaptive      bool
+	adaptiveFloor time.Duration
+
+	// sendCount echo requests were sent from sendFirst to sendLast
+	sendCount int
+	sendFirst time.Time
+	sendLast  time.Time
+
 	id       int
 	sequence int
 	network  string
+	port     int
+
+	prober    Prober
+	transport Transport
+	clock     Clock
+	payload   PayloadGenerator
+	pattern   []byte
+
+	// received is the time the packet being processed was received at, as
+	// timestamped by the kernel
+	received time.Time
+
+	// conn is the socket opened by Listen, used by the next Run
+	conn net.PacketConn
+
+	// shared is the socket of the PingerPool the pinger belongs to
+	shared *sharedConn
+}
+
+// Prober implements the transport of the probes sent by a Pinger: ICMP echo
+// requests by default, TWAMP-Light test packets, TCP connections or UDP
+// datagrams. The round-trip times of all transports are recorded alike.
+type Prober interface {
+	// Listen opens the socket probes are sent and received on.
+	Listen(p *Pinger) (net.PacketConn, error)
+
+	// Marshal returns probe seq, stamped with the current time, and its
+	// destination.
+	Marshal(p *Pinger, seq int) ([]byte, net.Addr, error)
+
+	// Parse decodes a packet received on the socket. It returns nil if the
+	// packet is not a reply to one of our probes, and otherwise the
+	// sequence number, round-trip time and payload of the reply, the Pinger
+	// filling in the other fields of the Packet.
+	Parse(p *Pinger, b []byte) (*Packet, error)
 }
 
 type packet struct {
-	bytes  []byte
-	nbytes int
-	rAddr  string
+	bytes   []byte
+	nbytes  int
+	rAddr   string
+	ttl     int
+	tos     int
+	options []byte
+
+	// received is the time the kernel received the packet at, if reported
+	received time.Time
+
+	// err is the error that ended the reads, reported by the loop of Run
+	// rather than by the receiving goroutine, which mustn't call callbacks
+	err error
+
+	// The buffer of bytes, recycled to pool by free
+	pool *sync.Pool
+	buf  *[]byte
 }
 
 // Packet represents a received and processed ICMP echo packet.
@@ -172,6 +465,48 @@ type Packet struct {
 
 	// Seq is the ICMP sequence number.
 	Seq int
+
+	// Ttl is the TTL, or hop limit for IPv6, the reply was received with.
+	// Zero if it is unknown, or for echo requests.
+	Ttl int
+
+	// Tos is the TOS, or traffic class for IPv6, the reply was received
+	// with, its DSCP in the upper 6 bits. Zero if it is unknown: for IPv4, it
+	// is only reported in privileged mode, on Linux, macOS and FreeBSD.
+	Tos int
+
+	// Payload is the data of the reply following the timestamp.
+	Payload []byte
+
+	// Options is the data recorded by the IP option set by SetIPOption, nil
+	// if there is none.
+	Options *IPOptions
+}
+
+// State is the reachability state of the target host.
+type State int
+
+const (
+	// StateUnknown is the state before any reply is received or the target
+	// is declared down.
+	StateUnknown State = iota
+
+	// StateUp means the target is answering echo requests.
+	StateUp
+
+	// StateDown means the last DownAfter echo requests went unanswered.
+	StateDown
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUp:
+		return "up"
+	case StateDown:
+		return "down"
+	default:
+		return "unknown"
+	}
 }
 
 // Statistics represent the stats of a currently running or finished
@@ -183,6 +518,22 @@ type Statistics struct {
 	// PacketsSent is the number of packets sent.
 	PacketsSent int
 
+	// PacketsRecvDuplicates is the number of duplicate replies received, not
+	// counted in PacketsRecv.
+	PacketsRecvDuplicates int
+
+	// PacketsRecvErrors is the number of ICMP error messages received about
+	// the echo requests, like Destination Unreachable.
+	PacketsRecvErrors int
+
+	// PacketsRecvCorrupted is the number of replies whose payload isn't the
+	// one of their echo request, not counted in PacketsRecv.
+	PacketsRecvCorrupted int
+
+	// PacketsLost is the number of packets declared lost so far, after
+	// their ProbeTimeout.
+	PacketsLost int
+
 	// PacketLoss is the percentage of packets lost.
 	PacketLoss float64
 
@@ -195,9 +546,18 @@ type Statistics struct {
 	// Addr is the string address of the host being pinged.
 	Addr string
 
-	// Rtts is all of the round-trip times sent via this pinger.
+	// Rtts is all of the round-trip times sent via this pinger. It is nil
+	// once the pinger's MemoryBudget is exceeded.
 	Rtts []time.Duration
 
+	// Probes is the outcome of each echo request, in send order. It is nil
+	// once the pinger's MemoryBudget is exceeded.
+	Probes []ProbeResult
+
+	// Histogram is the distribution of the round-trip times once the
+	// pinger's MemoryBudget is exceeded, with only the non-empty buckets.
+	Histogram []HistogramBucket
+
 	// MinRtt is the minimum round-trip time sent via this pinger.
 	MinRtt time.Duration
 
@@ -210,6 +570,48 @@ type Statistics struct {
 	// StdDevRtt is the standard deviation of the round-trip times sent via
 	// this pinger.
 	StdDevRtt time.Duration
+
+	// MedianRtt is the median round-trip time sent via this pinger.
+	MedianRtt time.Duration
+
+	// Percentiles are the pinger's Percentiles of the round-trip times.
+	Percentiles []PercentileRtt
+
+	// Jitter is the interarrival jitter of RFC 3550, the smoothed mean
+	// difference between the round-trip times of consecutive replies.
+	Jitter time.Duration
+
+	// RateLimit is the reply rate, in replies per second, the target appears
+	// to limit its replies to. Zero if no rate limiting was detected.
+	RateLimit float64
+
+	// RateLimitedLoss is the number of lost packets attributed to rate
+	// limiting by the target rather than to the network.
+	RateLimitedLoss int
+
+	// TimeToFirstReply is the time from the start of the pinger to the first
+	// reply, zero if none was received.
+	TimeToFirstReply time.Duration
+
+	// ProbesMissed is the number of scheduled echo requests that were
+	// skipped because the pinger fell behind, in isochronous mode.
+	ProbesMissed int
+
+	// AvgSendError is the average time echo requests were sent after their
+	// scheduled time, in isochronous mode.
+	AvgSendError time.Duration
+
+	// MaxSendError is the maximum time an echo request was sent after its
+	// scheduled time, in isochronous mode.
+	MaxSendError time.Duration
+
+	// SendRate is the rate the echo requests were sent at, per second, from
+	// the first to the last one. Zero until two were sent.
+	SendRate float64
+
+	// Sizes are the statistics of each size of echo requests, by size, in
+	// size sweep mode.
+	Sizes []SizeStatistics
 }
 
 // SetIPAddr sets the ip address of the target host.
@@ -234,7 +636,7 @@ func (p *Pinger) IPAddr() *net.IPAddr {
 // SetAddr resolves and sets the ip address of the target host, addr can be a
 // DNS name like "www.google.com" or IP like "127.0.0.1".
 func (p *Pinger) SetAddr(addr string) error {
-	ipaddr, err := net.ResolveIPAddr("ip", addr)
+	ipaddr, err := p.resolveAddr(addr)
 	if err != nil {
 		return err
 	}
@@ -253,6 +655,8 @@ func (p *Pinger) Addr() string {
 // false means pinger will send an "unprivileged" UDP ping.
 // true means pinger will send a "privileged" raw ICMP ping.
 // NOTE: setting to true requires that it be run with super-user privileges.
+// On Windows, unprivileged pings are sent with the ICMP API of the system,
+// and privileged ones require administrator rights.
 func (p *Pinger) SetPrivileged(privileged bool) {
 	if privileged {
 		p.network = "ip"
@@ -266,74 +670,309 @@ func (p *Pinger) Privileged() bool {
 	return p.network == "ip"
 }
 
-// Run runs the pinger. This is a blocking function that will exit when it's
-// done. If Count or Interval are not specified, it will run continuously until
-// it is interrupted.
-func (p *Pinger) Run() {
-	p.run()
+// SetTTL sets the TTL of the echo requests, or their hop limit for IPv6,
+// between 1 and 255. Zero uses the system default. It must be called before
+// Listen or Run, and has no effect on the pingers of a PingerPool, which
+// share a socket opened with the defaults of the system.
+func (p *Pinger) SetTTL(ttl int) {
+	p.ttl = ttl
 }
 
-func (p *Pinger) run() {
-	var conn *icmp.PacketConn
-	if p.ipv4 {
-		if conn = p.listen(ipv4Proto[p.network], p.source); conn == nil {
-			return
+// SetTOS sets the TOS of the echo requests, or their traffic class for
+// IPv6, to mark them with a DSCP in its upper 6 bits, such as 0xb8 for EF,
+// and an ECN codepoint in its lower 2. Zero uses the system default. It must
+// be called before Listen or Run, and has no effect on the pingers of a
+// PingerPool, like SetTTL. The TOS the replies are received with is reported
+// in their Tos.
+func (p *Pinger) SetTOS(tos byte) {
+	p.tos = tos
+}
+
+// TOS returns the TOS set by SetTOS.
+func (p *Pinger) TOS() byte {
+	return p.tos
+}
+
+// SetSize sets the size of the data of the echo requests, at least the 8
+// bytes of their timestamp, like the -s option of ping. It is ignored with
+// a PayloadGenerator, and disables the size sweep set by SetSizeSweep.
+func (p *Pinger) SetSize(size int) {
+	if size < timeSliceLength {
+		size = timeSliceLength
+	}
+	p.size = size
+	p.sweepStep = 0
+}
+
+// Size returns the size of the data of the echo requests.
+func (p *Pinger) Size() int {
+	return p.size
+}
+
+// TTL returns the TTL set by SetTTL.
+func (p *Pinger) TTL() int {
+	return p.ttl
+}
+
+// Run runs the pinger. This is a blocking function that will exit when it's
+// done, when Stop is called, or when ctx or the context of the pinger is
+// done, in which case it returns the context's error. If Count or Interval
+// are not specified, it will run continuously until it is interrupted.
+// Pending reads are interrupted on exit, and OnFinish is called with the
+// final statistics once the socket was opened.
+func (p *Pinger) Run(ctx context.Context) error {
+	p.setRunning(true)
+	defer p.setRunning(false)
+	if p.dualStack != nil && p.shared == nil && p.conn == nil {
+		ipaddr, err := p.chooseFamily(ctx)
+		if ipaddr == nil {
+			return err
 		}
+		p.setFamily(ipaddr)
+		if handler := p.OnFamily; handler != nil {
+			handler(ipaddr)
+		}
+	}
+
+	var conn net.PacketConn
+	var recv chan *packet
+	if p.shared != nil {
+		// The pool owns the socket and receives the replies
+		conn = p.shared.conn
+		recv = p.shared.recv
+		defer p.finish()
 	} else {
-		if conn = p.listen(ipv6Proto[p.network], p.source); conn == nil {
-			return
+		conn = p.conn
+		p.conn = nil
+		if conn == nil {
+			if MinimalSyscalls && p.transport == nil {
+				p.Stop()
+				return fmt.Errorf("Listen must be called before Run: %s",
+					ErrMinimalSyscalls)
+			}
+			var err error
+			if conn, err = p.open(); err != nil {
+				p.Stop()
+				return err
+			}
 		}
+		defer conn.Close()
+		defer p.finish()
+
+		var wg sync.WaitGroup
+		recv = make(chan *packet, 10)
+		wg.Add(1)
+		go p.recvPackets(conn, recv, &wg)
+		defer func() {
+			// Interrupt the pending read
+			conn.SetReadDeadline(time.Now())
+			wg.Wait()
+		}()
 	}
-	defer conn.Close()
-	defer p.finish()
+	defer p.Stop()
+	return p.loop(ctx, conn, recv)
+}
 
-	var wg sync.WaitGroup
-	recv := make(chan *packet, 10)
-	wg.Add(1)
-	go p.recvICMP(conn, recv, &wg)
+func (p *Pinger) loop(ctx context.Context, conn net.PacketConn, recv <-chan *packet) error {
+	defer p.release()
+	clock := p.getClock()
+	start := clock.Now()
+	p.started = start
+	var pace *pacer
+	if p.paced() {
+		pace = p.newPacer(start)
+		defer pace.stop()
+	}
 
-	err := p.sendICMP(conn)
-	if err != nil {
-		fmt.Println(err.Error())
+	timeout := clock.NewTimer(p.Timeout)
+	defer timeout.Stop()
+
+	var interval <-chan time.Time
+	var sched *schedule
+	var ticker Ticker
+	current := p.Interval
+	if pace != nil {
+		interval = pace.timer.C()
+	} else if p.Isochronous {
+		sched = newSchedule(clock, start, p.Interval)
+		defer sched.stop()
+		interval = sched.timer.C()
+	} else {
+		ticker = clock.NewTicker(p.Interval)
+		defer ticker.Stop()
+		interval = ticker.C()
+	}
+
+	var summary <-chan time.Time
+	if p.SummaryInterval > 0 {
+		t := clock.NewTicker(p.SummaryInterval)
+		defer t.Stop()
+		summary = t.C()
 	}
 
-	timeout := time.NewTicker(p.Timeout)
-	interval := time.NewTicker(p.Interval)
+	// expiry fires when the oldest echo request in flight times out
+	expiry := clock.NewTimer(p.ProbeTimeout)
+	defer expiry.Stop()
+
+	var resolve <-chan time.Time
+	if p.shared == nil && (p.resolveInterval > 0 || p.resolveAfter > 0) {
+		p.lock()
+		p.resolved = make(chan resolution, 1)
+		p.resolving = false
+		p.unlock()
+		if p.resolveInterval > 0 {
+			t := clock.NewTicker(p.resolveInterval)
+			defer t.Stop()
+			resolve = t.C()
+		}
+	}
+
+	// The timers are armed first, so that they run from start on the clock
+	if p.active(start) {
+		p.lock()
+		if pace != nil {
+			pace.send(start)
+		}
+		err := p.sendProbe(conn)
+		p.unlock()
+		if err != nil {
+			p.handleError(err)
+		}
+	}
 
 	for {
+		p.lock()
+		now := clock.Now()
+		next := p.expire(now)
+		p.unlock()
+		if !next.IsZero() {
+			expiry.Reset(next.Sub(now))
+		} else {
+			expiry.Stop()
+		}
+
 		select {
 		case <-p.done:
-			wg.Wait()
-			return
-		case <-timeout.C:
-			close(p.done)
-			wg.Wait()
-			return
-		case <-interval.C:
-			err = p.sendICMP(conn)
+			return nil
+		case <-timeout.C():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case <-interval:
+			p.lock()
+			var err error
+			if pace != nil {
+				err = p.sendPaced(conn, pace, recv)
+			} else {
+				err = p.tick(conn, sched)
+			}
+			p.unlock()
 			if err != nil {
-				fmt.Println("FATAL: ", err.Error())
+				p.handleError(err)
+			}
+			if pace == nil && p.Interval != current {
+				// Paced
+				current = p.Interval
+				if sched != nil {
+					sched.setInterval(current)
+				} else {
+					ticker.Reset(current)
+				}
+			}
+		case <-expiry.C():
+		case <-resolve:
+			p.lock()
+			p.resolve()
+			p.unlock()
+		case r := <-p.resolved:
+			p.lock()
+			err := p.updateAddr(r)
+			p.unlock()
+			if err != nil {
+				p.handleError(err)
+			}
+		case <-summary:
+			if handler := p.OnSummary; handler != nil {
+				handler(p.Statistics())
 			}
-		case <-p.ctx.Done():
-			wg.Wait()
-			return
 		case r := <-recv:
+			if r.err != nil {
+				p.handleError(r.err)
+				return nil
+			}
+			p.lock()
+			recvd := p.PacketsRecv
 			err := p.processPacket(r)
+			r.free()
+			if pace != nil && p.PacketsRecv > recvd {
+				pace.answer(clock.Now())
+			}
+			done := p.Count > 0 && p.PacketsRecv >= p.Count
+			p.unlock()
 			if err != nil {
-				fmt.Println("FATAL: ", err.Error())
+				p.handleError(err)
 			}
-		default:
-			if p.Count > 0 && p.PacketsRecv >= p.Count {
-				close(p.done)
-				wg.Wait()
-				return
+			if done {
+				return nil
 			}
 		}
 	}
 }
 
+// Stop stops the pinger. It is safe to call Stop more than once, or after the
+// pinger has finished.
 func (p *Pinger) Stop() {
-	close(p.done)
+	p.doneOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// closeOnDone closes c once ctx or the context of the pinger is done, to
+// interrupt the blocking functions reading from it, until the returned stop
+// function is called.
+func (p *Pinger) closeOnDone(ctx context.Context, c io.Closer) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-p.ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// contextErr returns the error of ctx, or of the context of the pinger.
+func (p *Pinger) contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.ctx.Err()
+}
+
+// sleepContext sleeps until t, or until ctx or the context of the pinger is
+// done.
+func (p *Pinger) sleepContext(ctx context.Context, t time.Time) {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-p.ctx.Done():
+	}
+}
+
+func (p *Pinger) handleError(err error) {
+	handler := p.OnError
+	if handler != nil {
+		p.callback(func() { handler(err) })
+		return
+	}
+	fmt.Println("FATAL: ", err.Error())
 }
 
 func (p *Pinger) finish() {
@@ -344,17 +983,153 @@ func (p *Pinger) finish() {
 	}
 }
 
+// State returns the current reachability state of the target host. It can
+// be called from any goroutine.
+func (p *Pinger) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func (p *Pinger) setState(state State) {
+	if state == p.state {
+		return
+	}
+	old := p.state
+	p.state = state
+	handler := p.OnStateChange
+	if handler != nil {
+		p.callback(func() { handler(old, state) })
+	}
+}
+
+// SnapshotStatistics returns the statistics of the pinger like Statistics.
+//
+// Deprecated: Statistics is safe to call from any goroutine.
+func (p *Pinger) SnapshotStatistics() *Statistics {
+	return p.Statistics()
+}
+
+// Running returns whether Run is active. It can be called from any
+// goroutine.
+func (p *Pinger) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// Outstanding returns the number of echo requests sent and waiting for a
+// reply, neither answered nor declared lost after their ProbeTimeout. It can
+// be called from any goroutine.
+func (p *Pinger) Outstanding() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := p.PacketsSent - p.PacketsRecv - p.packetsLost; n > 0 {
+		return n
+	}
+	return 0
+}
+
+func (p *Pinger) setRunning(running bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = running
+}
+
+// lock locks the pinger for Run while it updates it.
+func (p *Pinger) lock() {
+	p.mu.Lock()
+	p.locked = true
+}
+
+func (p *Pinger) unlock() {
+	p.locked = false
+	p.mu.Unlock()
+}
+
+// release unlocks the pinger if Run still holds it after a panic, so that
+// the deferred calls of Run, setRunning and OnFinish included, don't
+// deadlock.
+func (p *Pinger) release() {
+	if p.locked {
+		p.unlock()
+	}
+}
+
+// callback calls f, calling a callback of the pinger, with the pinger
+// unlocked if Run holds it, so that the callback can call Statistics and the
+// other methods locking it.
+func (p *Pinger) callback(f func()) {
+	if !p.locked {
+		f()
+		return
+	}
+	p.unlock()
+	defer p.lock()
+	f()
+}
+
 // Statistics returns the statistics of the pinger. This can be run while the
-// pinger is running or after it is finished. OnFinish calls this function to
-// get it's finished statistics.
+// pinger is running or after it is finished, from any goroutine, the
+// pinger's callbacks included. OnFinish calls this function to get it's
+// finished statistics. The counters of the pinger, like PacketsSent, must
+// only be read directly by the callbacks of a running pinger.
 func (p *Pinger) Statistics() *Statistics {
-	loss := float64(p.PacketsSent-p.PacketsRecv) / float64(p.PacketsSent) * 100
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats()
+}
+
+// stats returns the statistics of the pinger, locked.
+func (p *Pinger) stats() *Statistics {
+	var s *Statistics
+	if p.window != nil {
+		s = p.window.statistics()
+	} else {
+		s = statistics(p.PacketsSent, p.PacketsRecv, p.rtts)
+	}
+	s.Addr = p.addr
+	s.IPAddr = p.ipaddr
+	if p.stream != nil {
+		p.stream.fill(s)
+	}
+	s.setPercentiles(p.Percentiles)
+	s.Jitter = time.Duration(p.jitter)
+	s.PacketsRecvDuplicates = p.PacketsRecvDuplicates
+	s.PacketsRecvErrors = p.PacketsRecvErrors
+	s.PacketsRecvCorrupted = p.PacketsRecvCorrupted
+	s.PacketsLost = p.packetsLost
+	s.Probes = p.probeResults()
+	s.ProbesMissed = p.probesMissed
+	s.TimeToFirstReply = p.firstReply
+	s.RateLimit = p.rateLimit
+	s.RateLimitedLoss = p.rateLimitedLoss
+	if p.sendErrorCount > 0 {
+		s.AvgSendError = p.sendErrorTotal / time.Duration(p.sendErrorCount)
+		s.MaxSendError = p.sendErrorMax
+	}
+	s.SendRate = p.sendRate()
+	s.Sizes = p.sizeStatistics()
+	return s
+}
+
+// packetLoss returns the percentage of sent packets that weren't received,
+// zero if none was sent.
+func packetLoss(sent, recv int) float64 {
+	if sent <= 0 {
+		return 0
+	}
+	return float64(sent-recv) / float64(sent) * 100
+}
+
+func statistics(sent, recv int, rtts []time.Duration) *Statistics {
+	loss := packetLoss(sent, recv)
 	var min, max, total time.Duration
-	if len(p.rtts) > 0 {
-		min = p.rtts[0]
-		max = p.rtts[0]
+	if len(rtts) > 0 {
+		min = rtts[0]
+		max = rtts[0]
 	}
-	for _, rtt := range p.rtts {
+	for _, rtt := range rtts {
 		if rtt < min {
 			min = rtt
 		}
@@ -364,112 +1139,321 @@ func (p *Pinger) Statistics() *Statistics {
 		total += rtt
 	}
 	s := Statistics{
-		PacketsSent: p.PacketsSent,
-		PacketsRecv: p.PacketsRecv,
+		PacketsSent: sent,
+		PacketsRecv: recv,
 		PacketLoss:  loss,
-		Rtts:        p.rtts,
-		Addr:        p.addr,
-		IPAddr:      p.ipaddr,
+		Rtts:        rtts,
 		MaxRtt:      max,
 		MinRtt:      min,
 	}
-	if len(p.rtts) > 0 {
-		s.AvgRtt = total / time.Duration(len(p.rtts))
+	if len(rtts) > 0 {
+		s.AvgRtt = total / time.Duration(len(rtts))
 		var sumsquares time.Duration
-		for _, rtt := range p.rtts {
+		for _, rtt := range rtts {
 			sumsquares += (rtt - s.AvgRtt) * (rtt - s.AvgRtt)
 		}
 		s.StdDevRtt = time.Duration(math.Sqrt(
-			float64(sumsquares / time.Duration(len(p.rtts)))))
+			float64(sumsquares / time.Duration(len(rtts)))))
+	}
+	s.setPercentiles(DefaultPercentiles)
+	var jitter float64
+	for i := 1; i < len(rtts); i++ {
+		jitter = smoothJitter(jitter, rtts[i-1], rtts[i])
 	}
+	s.Jitter = time.Duration(jitter)
 	return &s
 }
 
-func (p *Pinger) recvICMP(conn *icmp.PacketConn, recv chan<- *packet, wg *sync.WaitGroup) {
+func (p *Pinger) recvPackets(conn net.PacketConn, recv chan<- *packet, wg *sync.WaitGroup) {
 	defer wg.Done()
+	size := p.packetSize()
+	if size < 512 {
+		size = 512
+	}
+	if p.ipOption != IPOptionNone {
+		size += maxIPOptionsLen
+	}
+	if p.payload != nil {
+		// The payloads of a generator aren't bounded by the size
+		size = maxPacketSize
+	}
+	// Replies to echo requests and TWAMP-Light test packets are read in
+	// batches where supported, as they don't keep the bytes they are parsed
+	// from
+	var batch *batchReader
+	if c, ok := conn.(ipConn); ok && batchReads {
+		switch p.prober.(type) {
+		case icmpProber, twampProber:
+			batch = newBatchReader(c, size)
+		}
+	}
 	for {
+		var pkt *packet
+		var rAddr net.Addr
+		var err error
+		if batch != nil {
+			pkt, rAddr, err = batch.read()
+		} else {
+			pkt, rAddr, err = readPacket(conn, make([]byte, size))
+		}
+		if err != nil {
+			select {
+			case <-p.done:
+				// Interrupted by Run
+			case recv <- &packet{err: fmt.Errorf("Error receiving packets: %s", err)}:
+			}
+			return
+		}
+
+		pkt.rAddr = rAddr.String()
 		select {
+		case recv <- pkt:
 		case <-p.done:
 			return
-		default:
-			bytes := make([]byte, 512)
-			conn.SetReadDeadline(time.Now().Add(time.Millisecond * 100))
-			n, rAddr, err := conn.ReadFrom(bytes)
-			if err != nil {
-				if neterr, ok := err.(*net.OpError); ok {
-					if neterr.Timeout() {
-						// Read timeout
-						continue
-					}
-					close(p.done)
-					return
-				}
-			}
-
-			recv <- &packet{bytes: bytes, nbytes: n, rAddr: rAddr.String()}
 		}
 	}
 }
 
 func (p *Pinger) processPacket(recv *packet) error {
-	var bytes []byte
-	var proto int
-	if p.ipv4 {
-		if p.network == "ip" {
-			bytes = ipv4Payload(recv.bytes)
-		} else {
-			bytes = recv.bytes
+	p.received = recv.received
+	defer func() {
+		p.received = time.Time{}
+	}()
+	outPkt, err := p.prober.Parse(p, recv.bytes[:recv.nbytes])
+	var icmpErr *ICMPError
+	if errors.As(err, &icmpErr) {
+		icmpErr.RAddr = recv.rAddr
+		return p.processICMPError(icmpErr)
+	}
+	var corrupt *CorruptReplyError
+	if errors.As(err, &corrupt) {
+		corrupt.RAddr = recv.rAddr
+		p.PacketsRecvCorrupted += 1
+		return corrupt
+	}
+	if err != nil || outPkt == nil {
+		return err
+	}
+	outPkt.Nbytes = recv.nbytes
+	outPkt.IPAddr = p.ipaddr
+	outPkt.RAddr = recv.rAddr
+	outPkt.Ttl = recv.ttl
+	outPkt.Tos = recv.tos
+	if len(recv.options) > 0 {
+		outPkt.Options = parseIPOptions(recv.options)
+	}
+
+	duplicate, late := p.trackReply(outPkt.Seq, p.receiveTime(), outPkt.Rtt)
+	if duplicate {
+		p.PacketsRecvDuplicates += 1
+		if handler := p.OnDuplicate; handler != nil {
+			p.callback(func() { handler(outPkt) })
+		}
+		return nil
+	}
+	if late {
+		// Already declared lost
+		return nil
+	}
+	if p.window != nil {
+		if pr := p.probes[outPkt.Seq&0xffff]; pr != nil {
+			p.window.reply(pr.Seq, outPkt.Rtt)
 		}
-		proto = protocolICMP
-	} else {
-		bytes = recv.bytes
-		proto = protocolIPv6ICMP
 	}
 
-	var m *icmp.Message
-	var err error
-	if m, err = icmp.ParseMessage(proto, bytes[:recv.nbytes]); err != nil {
-		return fmt.Errorf("Error parsing icmp message")
+	if p.PacketsRecv == 0 {
+		p.firstReply = p.now().Sub(p.started)
+	}
+	p.PacketsRecv += 1
+	p.recordSizeReply(outPkt.Seq, outPkt.Rtt)
+	p.lastRecvSent = p.PacketsSent
+	p.setState(StateUp)
+	p.recordReply(outPkt.Seq)
+
+	p.recordRtt(outPkt.Rtt)
+	p.recordJitter(outPkt.Rtt)
+	handler := p.OnRecv
+	if handler != nil {
+		p.callback(func() { handler(outPkt) })
 	}
 
-	if m.Type != ipv4.ICMPTypeEchoReply && m.Type != ipv6.ICMPTypeEchoReply {
-		// Not an echo reply, ignore it
+	return nil
+}
+
+// tick sends the next echo request, unless the pinger is outside of its
+// windows.
+func (p *Pinger) tick(conn net.PacketConn, sched *schedule) error {
+	if sched != nil {
+		return p.sendScheduled(conn, sched)
+	}
+	if !p.active(p.now()) {
 		return nil
 	}
+	return p.sendProbe(conn)
+}
 
-	// Check if reply from same ID
-	body := m.Body.(*icmp.Echo)
-	if body.ID != p.id {
+func (p *Pinger) sendProbe(conn net.PacketConn) error {
+	if p.waker != nil && p.PacketsRecv == 0 {
+		if err := p.waker.wake(); err != nil {
+			p.handleError(err)
+		}
+	}
+
+	bytes, dst, err := p.prober.Marshal(p, p.sequence)
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, err = conn.WriteTo(bytes, dst)
+		if err != nil {
+			if neterr, ok := err.(*net.OpError); ok {
+				if isNoBufferSpace(neterr.Err) {
+					continue
+				}
+			}
+		}
+		now := p.now()
+		p.trackSent(p.sequence, now)
+		if err == nil {
+			p.recordSend(now)
+		}
+		if p.window != nil {
+			p.window.send(p.sequence)
+		}
+		if handler := p.OnSend; handler != nil && err == nil {
+			pkt := &Packet{
+				IPAddr: p.ipaddr,
+				Nbytes: len(bytes),
+				Seq:    p.sequence,
+			}
+			p.callback(func() { handler(pkt) })
+		}
+		p.PacketsSent += 1
+		p.recordSizeSent(p.sequence)
+		p.sequence += 1
+		if p.DownAfter > 0 && p.PacketsSent-p.lastRecvSent > p.DownAfter {
+			p.setState(StateDown)
+		}
+		if unanswered := p.PacketsSent - p.lastRecvSent - 1; p.resolveAfter > 0 &&
+			unanswered > 0 && unanswered%p.resolveAfter == 0 {
+			p.resolve()
+		}
+		p.checkRateLimit()
+		break
+	}
+	if err != nil {
+		return fmt.Errorf("Error sending echo request %d: %w", p.sequence-1, err)
+	}
+	return nil
+}
+
+// Listen opens the socket the pinger sends and receives on, so that Run can
+// be called after the process has dropped the privileges needed to open it,
+// see DropPrivileges. The socket is closed when Run returns.
+func (p *Pinger) Listen() error {
+	if p.conn != nil {
 		return nil
 	}
+	conn, err := p.open()
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	return nil
+}
 
-	outPkt := &Packet{
-		Nbytes: recv.nbytes,
-		IPAddr: p.ipaddr,
-		RAddr:  recv.rAddr,
+func (p *Pinger) open() (net.PacketConn, error) {
+	conn, err := p.prober.Listen(p)
+	if err == ErrUnsupportedPlatform {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error listening for packets: %s", err)
 	}
+	return conn, nil
+}
 
-	switch pkt := m.Body.(type) {
-	case *icmp.Echo:
-		outPkt.Rtt = time.Since(bytesToTime(pkt.Data[:timeSliceLength]))
-		outPkt.Seq = pkt.Seq
-		p.PacketsRecv += 1
-	default:
-		// Very bad, not sure how this can happen
-		return fmt.Errorf("Error, invalid ICMP echo reply. Body type: %T, %s",
-			pkt, pkt)
+// icmpProber sends ICMP echo requests.
+type icmpProber struct{}
+
+func (icmpProber) Listen(p *Pinger) (net.PacketConn, error) {
+	if p.transport != nil {
+		return p.transport.ListenPacket(p)
+	}
+	if useEchoAPI && p.network == "udp" {
+		return listenEchoAPI(p)
+	}
+	proto := ipv6Proto[p.network]
+	if p.ipv4 {
+		proto = ipv4Proto[p.network]
+	}
+	conn, err := p.listenICMP(proto, true)
+	if err != nil {
+		return nil, err
 	}
 
-	p.rtts = append(p.rtts, outPkt.Rtt)
-	handler := p.OnRecv
-	if handler != nil {
-		handler(outPkt)
+	// Report the TTL and traffic class of replies where supported
+	if p.ipv4 {
+		pc := conn.IPv4PacketConn()
+		pc.SetControlMessage(ipv4.FlagTTL, true)
+		if p.ttl > 0 {
+			err = pc.SetTTL(p.ttl)
+		}
+		if err == nil && p.tos > 0 {
+			err = pc.SetTOS(int(p.tos))
+		}
+	} else {
+		pc := conn.IPv6PacketConn()
+		pc.SetControlMessage(ipv6.FlagHopLimit|ipv6.FlagTrafficClass, true)
+		if p.ttl > 0 {
+			err = pc.SetHopLimit(p.ttl)
+		}
+		if err == nil && p.tos > 0 {
+			err = pc.SetTrafficClass(int(p.tos))
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
+	return conn, nil
+}
 
-	return nil
+// readPacket reads a packet from conn, along with the TTL or hop limit, the
+// TOS or traffic class and the IPv4 options it was received with if conn
+// reports them.
+func readPacket(conn net.PacketConn, b []byte) (*packet, net.Addr, error) {
+	if dc, ok := conn.(*dialConn); ok {
+		n, ttl, addr, err := dc.readFrom(b)
+		return &packet{bytes: b, nbytes: n, ttl: ttl}, addr, err
+	}
+	c, ok := conn.(ipConn)
+	if !ok {
+		n, addr, err := conn.ReadFrom(b)
+		return &packet{bytes: b, nbytes: n}, addr, err
+	}
+	if pc := c.IPv4PacketConn(); pc != nil {
+		if _, raw := conn.LocalAddr().(*net.IPAddr); raw && rawHeaders {
+			return readHeader(c, b)
+		}
+		n, cm, addr, err := pc.ReadFrom(b)
+		pkt := &packet{bytes: b, nbytes: n}
+		if cm != nil {
+			pkt.ttl = cm.TTL
+		}
+		return pkt, addr, err
+	}
+	n, cm, addr, err := c.IPv6PacketConn().ReadFrom(b)
+	pkt := &packet{bytes: b, nbytes: n}
+	if cm != nil {
+		pkt.ttl = cm.HopLimit
+		pkt.tos = cm.TrafficClass
+	}
+	return pkt, addr, err
 }
 
-func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
+func (icmpProber) Marshal(p *Pinger, seq int) ([]byte, net.Addr, error) {
 	var typ icmp.Type
 	if p.ipv4 {
 		typ = ipv4.ICMPTypeEcho
@@ -482,62 +1466,71 @@ func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
 		dst = &net.UDPAddr{IP: p.ipaddr.IP, Zone: p.ipaddr.Zone}
 	}
 
-	t := timeToBytes(time.Now())
-	if p.size-timeSliceLength != 0 {
-		t = append(t, byteSliceOfSize(p.size-timeSliceLength)...)
+	t := timeToBytes(p.now())
+	if p.payload != nil {
+		t = append(t, p.payload.Payload(seq)...)
+	} else if size := p.sizeOf(seq); size-timeSliceLength != 0 {
+		t = append(t, p.padding(size-timeSliceLength)...)
 	}
 	bytes, err := (&icmp.Message{
 		Type: typ, Code: 0,
 		Body: &icmp.Echo{
 			ID:   p.id,
-			Seq:  p.sequence,
+			Seq:  seq,
 			Data: t,
 		},
 	}).Marshal(nil)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return bytes, dst, nil
+}
 
-	for {
-		if _, err := conn.WriteTo(bytes, dst); err != nil {
-			if neterr, ok := err.(*net.OpError); ok {
-				if neterr.Err == syscall.ENOBUFS {
-					continue
-				}
-			}
-		}
-		p.PacketsSent += 1
-		p.sequence += 1
-		break
+func (icmpProber) Parse(p *Pinger, bytes []byte) (*Packet, error) {
+	// The IPv4 header is stripped by the socket
+	proto := protocolIPv6ICMP
+	if p.ipv4 {
+		proto = protocolICMP
 	}
-	return nil
-}
 
-func (p *Pinger) listen(netProto string, source string) *icmp.PacketConn {
-	conn, err := icmp.ListenPacket(netProto, source)
-	if err != nil {
-		fmt.Printf("Error listening for ICMP packets: %s\n", err.Error())
-		close(p.done)
-		return nil
+	var m *icmp.Message
+	var err error
+	if m, err = icmp.ParseMessage(proto, bytes); err != nil {
+		return nil, fmt.Errorf("Error parsing icmp message")
 	}
-	return conn
-}
 
-func byteSliceOfSize(n int) []byte {
-	b := make([]byte, n)
-	for i := 0; i < len(b); i++ {
-		b[i] = 1
+	if icmpErr := p.parseICMPError(m, bytes); icmpErr != nil {
+		return nil, icmpErr
+	}
+	if m.Type != ipv4.ICMPTypeEchoReply && m.Type != ipv6.ICMPTypeEchoReply {
+		// Not an echo reply, ignore it
+		return nil, nil
 	}
 
-	return b
-}
+	// Check if reply from same ID. Unprivileged pings get the ID the kernel
+	// set for the socket, and only the replies to it.
+	body := m.Body.(*icmp.Echo)
+	if p.network != "udp" && body.ID != p.id {
+		return nil, nil
+	}
+
+	outPkt := &Packet{}
 
-func ipv4Payload(b []byte) []byte {
-	if len(b) < ipv4.HeaderLen {
-		return b
+	switch pkt := m.Body.(type) {
+	case *icmp.Echo:
+		outPkt.Rtt = p.receiveTime().Sub(bytesToTime(pkt.Data[:timeSliceLength]))
+		outPkt.Seq = pkt.Seq
+		outPkt.Payload = pkt.Data[timeSliceLength:]
+		if err := p.validatePayload(pkt.Seq, outPkt.Payload); err != nil {
+			return nil, &CorruptReplyError{Seq: pkt.Seq, Err: err}
+		}
+	default:
+		// Very bad, not sure how this can happen
+		return nil, fmt.Errorf("Error, invalid ICMP echo reply. Body type: %T, %s",
+			pkt, pkt)
 	}
-	hdrlen := int(b[0]&0x0f) << 2
-	return b[hdrlen:]
+
+	return outPkt, nil
 }
 
 func bytesToTime(b []byte) time.Time {
@@ -2,8 +2,10 @@ package ping
 
 import (
 	"context"
+	"errors"
 	"net"
 	"runtime/debug"
+	"strings"
 	"testing"
 	"time"
 )
@@ -197,6 +199,265 @@ func TestStatisticsLossy(t *testing.T) {
 	}
 }
 
+func TestStateChange(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPinger(ctx, "127.0.0.1")
+	AssertNoError(t, err)
+
+	var changes []State
+	p.OnStateChange = func(old, new State) {
+		changes = append(changes, new)
+	}
+
+	p.setState(StateUp)
+	p.setState(StateUp)
+	p.setState(StateDown)
+	if len(changes) != 2 || changes[0] != StateUp || changes[1] != StateDown {
+		t.Errorf("Expected [up down], got %v", changes)
+	}
+	AssertEqualStrings(t, "down", p.State().String())
+}
+
+func TestRunContext(t *testing.T) {
+	// TEST-NET-1, nothing answers
+	p, err := NewPinger(context.Background(), "192.0.2.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Interval = 20 * time.Millisecond
+	var finished *Statistics
+	p.OnFinish = func(stats *Statistics) {
+		finished = stats
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = p.Run(ctx)
+	if err != nil && err != context.DeadlineExceeded {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Expected Run to return on cancellation, took %v", elapsed)
+	}
+	if finished == nil || finished.PacketsSent == 0 {
+		t.Errorf("Expected OnFinish to be called with the sent packets, got %v", finished)
+	}
+}
+
+func TestRunCount(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Count = 3
+	p.Interval = 10 * time.Millisecond
+	p.Timeout = time.Second
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if p.PacketsRecv != 3 {
+		t.Errorf("Expected %v, got %v", 3, p.PacketsRecv)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetTTL(7)
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	ttl, err := p.conn.(ipConn).IPv4PacketConn().TTL()
+	AssertNoError(t, err)
+	if ttl != 7 {
+		t.Errorf("Expected %v, got %v", 7, ttl)
+	}
+
+	p.Count = 1
+	p.Timeout = time.Second
+	var reply *Packet
+	p.OnRecv = func(pkt *Packet) {
+		reply = pkt
+	}
+	AssertNoError(t, p.Run(context.Background()))
+	if reply == nil || reply.Ttl == 0 {
+		t.Errorf("Expected the TTL of the reply, got %+v", reply)
+	}
+
+	p.SetTTL(300)
+	AssertError(t, p.Listen(), "TTL 300")
+}
+
+func TestTOS(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetTOS(0xb8)
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	tos, err := p.conn.(ipConn).IPv4PacketConn().TOS()
+	AssertNoError(t, err)
+	if tos != 0xb8 {
+		t.Errorf("Expected %v, got %v", 0xb8, tos)
+	}
+
+	p.Count = 1
+	p.Timeout = time.Second
+	var reply *Packet
+	p.OnRecv = func(pkt *Packet) {
+		reply = pkt
+	}
+	AssertNoError(t, p.Run(context.Background()))
+	// The reply is marked like the request on loopback
+	if reply == nil || reply.Tos != 0xb8 || reply.Ttl == 0 {
+		t.Errorf("Expected the TOS of the reply, got %+v", reply)
+	}
+}
+
+func TestStatisticsWhileRunning(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Count = 20
+	p.Interval = time.Millisecond
+	p.Timeout = 10 * time.Second
+	// Callbacks can read the statistics too, Run waits for them so that they
+	// don't change meanwhile
+	var recv int
+	var running bool
+	p.OnRecv = func(*Packet) {
+		s := p.Statistics()
+		recv = s.PacketsRecv
+		running = p.Running()
+		if n := s.PacketsSent - s.PacketsRecv; p.Outstanding() > n {
+			t.Errorf("Expected at most %v outstanding, got %v", n, p.Outstanding())
+		}
+	}
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	AssertFalse(t, p.Running())
+
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background())
+	}()
+	// Other goroutines can read them while it runs
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for finished := false; !finished; {
+		select {
+		case err := <-done:
+			AssertNoError(t, err)
+			finished = true
+		case <-ticker.C:
+			p.Statistics()
+			p.Outstanding()
+			p.State()
+		}
+	}
+	AssertTrue(t, running)
+	AssertFalse(t, p.Running())
+	if recv != 20 {
+		t.Errorf("Expected %v, got %v", 20, recv)
+	}
+	// Requests sent once the replies were counted are left unanswered
+	if n := p.PacketsSent - p.PacketsRecv; p.Outstanding() != n {
+		t.Errorf("Expected %v, got %v", n, p.Outstanding())
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Count = 1
+	p.Timeout = 10 * time.Second
+	p.OnRecv = func(*Packet) {
+		panic("OnRecv")
+	}
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+
+	done := make(chan interface{})
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		p.Run(context.Background())
+	}()
+	select {
+	case r := <-done:
+		if r != "OnRecv" {
+			t.Errorf("Expected %v, got %v", "OnRecv", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run deadlocked after a panic")
+	}
+	// The pinger is left unlocked
+	AssertFalse(t, p.Running())
+	if s := p.Statistics(); s.PacketsSent != 1 {
+		t.Errorf("Expected %v, got %v", 1, s.PacketsSent)
+	}
+}
+
+// failingConn is a socket whose reads fail.
+type failingConn struct {
+	net.PacketConn
+}
+
+func (c failingConn) ReadFrom([]byte) (int, net.Addr, error) {
+	return 0, nil, errors.New("read failed")
+}
+
+// failingTransport opens failingConns.
+type failingTransport struct{}
+
+func (failingTransport) ListenPacket(*Pinger) (net.PacketConn, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return failingConn{conn}, nil
+}
+
+func TestReceiveError(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetTransport(failingTransport{})
+	p.Timeout = 10 * time.Second
+	errs := make(chan error, 1)
+	p.OnError = func(err error) {
+		// Called by Run, unlocked, as the other callbacks
+		p.Statistics()
+		if strings.Contains(err.Error(), "receiving") {
+			errs <- err
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't stop after a receive error")
+	}
+	select {
+	case err := <-errs:
+		AssertTrue(t, strings.Contains(err.Error(), "read failed"))
+	default:
+		t.Error("Expected OnError to be called")
+	}
+}
+
 // Test helpers
 func AssertNoError(t *testing.T, err error) {
 	if err != nil {
@@ -0,0 +1,228 @@
+// Package pingtest provides the means of testing code embedding a Pinger
+// without network access nor privileges: a fake clock, and an in-memory
+// transport answering echo requests across a simulated network.
+//
+// A test drives a pinger with both:
+//
+//	clock := pingtest.NewClock(time.Unix(0, 0))
+//	pinger.SetClock(clock)
+//	pinger.SetTransport(&pingtest.Responder{
+//		Loss:  0.1,
+//		Delay: pingtest.Uniform(10*time.Millisecond, 20*time.Millisecond),
+//		Clock: clock,
+//		Seed:  1,
+//	})
+//	go pinger.Run(ctx)
+//	clock.BlockUntil(3)
+//	clock.Advance(time.Second)
+package pingtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sparrc/go-ping"
+)
+
+// Clock is a fake ping.Clock, whose time only moves when Advance is called,
+// firing the timers and tickers due in order.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*timer
+	changed chan struct{}
+}
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start, changed: make(chan struct{})}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer firing once the clock is advanced by d.
+func (c *Clock) NewTimer(d time.Duration) ping.Timer {
+	t := &timer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker returns a ticker firing every time the clock is advanced by d.
+func (c *Clock) NewTicker(d time.Duration) ping.Ticker {
+	if d <= 0 {
+		panic("pingtest: non-positive interval for NewTicker")
+	}
+	t := &timer{clock: c, ch: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return (*ticker)(t)
+}
+
+// AfterFunc calls f once the clock is advanced by d, from Advance, or right
+// away in its own goroutine if d isn't positive. It returns a timer that can
+// cancel the call.
+func (c *Clock) AfterFunc(d time.Duration, f func()) ping.Timer {
+	t := &timer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d. The timers due along the way fire
+// in order, the clock set to their time. Functions of AfterFunc are called
+// before Advance returns, but the goroutines waiting on timers and tickers
+// run concurrently.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		t := c.next(end)
+		if t == nil {
+			break
+		}
+		c.now = t.when
+		f := t.fire()
+		if f != nil {
+			c.mu.Unlock()
+			f()
+			c.mu.Lock()
+		}
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// next returns the first timer active at or before end, or nil.
+func (c *Clock) next(end time.Time) *timer {
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	if len(c.timers) > 0 && !c.timers[0].when.After(end) {
+		return c.timers[0]
+	}
+	return nil
+}
+
+// BlockUntil blocks until n timers and tickers are active, to advance the
+// clock once the code under test waits on them.
+func (c *Clock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		active, changed := len(c.timers), c.changed
+		c.mu.Unlock()
+		if active >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// add activates t, c.mu held.
+func (c *Clock) add(t *timer) {
+	c.remove(t)
+	c.timers = append(c.timers, t)
+	c.notify()
+}
+
+// remove deactivates t, c.mu held, and returns whether it was active.
+func (c *Clock) remove(t *timer) bool {
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// notify wakes up BlockUntil, c.mu held.
+func (c *Clock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// timer is a timer of a Clock, a ticker if period is set, calling f if set
+// rather than sending on ch.
+type timer struct {
+	clock  *Clock
+	ch     chan time.Time
+	f      func()
+	when   time.Time
+	period time.Duration
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.when = c.now.Add(d)
+	if d > 0 || t.period > 0 {
+		active := c.remove(t)
+		c.add(t)
+		return active
+	}
+	// Due at once
+	active := c.remove(t)
+	if f := t.fire(); f != nil {
+		go f()
+	}
+	return active
+}
+
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remove(t)
+}
+
+// fire fires t at its time, c.mu held, rearming it if it is a ticker. It
+// returns the function to call, if any.
+func (t *timer) fire() func() {
+	c := t.clock
+	if t.period > 0 {
+		t.when = t.when.Add(t.period)
+	} else {
+		c.remove(t)
+	}
+	if t.f != nil {
+		return t.f
+	}
+	// Dropped if the last one wasn't received, as with time.Timer
+	select {
+	case t.ch <- c.now:
+	default:
+	}
+	return nil
+}
+
+// ticker is a timer firing every period.
+type ticker timer
+
+func (t *ticker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("pingtest: non-positive interval for Reset")
+	}
+	c := t.clock
+	c.mu.Lock()
+	t.period = d
+	c.mu.Unlock()
+	(*timer)(t).Reset(d)
+}
+
+func (t *ticker) Stop() {
+	(*timer)(t).Stop()
+}
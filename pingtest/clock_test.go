@@ -0,0 +1,63 @@
+package pingtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	c := NewClock(start)
+	timer := c.NewTimer(time.Second)
+	ticker := c.NewTicker(400 * time.Millisecond)
+	var called time.Time
+	c.AfterFunc(500*time.Millisecond, func() {
+		called = c.Now()
+	})
+	c.BlockUntil(3)
+
+	c.Advance(900 * time.Millisecond)
+	if now := c.Now(); !now.Equal(start.Add(900 * time.Millisecond)) {
+		t.Errorf("Expected %v, got %v", start.Add(900*time.Millisecond), now)
+	}
+	if !called.Equal(start.Add(500 * time.Millisecond)) {
+		t.Errorf("Expected %v, got %v", start.Add(500*time.Millisecond), called)
+	}
+	// The second tick is dropped, as the first wasn't received
+	if tick := <-ticker.C(); !tick.Equal(start.Add(400 * time.Millisecond)) {
+		t.Errorf("Expected %v, got %v", start.Add(400*time.Millisecond), tick)
+	}
+	select {
+	case <-timer.C():
+		t.Errorf("Expected the timer not to fire before its time")
+	case <-ticker.C():
+		t.Errorf("Expected a single tick")
+	default:
+	}
+
+	c.Advance(100 * time.Millisecond)
+	if fired := <-timer.C(); !fired.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected %v, got %v", start.Add(time.Second), fired)
+	}
+	if timer.Stop() {
+		t.Errorf("Expected the timer to be stopped once fired")
+	}
+	if timer.Reset(time.Second) {
+		t.Errorf("Expected the timer to be inactive")
+	}
+	if !timer.Stop() {
+		t.Errorf("Expected the timer to be active")
+	}
+
+	ticker.Reset(time.Second)
+	c.Advance(time.Second)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected %v, got %v", start.Add(2*time.Second), tick)
+	}
+	ticker.Stop()
+
+	// Due at once
+	if fired := <-c.NewTimer(0).C(); !fired.Equal(c.Now()) {
+		t.Errorf("Expected %v, got %v", c.Now(), fired)
+	}
+}
@@ -0,0 +1,305 @@
+package pingtest
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sparrc/go-ping"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Protocol numbers of ICMP and ICMPv6, for icmp.ParseMessage
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// Delay is a distribution of the delays of replies, drawing them from r.
+type Delay func(r *rand.Rand) time.Duration
+
+// Constant delays all replies by d.
+func Constant(d time.Duration) Delay {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// Uniform delays replies uniformly between min and max.
+func Uniform(min, max time.Duration) Delay {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// Normal delays replies normally around mean, with standard deviation
+// stddev, and never less than zero.
+func Normal(mean, stddev time.Duration) Delay {
+	return func(r *rand.Rand) time.Duration {
+		if d := mean + time.Duration(r.NormFloat64()*float64(stddev)); d > 0 {
+			return d
+		}
+		return 0
+	}
+}
+
+// Responder is an in-memory ping.Transport answering the ICMP echo requests
+// of pingers, across a simulated network losing, delaying and duplicating
+// them. Its random choices are drawn in the order the echo requests are
+// sent, from a source seeded with Seed, so that tests are repeatable.
+type Responder struct {
+	// Loss is the fraction of echo requests, between 0 and 1, that are
+	// dropped without a reply.
+	Loss float64
+
+	// Duplicates is the fraction of replies, between 0 and 1, that are
+	// received twice.
+	Duplicates float64
+
+	// Delay is the distribution of the delays of the replies. Nil answers
+	// at once.
+	Delay Delay
+
+	// Clock times the delays, ping.SystemClock if nil.
+	Clock ping.Clock
+
+	// Seed seeds the random choices.
+	Seed int64
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	requests int
+	replies  int
+}
+
+// ListenPacket implements ping.Transport.
+func (r *Responder) ListenPacket(*ping.Pinger) (net.PacketConn, error) {
+	return &conn{
+		r:       r,
+		replies: make(chan reply, 1024),
+		closed:  make(chan struct{}),
+		wake:    make(chan struct{}),
+	}, nil
+}
+
+// Requests returns the number of echo requests received.
+func (r *Responder) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+// Replies returns the number of replies delivered, duplicates included.
+func (r *Responder) Replies() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.replies
+}
+
+// answer returns the delays of the replies to an echo request, none if it is
+// lost.
+func (r *Responder) answer() []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rand == nil {
+		r.rand = rand.New(rand.NewSource(r.Seed))
+	}
+	r.requests++
+	if r.Loss > 0 && r.rand.Float64() < r.Loss {
+		return nil
+	}
+	delays := []time.Duration{r.delay()}
+	if r.Duplicates > 0 && r.rand.Float64() < r.Duplicates {
+		delays = append(delays, r.delay())
+	}
+	return delays
+}
+
+// delay draws the delay of a reply, r.mu held.
+func (r *Responder) delay() time.Duration {
+	if r.Delay == nil {
+		return 0
+	}
+	return r.Delay(r.rand)
+}
+
+func (r *Responder) clock() ping.Clock {
+	if r.Clock == nil {
+		return ping.SystemClock
+	}
+	return r.Clock
+}
+
+// reply is a reply waiting to be read.
+type reply struct {
+	b    []byte
+	addr net.Addr
+}
+
+// conn is a socket of a Responder.
+type conn struct {
+	r       *Responder
+	replies chan reply
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	// wake is closed when the read deadline changes
+	mu       sync.Mutex
+	deadline time.Time
+	wake     chan struct{}
+}
+
+func (c *conn) WriteTo(b []byte, dst net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, &net.OpError{Op: "write", Net: "pingtest", Addr: dst, Err: net.ErrClosed}
+	default:
+	}
+	var ip net.IP
+	switch a := dst.(type) {
+	case *net.IPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	}
+	proto, typ := protocolIPv6ICMP, icmp.Type(ipv6.ICMPTypeEchoReply)
+	if ip.To4() != nil {
+		proto, typ = protocolICMP, ipv4.ICMPTypeEchoReply
+	}
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return 0, err
+	}
+	echo, ok := m.Body.(*icmp.Echo)
+	if !ok || (m.Type != ipv4.ICMPTypeEcho && m.Type != ipv6.ICMPTypeEchoRequest) {
+		// Only echo requests are answered
+		return len(b), nil
+	}
+	answer, err := (&icmp.Message{Type: typ, Body: echo}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delay := range c.r.answer() {
+		deliver := func() {
+			select {
+			case <-c.closed:
+			case c.replies <- reply{answer, dst}:
+				c.r.mu.Lock()
+				c.r.replies++
+				c.r.mu.Unlock()
+			default:
+				// The socket buffer is full
+			}
+		}
+		if delay <= 0 {
+			deliver()
+		} else {
+			c.after(delay, deliver)
+		}
+	}
+	return len(b), nil
+}
+
+// after calls f after d on the clock of the responder, unless the socket is
+// closed first.
+func (c *conn) after(d time.Duration, f func()) {
+	if clock, ok := c.r.clock().(*Clock); ok {
+		clock.AfterFunc(d, f)
+		return
+	}
+	t := c.r.clock().NewTimer(d)
+	go func() {
+		select {
+		case <-t.C():
+			f()
+		case <-c.closed:
+			t.Stop()
+		}
+	}()
+}
+
+func (c *conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		c.mu.Lock()
+		deadline, wake := c.deadline, c.wake
+		c.mu.Unlock()
+
+		var expired <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, &net.OpError{Op: "read", Net: "pingtest", Err: os.ErrDeadlineExceeded}
+			}
+			timer = time.NewTimer(d)
+			expired = timer.C
+		}
+		var r *reply
+		var err error
+		select {
+		case rep := <-c.replies:
+			r = &rep
+		case <-expired:
+		case <-wake:
+		case <-c.closed:
+			err = &net.OpError{Op: "read", Net: "pingtest", Err: net.ErrClosed}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if r != nil {
+			return copy(b, r.b), r.addr, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return addr{}
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	close(c.wake)
+	c.wake = make(chan struct{})
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// addr is the local address of the sockets of a Responder.
+type addr struct{}
+
+func (addr) Network() string {
+	return "pingtest"
+}
+
+func (addr) String() string {
+	return "pingtest"
+}
@@ -0,0 +1,157 @@
+package pingtest
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sparrc/go-ping"
+)
+
+func TestResponder(t *testing.T) {
+	for _, addr := range []string{"192.0.2.1", "2001:db8::1"} {
+		for _, privileged := range []bool{false, true} {
+			p, err := ping.NewPinger(context.Background(), addr)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err)
+			}
+			r := &Responder{}
+			p.SetTransport(r)
+			p.SetPrivileged(privileged)
+			p.Count = 5
+			p.Interval = time.Millisecond
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Expected no error, got %s", err)
+			}
+			if p.PacketsRecv != 5 || r.Requests() < 5 {
+				t.Errorf("Expected %v replies to %v, got %v to %v", 5, addr,
+					p.PacketsRecv, r.Requests())
+			}
+		}
+	}
+}
+
+// simulate pings a target across a lossy network for 20 seconds of the
+// clock, advancing it once the pinger processed the previous events. The
+// delays are whole milliseconds, the clock's steps, so that the replies are
+// received at the end of a step whenever the pinger reads them.
+func simulate(t *testing.T, seed int64) *ping.Statistics {
+	clock := NewClock(time.Unix(0, 0))
+	uniform := Uniform(10*time.Millisecond, 50*time.Millisecond)
+	delay := func(r *rand.Rand) time.Duration {
+		return uniform(r).Truncate(time.Millisecond)
+	}
+	r := &Responder{
+		Loss:       0.25,
+		Duplicates: 0.2,
+		Delay:      delay,
+		Clock:      clock,
+		Seed:       seed,
+	}
+	p, err := ping.NewPinger(context.Background(), "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	p.SetClock(clock)
+	p.SetTransport(r)
+	p.ProbeTimeout = 500 * time.Millisecond
+	sent := make(chan bool, 1)
+	p.OnSend = func(*ping.Packet) {
+		sent <- true
+	}
+	recv := make(chan bool, 1)
+	p.OnRecv = func(*ping.Packet) {
+		recv <- true
+	}
+	p.OnDuplicate = p.OnRecv
+
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background())
+	}()
+	replies := 0
+	for step := 0; step < 19999; step++ {
+		if step%1000 == 0 {
+			<-sent
+		}
+		clock.Advance(time.Millisecond)
+		for ; replies < r.Replies(); replies++ {
+			<-recv
+		}
+	}
+	p.Stop()
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	return p.Statistics()
+}
+
+func TestSimulate(t *testing.T) {
+	s := simulate(t, 1)
+	if s.PacketsSent != 20 {
+		t.Errorf("Expected %v, got %v", 20, s.PacketsSent)
+	}
+	if s.PacketsLost == 0 || s.PacketsRecvDuplicates == 0 ||
+		s.PacketsRecv+s.PacketsLost != s.PacketsSent {
+		t.Errorf("Expected losses and duplicates, got %+v", s)
+	}
+	// Measured on the clock, exactly
+	if s.MinRtt < 10*time.Millisecond || s.MaxRtt > 50*time.Millisecond ||
+		s.MinRtt%time.Millisecond != 0 {
+		t.Errorf("Expected round-trip times between 10ms and 50ms, got %v", s.Rtts)
+	}
+
+	if again := simulate(t, 1); !reflect.DeepEqual(again.Rtts, s.Rtts) ||
+		again.PacketsLost != s.PacketsLost ||
+		again.PacketsRecvDuplicates != s.PacketsRecvDuplicates {
+		t.Errorf("Expected %+v, got %+v", s, again)
+	}
+}
+
+func TestIsochronous(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	p, err := ping.NewPinger(context.Background(), "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	p.SetClock(clock)
+	// Unanswered, so that the timers active are those of the timeout, of
+	// the request in flight and, once rearmed, of the schedule
+	p.SetTransport(&Responder{Loss: 1, Clock: clock})
+	p.Isochronous = true
+	p.ProbeTimeout = time.Hour
+	sent := make(chan bool, 1)
+	p.OnSend = func(*ping.Packet) {
+		sent <- true
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background())
+	}()
+	<-sent
+	// 250ms late for slot 1, then for slot 4 after missing slots 2 and 3,
+	// then on time for slot 5
+	for _, d := range []time.Duration{1250 * time.Millisecond,
+		3500 * time.Millisecond, 250 * time.Millisecond} {
+		clock.BlockUntil(3)
+		clock.Advance(d)
+		<-sent
+	}
+	p.Stop()
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	s := p.Statistics()
+	if s.PacketsSent != 4 || s.ProbesMissed != 2 {
+		t.Errorf("Expected 4 requests and 2 missed slots, got %v and %v",
+			s.PacketsSent, s.ProbesMissed)
+	}
+	if s.MaxSendError != 750*time.Millisecond || s.AvgSendError != time.Second/3 {
+		t.Errorf("Expected send errors of %v at most and %v on average, got %v and %v",
+			750*time.Millisecond, time.Second/3, s.MaxSendError, s.AvgSendError)
+	}
+}
@@ -0,0 +1,23 @@
+package ping
+
+import (
+	"errors"
+	"runtime"
+
+	"golang.org/x/net/icmp"
+)
+
+// ErrUnsupportedPlatform is returned when opening an ICMP socket, or using
+// another feature, on a platform that doesn't support it, such as js/wasm
+// or plan9. The package builds everywhere so that it can be embedded in
+// multi-platform programs.
+var ErrUnsupportedPlatform = errors.New("Unsupported platform " +
+	runtime.GOOS + "/" + runtime.GOARCH)
+
+// listenPacket opens an ICMP socket as icmp.ListenPacket does.
+func listenPacket(network, address string) (*icmp.PacketConn, error) {
+	if !platformSupported {
+		return nil, ErrUnsupportedPlatform
+	}
+	return icmp.ListenPacket(network, address)
+}
@@ -0,0 +1,35 @@
+//go:build !js && !plan9 && !wasip1
+
+package ping
+
+import (
+	"errors"
+	"syscall"
+)
+
+const platformSupported = true
+
+// isNoBufferSpace reports whether err means the socket send buffer is full.
+func isNoBufferSpace(err error) bool {
+	return err == syscall.ENOBUFS
+}
+
+// isMessageTooLong reports whether err means a packet was larger than the
+// MTU of the local interface.
+func isMessageTooLong(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
+
+// wsaeConnRefused and wsaeConnReset are the errors of Windows for refused TCP
+// connections and UDP datagrams.
+const (
+	wsaeConnRefused = syscall.Errno(10061)
+	wsaeConnReset   = syscall.Errno(10054)
+)
+
+// isConnRefused reports whether err means a TCP connection or UDP datagram
+// was refused by the target.
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, wsaeConnRefused) || errors.Is(err, wsaeConnReset)
+}
@@ -0,0 +1,20 @@
+package ping
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUnsupportedPlatform(t *testing.T) {
+	if platformSupported {
+		t.Skip("Supported platform, skipping")
+	}
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	if err := p.Listen(); err != ErrUnsupportedPlatform {
+		t.Errorf("Expected %v, got %v", ErrUnsupportedPlatform, err)
+	}
+	if _, err := NewResponder("ip4:icmp", "127.0.0.1"); err != ErrUnsupportedPlatform {
+		t.Errorf("Expected %v, got %v", ErrUnsupportedPlatform, err)
+	}
+}
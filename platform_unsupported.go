@@ -0,0 +1,17 @@
+//go:build js || plan9 || wasip1
+
+package ping
+
+const platformSupported = false
+
+func isNoBufferSpace(err error) bool {
+	return false
+}
+
+func isMessageTooLong(err error) bool {
+	return false
+}
+
+func isConnRefused(err error) bool {
+	return false
+}
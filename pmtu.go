@@ -0,0 +1,161 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// icmpHeaderLen is the length of the ICMP echo header.
+	icmpHeaderLen = 8
+
+	// maxPathMTU is the largest path MTU DiscoverPathMTU looks for, the
+	// usual jumbo frame size.
+	maxPathMTU = 9000
+
+	// pmtuProbeTimeout is how long DiscoverPathMTU waits for a reply to a
+	// size.
+	pmtuProbeTimeout = time.Second
+)
+
+// FragmentationNeededError is reported to OnError when an echo request sent
+// with the don't fragment flag is too large for the path, with the MTU of
+// the next hop if the router reported it. Routers only report it to
+// privileged pingers.
+type FragmentationNeededError struct {
+	// Seq is the sequence number of the echo request.
+	Seq int
+
+	// Size is the size of the IP packet of the echo request.
+	Size int
+
+	// MTU is the MTU of the next hop, zero if unknown.
+	MTU int
+}
+
+func (e *FragmentationNeededError) Error() string {
+	if e.MTU == 0 {
+		return fmt.Sprintf("Echo request %d of %d bytes needs fragmentation", e.Seq, e.Size)
+	}
+	return fmt.Sprintf("Echo request %d of %d bytes needs fragmentation, next hop MTU is %d",
+		e.Seq, e.Size, e.MTU)
+}
+
+// SetDontFragment sets the don't fragment flag of the echo requests, so
+// that the ones larger than the path MTU are dropped and reported with a
+// FragmentationNeededError instead of being fragmented. For IPv6, which
+// routers never fragment, it prevents fragmentation by the local host. It
+// must be called before Listen or Run, and is not supported on all
+// platforms. It has no effect on the pingers of a PingerPool, like SetTTL.
+func (p *Pinger) SetDontFragment(df bool) {
+	p.dontFragment = df
+}
+
+// packetSize returns the size of the IP packets of the largest echo
+// requests.
+func (p *Pinger) packetSize() int {
+	return p.ipPacketSize(p.maxSize())
+}
+
+// ipPacketSize returns the size of the IP packet of an echo request with
+// size bytes of data.
+func (p *Pinger) ipPacketSize(size int) int {
+	if p.ipv4 {
+		return ipv4.HeaderLen + icmpHeaderLen + size
+	}
+	return ipv6.HeaderLen + icmpHeaderLen + size
+}
+
+// DiscoverPathMTU returns the MTU of the path to the target, the size of
+// the largest IP packet that reaches it unfragmented. It searches the sizes
+// of echo requests sent with the don't fragment flag, between the minimum
+// MTU of the address family and 9000 bytes, skipping to the next hop MTU
+// reported by routers when there is one. Each size is given a second to be
+// answered. This is a blocking function. It is not available in a
+// pingminimal build, as each size is sent on its own socket.
+func (p *Pinger) DiscoverPathMTU(ctx context.Context) (int, error) {
+	if MinimalSyscalls {
+		return 0, ErrMinimalSyscalls
+	}
+	header := ipv6.HeaderLen + icmpHeaderLen
+	lo := 1280
+	if p.ipv4 {
+		header = ipv4.HeaderLen + icmpHeaderLen
+		lo = 68
+	}
+	fits, _, err := p.probeSize(ctx, lo-header)
+	if err != nil {
+		return 0, err
+	}
+	if !fits {
+		return 0, errors.New("No reply to echo requests of the minimum MTU")
+	}
+
+	hi := maxPathMTU + 1
+	for lo+1 < hi {
+		mid := (lo + hi) / 2
+		fits, mtu, err := p.probeSize(ctx, mid-header)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case fits:
+			lo = mid
+		case mtu > lo && mtu < mid:
+			// Try the reported MTU next
+			hi = mtu + 1
+		default:
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// probeSize pings the target with echo requests of the given payload size
+// and the don't fragment flag. It reports whether they are answered, or the
+// next hop MTU if a router reported it.
+func (p *Pinger) probeSize(ctx context.Context, size int) (fits bool, mtu int, err error) {
+	q := p.clone()
+	q.SetSize(size)
+	q.SetDontFragment(true)
+	q.Count = 1
+	q.Interval = pmtuProbeTimeout / 4
+	q.Timeout = pmtuProbeTimeout
+	q.OnError = func(err error) {
+		var frag *FragmentationNeededError
+		if errors.As(err, &frag) {
+			mtu = frag.MTU
+			q.Stop()
+		} else if isMessageTooLong(err) {
+			// Larger than the MTU of the local interface
+			q.Stop()
+		}
+	}
+	if err := q.Run(ctx); err != nil {
+		return false, 0, err
+	}
+	return q.PacketsRecv > 0, mtu, nil
+}
+
+// quotedEcho returns the identifier and sequence number of the echo request
+// quoted by an ICMP error: its IP header followed by its first 8 bytes.
+func quotedEcho(quoted []byte, ipv4Quoted bool) (id, seq int, ok bool) {
+	hdrlen := ipv6.HeaderLen
+	if ipv4Quoted {
+		if len(quoted) < ipv4.HeaderLen {
+			return 0, 0, false
+		}
+		hdrlen = int(quoted[0]&0x0f) << 2
+	}
+	if len(quoted) < hdrlen+icmpHeaderLen {
+		return 0, 0, false
+	}
+	echo := quoted[hdrlen:]
+	return int(binary.BigEndian.Uint16(echo[4:6])), int(binary.BigEndian.Uint16(echo[6:8])), true
+}
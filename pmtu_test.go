@@ -0,0 +1,120 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestParseFragmentationNeeded(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetSize(1472)
+	probe, _, err := p.prober.Marshal(p, 5)
+	AssertNoError(t, err)
+	p.trackSent(5, time.Now())
+
+	// IPv4 header of the dropped request, followed by its first 8 bytes
+	quoted := append(make([]byte, ipv4.HeaderLen), probe[:8]...)
+	quoted[0] = 0x45
+	copy(quoted[16:20], net.ParseIP("127.0.0.1").To4())
+	b, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeDestinationUnreachable, Code: 4,
+		Body: &icmp.DstUnreach{Data: quoted},
+	}).Marshal(nil)
+	AssertNoError(t, err)
+	binary.BigEndian.PutUint16(b[6:8], 1400)
+
+	err = p.processPacket(&packet{bytes: b, nbytes: len(b)})
+	var frag *FragmentationNeededError
+	if !errors.As(err, &frag) {
+		t.Fatalf("Expected a FragmentationNeededError, got %v", err)
+	}
+	if frag.Seq != 5 || frag.Size != 1500 || frag.MTU != 1400 {
+		t.Errorf("Expected request 5 of 1500 bytes and MTU 1400, got %+v", frag)
+	}
+
+	// Another host unreachable is not about fragmentation
+	b[1] = 1
+	AssertNoError(t, p.processPacket(&packet{bytes: b, nbytes: len(b)}))
+
+	// Someone else's request
+	p.id++
+	b[1] = 4
+	AssertNoError(t, p.processPacket(&packet{bytes: b, nbytes: len(b)}))
+
+	p6, err := NewPinger(context.Background(), "::1")
+	AssertNoError(t, err)
+	p6.SetPrivileged(true)
+	probe, _, err = p6.prober.Marshal(p6, 7)
+	AssertNoError(t, err)
+	p6.trackSent(7, time.Now())
+	quoted = append(make([]byte, ipv6.HeaderLen), probe[:8]...)
+	copy(quoted[24:40], net.ParseIP("::1"))
+	b, err = (&icmp.Message{
+		Type: ipv6.ICMPTypePacketTooBig,
+		Body: &icmp.PacketTooBig{MTU: 1280, Data: quoted},
+	}).Marshal(nil)
+	AssertNoError(t, err)
+	err = p6.processPacket(&packet{bytes: b, nbytes: len(b)})
+	if !errors.As(err, &frag) || frag.Seq != 7 || frag.MTU != 1280 {
+		t.Errorf("Expected request 7 and MTU 1280, got %v", err)
+	}
+}
+
+func TestSetSize(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetSize(2)
+	if p.Size() != timeSliceLength {
+		t.Errorf("Expected %v, got %v", timeSliceLength, p.Size())
+	}
+
+	p.SetPrivileged(true)
+	p.SetSize(2000)
+	p.SetDontFragment(true)
+	p.Count = 1
+	p.Timeout = time.Second
+	var reply *Packet
+	p.OnRecv = func(pkt *Packet) {
+		reply = pkt
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if reply == nil || reply.Nbytes != 2000+icmpHeaderLen {
+		t.Errorf("Expected a reply of %v bytes, got %+v", 2000+icmpHeaderLen, reply)
+	}
+}
+
+func TestDiscoverPathMTU(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	p.conn.Close()
+	p.conn = nil
+
+	// The loopback interface has a larger MTU than searched
+	mtu, err := p.DiscoverPathMTU(context.Background())
+	if MinimalSyscalls {
+		if err != ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+		}
+		return
+	}
+	AssertNoError(t, err)
+	if mtu != maxPathMTU {
+		t.Errorf("Expected %v, got %v", maxPathMTU, mtu)
+	}
+}
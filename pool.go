@@ -0,0 +1,285 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// PingerPool runs many pingers over a single ICMP socket per address family,
+// instead of one socket and one receive goroutine per pinger. Echo replies
+// are demultiplexed back to their pinger by source address and identifier.
+//
+// The pingers are run as in a MultiPinger, until Stop is called or the
+// context of Run is done. They must not change address once added. The
+// shared socket is opened with the defaults of the system: SetSource and
+// SetInterface fail on the pingers of a pool, and SetTTL, SetTOS and
+// SetDontFragment have no effect on them.
+type PingerPool struct {
+	*MultiPinger
+
+	ctx     context.Context
+	network string
+
+	mu    sync.Mutex
+	conns map[bool]*poolConn
+	wg    sync.WaitGroup
+}
+
+// errPooled is returned when setting the socket options of a pinger of a
+// PingerPool.
+var errPooled = errors.New("Socket options can't be set on the pingers of a PingerPool")
+
+// poolConn is a socket shared by the pingers of a pool for an address
+// family.
+type poolConn struct {
+	conn    net.PacketConn
+	ipv4    bool
+	network string
+
+	mu      sync.Mutex
+	members map[poolKey]chan *packet
+}
+
+// poolKey identifies the pinger echo replies are for. The identifier is
+// always zero for unprivileged pings, as the kernel sets it per socket.
+type poolKey struct {
+	ip string
+	id int
+}
+
+// sharedConn is the socket of a pooled pinger, and the replies demultiplexed
+// for it.
+type sharedConn struct {
+	conn net.PacketConn
+	recv chan *packet
+}
+
+// NewPingerPool returns an empty PingerPool. Its pingers are created with
+// ctx and send unprivileged pings by default.
+func NewPingerPool(ctx context.Context) *PingerPool {
+	return &PingerPool{
+		MultiPinger: NewMultiPinger(),
+		ctx:         ctx,
+		network:     "udp",
+		conns:       make(map[bool]*poolConn),
+	}
+}
+
+// SetPrivileged sets the type of ping the pool sends, as Pinger.SetPrivileged
+// does. It must be called before any pinger is added.
+func (pool *PingerPool) SetPrivileged(privileged bool) {
+	if privileged {
+		pool.network = "ip"
+	} else {
+		pool.network = "udp"
+	}
+}
+
+// Add creates a pinger for addr sharing the pool's socket, and adds it under
+// name, starting it if the pool is running. Unprivileged pings can't tell
+// apart replies from the same address, so there can only be one pinger per
+// address in that case.
+func (pool *PingerPool) Add(name, addr string) (*Pinger, error) {
+	p, err := NewPinger(pool.ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	p.network = pool.network
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	c, err := pool.conn(p)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.register(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.MultiPinger.Add(name, p); err != nil {
+		c.unregister(key)
+		return nil, err
+	}
+	return p, nil
+}
+
+// Remove stops and removes the pinger added under name, as
+// MultiPinger.Remove does.
+func (pool *PingerPool) Remove(name string) *Pinger {
+	p := pool.MultiPinger.Remove(name)
+	if p == nil {
+		return nil
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if c := pool.conns[p.ipv4]; c != nil {
+		c.unregister(c.key(p))
+	}
+	return p
+}
+
+// Close stops the pingers and closes the pool's sockets.
+func (pool *PingerPool) Close() error {
+	pool.Stop()
+	pool.mu.Lock()
+	var err error
+	for _, c := range pool.conns {
+		if cerr := c.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	pool.conns = make(map[bool]*poolConn)
+	pool.mu.Unlock()
+	pool.wg.Wait()
+	return err
+}
+
+// conn returns the socket for the address family of p, opening it if
+// needed.
+func (pool *PingerPool) conn(p *Pinger) (*poolConn, error) {
+	if c, ok := pool.conns[p.ipv4]; ok {
+		return c, nil
+	}
+	conn, err := icmpProber{}.Listen(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error listening for packets: %s", err)
+	}
+	c := &poolConn{
+		conn:    conn,
+		ipv4:    p.ipv4,
+		network: p.network,
+		members: make(map[poolKey]chan *packet),
+	}
+	pool.conns[p.ipv4] = c
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		c.demux()
+	}()
+	return c, nil
+}
+
+func (c *poolConn) key(p *Pinger) poolKey {
+	key := poolKey{ip: p.ipaddr.IP.String()}
+	if c.network != "udp" {
+		key.id = p.id
+	}
+	return key
+}
+
+// register makes the replies to p delivered to it, picking a free identifier
+// for it if needed.
+func (c *poolConn) register(p *Pinger) (poolKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := c.key(p)
+	for c.members[key] != nil {
+		if c.network == "udp" {
+			return key, fmt.Errorf("Pool already pings %s unprivileged", key.ip)
+		}
+		p.id = rand.Intn(0xffff)
+		key = c.key(p)
+	}
+	recv := make(chan *packet, 10)
+	c.members[key] = recv
+	p.shared = &sharedConn{conn: c.conn, recv: recv}
+	return key, nil
+}
+
+func (c *poolConn) unregister(key poolKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.members, key)
+}
+
+// demux reads the packets received on the socket and delivers the echo
+// replies to their pinger, until the socket is closed. Replies are dropped
+// when the pinger isn't keeping up.
+func (c *poolConn) demux() {
+	proto := protocolIPv6ICMP
+	if c.ipv4 {
+		proto = protocolICMP
+	}
+	// Large enough for the echo requests of any size, copied on delivery
+	buf := make([]byte, 65536)
+	var batch *batchReader
+	if conn, ok := c.conn.(ipConn); ok && batchReads {
+		batch = newBatchReader(conn, len(buf))
+	}
+	for {
+		var pkt *packet
+		var rAddr net.Addr
+		var err error
+		if batch != nil {
+			pkt, rAddr, err = batch.read()
+		} else {
+			pkt, rAddr, err = readPacket(c.conn, buf)
+		}
+		if err != nil {
+			if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+				continue
+			}
+			return
+		}
+		c.deliver(pkt, rAddr, proto)
+		pkt.free()
+	}
+}
+
+// deliver delivers pkt to its pinger, if it is an echo reply to or an error
+// about the echo requests of a member.
+func (c *poolConn) deliver(pkt *packet, rAddr net.Addr, proto int) {
+	b := pkt.bytes[:pkt.nbytes]
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return
+	}
+	var key poolKey
+	if echo, ok := m.Body.(*icmp.Echo); ok &&
+		(m.Type == ipv4.ICMPTypeEchoReply || m.Type == ipv6.ICMPTypeEchoReply) {
+		key = poolKey{ip: addrIP(rAddr).String(), id: echo.ID}
+	} else if quoted, _ := quotedRequest(m, b); quoted != nil {
+		// An error about the request of a member, to its target
+		id, _, ok := quotedEcho(quoted, c.ipv4)
+		if !ok {
+			return
+		}
+		key = poolKey{ip: quotedDst(quoted, c.ipv4).String(), id: id}
+	} else {
+		return
+	}
+	if c.network == "udp" {
+		key.id = 0
+	}
+	c.mu.Lock()
+	recv := c.members[key]
+	c.mu.Unlock()
+	if recv == nil {
+		return
+	}
+	select {
+	case recv <- &packet{bytes: append([]byte(nil), b...), nbytes: pkt.nbytes,
+		rAddr: rAddr.String(), ttl: pkt.ttl, tos: pkt.tos, options: pkt.options,
+		received: pkt.received}:
+	default:
+	}
+}
+
+// addrIP returns the IP address of a packet source.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPingerPool(t *testing.T) {
+	pool := NewPingerPool(context.Background())
+	pool.SetPrivileged(true)
+	defer pool.Close()
+
+	addrs := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+	raddrs := make(map[string]map[string]bool)
+	var pingers []*Pinger
+	for _, addr := range addrs {
+		p, err := pool.Add(addr, addr)
+		if err != nil {
+			t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+		}
+		p.Count = 3
+		p.Interval = 20 * time.Millisecond
+		p.Timeout = 2 * time.Second
+		seen := make(map[string]bool)
+		raddrs[addr] = seen
+		p.OnRecv = func(pkt *Packet) {
+			seen[pkt.RAddr] = true
+		}
+		pingers = append(pingers, p)
+	}
+	_, err := pool.Add("127.0.0.1", "127.0.0.1")
+	AssertError(t, err, "duplicate name")
+	// The socket is shared
+	AssertError(t, pingers[0].SetSource("127.0.0.1"), "pooled source")
+	AssertError(t, pingers[0].SetInterface("lo"), "pooled interface")
+	if len(pool.conns) != 1 {
+		t.Errorf("Expected %v socket, got %v", 1, len(pool.conns))
+	}
+
+	AssertNoError(t, runMulti(t, pool.MultiPinger, pingers...))
+	for name, stats := range pool.Statistics() {
+		if stats.PacketsRecv < 3 {
+			t.Errorf("Expected 3 replies for %s, got %d", name, stats.PacketsRecv)
+		}
+		if len(raddrs[name]) != 1 || !raddrs[name][name] {
+			t.Errorf("Expected replies from %s only, got %v", name, raddrs[name])
+		}
+	}
+
+	if p := pool.Remove("127.0.0.2"); p == nil {
+		t.Fatalf("Expected 127.0.0.2 to be removed")
+	}
+	c := pool.conns[true]
+	if len(c.members) != 2 {
+		t.Errorf("Expected %v members, got %v", 2, len(c.members))
+	}
+}
+
+func TestPingerPoolUnprivileged(t *testing.T) {
+	pool := NewPingerPool(context.Background())
+	defer pool.Close()
+	if _, err := pool.Add("a", "127.0.0.1"); err != nil {
+		t.Skipf("Can't open unprivileged ICMP socket, skipping: %s", err)
+	}
+	_, err := pool.Add("b", "127.0.0.1")
+	AssertError(t, err, "same address unprivileged")
+}
@@ -0,0 +1,75 @@
+package ping
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const linuxCapabilityVersion3 = 0x20080522
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// DropPrivileges switches the process to the given user and group IDs, with
+// no supplementary groups, and clears all its capabilities. It applies to all
+// the threads of the process.
+//
+// It is meant to be called after the sockets needing privileges have been
+// opened with Pinger.Listen or MultiPinger.Listen, and before Run:
+//
+//	if err := pinger.Listen(); err != nil {
+//		panic(err)
+//	}
+//	if err := ping.DropPrivileges(65534, 65534); err != nil {
+//		panic(err)
+//	}
+//	pinger.Run(ctx)
+func DropPrivileges(uid, gid int) error {
+	if err := syscall.Setgroups(nil); err != nil {
+		return fmt.Errorf("Error dropping supplementary groups: %s", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("Error setting group ID %d: %s", gid, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("Error setting user ID %d: %s", uid, err)
+	}
+
+	// Switching to a non-zero user ID already clears the capabilities of
+	// all threads, unless SECBIT_KEEP_CAPS is set.
+	if uid != 0 && !hasCapabilities() {
+		return nil
+	}
+	hdr := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	_, _, errno := syscall.AllThreadsSyscall(syscall.SYS_CAPSET,
+		uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+	if errno == syscall.ENOTSUP {
+		return errors.New("Clearing capabilities of all threads is not supported " +
+			"with cgo, switch to a non-zero user ID instead")
+	}
+	if errno != 0 {
+		return fmt.Errorf("Error clearing capabilities: %s", errno)
+	}
+	return nil
+}
+
+// hasCapabilities reports whether the calling thread has any permitted
+// capability.
+func hasCapabilities() bool {
+	hdr := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	_, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET,
+		uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+	return errno != 0 || data[0].permitted != 0 || data[1].permitted != 0
+}
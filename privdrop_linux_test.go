@@ -0,0 +1,50 @@
+package ping
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+func TestDropPrivileges(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Not running as root, skipping")
+	}
+	if os.Getenv("PING_TEST_DROP_PRIVILEGES") == "" {
+		// Dropping privileges can't be undone, do it in a child process
+		cmd := exec.Command(os.Args[0], "-test.run=^TestDropPrivileges$")
+		cmd.Env = append(os.Environ(), "PING_TEST_DROP_PRIVILEGES=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Expected the child test to pass, got %s:\n%s", err, out)
+		}
+		return
+	}
+
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Count = 1
+	p.Timeout = time.Second
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+
+	AssertNoError(t, DropPrivileges(65534, 65534))
+	if uid := os.Getuid(); uid != 65534 {
+		t.Errorf("Expected %v, got %v", 65534, uid)
+	}
+	if conn, err := icmp.ListenPacket("ip4:icmp", ""); err == nil {
+		conn.Close()
+		t.Errorf("Expected opening a raw socket to fail after dropping privileges")
+	}
+
+	p.Run(context.Background())
+	if p.PacketsRecv != 1 {
+		t.Errorf("Expected %v, got %v", 1, p.PacketsRecv)
+	}
+}
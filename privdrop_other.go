@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ping
+
+// DropPrivileges switches the process to the given user and group IDs and
+// clears its capabilities. It is only supported on Linux, and returns
+// ErrUnsupportedPlatform elsewhere.
+func DropPrivileges(uid, gid int) error {
+	return ErrUnsupportedPlatform
+}
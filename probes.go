@@ -0,0 +1,98 @@
+package ping
+
+import (
+	"time"
+)
+
+// ProbeResult is the outcome of one echo request.
+type ProbeResult struct {
+	// Seq is the sequence number of the echo request.
+	Seq int
+
+	// Sent is when the echo request was sent.
+	Sent time.Time
+
+	// Received is when the reply was received, zero if none was.
+	Received time.Time
+
+	// Rtt is the round-trip time of the reply.
+	Rtt time.Duration
+
+	// Lost is true if no reply was received within the ProbeTimeout.
+	Lost bool
+}
+
+// probeResultSize is the number of bytes retained for the outcome of an echo
+// request, a ProbeResult and the pointer to it.
+const probeResultSize = 80
+
+// trackSent records probe seq, sent at sent.
+func (p *Pinger) trackSent(seq int, sent time.Time) {
+	if p.probes == nil {
+		p.probes = make(map[int]*ProbeResult)
+	}
+	pr := &ProbeResult{Seq: seq, Sent: sent}
+	p.probes[seq&0xffff] = pr
+	if p.ProbeTimeout > 0 {
+		p.inflight = append(p.inflight, pr)
+	}
+	if p.stream == nil && p.window == nil {
+		p.results = append(p.results, pr)
+		p.checkMemoryBudget()
+	}
+}
+
+// trackReply records the reply to probe seq, received at received. It
+// reports whether the probe was already answered, or declared lost, in which
+// case the reply is not recorded.
+func (p *Pinger) trackReply(seq int, received time.Time, rtt time.Duration) (duplicate, late bool) {
+	pr := p.probes[seq&0xffff]
+	switch {
+	case pr == nil:
+		// Sent by a previous run
+		return false, false
+	case !pr.Received.IsZero():
+		return true, false
+	case pr.Lost:
+		return false, true
+	}
+	pr.Received = received
+	pr.Rtt = rtt
+	return false, false
+}
+
+// expire declares lost the probes unanswered for ProbeTimeout at now, and
+// reports them to OnTimeout. It returns when the next probe times out, or
+// the zero time if no probe is in flight.
+func (p *Pinger) expire(now time.Time) time.Time {
+	for len(p.inflight) > 0 {
+		pr := p.inflight[0]
+		if !pr.Received.IsZero() {
+			p.inflight = p.inflight[1:]
+			continue
+		}
+		end := pr.Sent.Add(p.ProbeTimeout)
+		if now.Before(end) {
+			return end
+		}
+		p.inflight = p.inflight[1:]
+		pr.Lost = true
+		p.packetsLost++
+		if handler := p.OnTimeout; handler != nil {
+			p.callback(func() { handler(pr.Seq) })
+		}
+	}
+	return time.Time{}
+}
+
+// probeResults returns a copy of the recorded probe outcomes.
+func (p *Pinger) probeResults() []ProbeResult {
+	if p.results == nil {
+		return nil
+	}
+	results := make([]ProbeResult, len(p.results))
+	for i, pr := range p.results {
+		results[i] = *pr
+	}
+	return results
+}
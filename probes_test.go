@@ -0,0 +1,143 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProbeTimeout(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.ProbeTimeout = 500 * time.Millisecond
+	var timeouts []int
+	p.OnTimeout = func(seq int) {
+		timeouts = append(timeouts, seq)
+	}
+
+	start := time.Now()
+	for seq := 0; seq < 3; seq++ {
+		p.trackSent(seq, start.Add(time.Duration(seq)*100*time.Millisecond))
+	}
+	p.trackReply(1, start.Add(150*time.Millisecond), 50*time.Millisecond)
+	duplicate, late := p.trackReply(1, start.Add(160*time.Millisecond), 60*time.Millisecond)
+	AssertTrue(t, duplicate)
+	AssertFalse(t, late)
+	duplicate, late = p.trackReply(7, start, 0)
+	AssertFalse(t, duplicate || late)
+
+	next := p.expire(start.Add(550 * time.Millisecond))
+	if len(timeouts) != 1 || timeouts[0] != 0 {
+		t.Errorf("Expected [0], got %v", timeouts)
+	}
+	if expected := start.Add(700 * time.Millisecond); !next.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, next)
+	}
+	_, late = p.trackReply(0, start.Add(600*time.Millisecond), 600*time.Millisecond)
+	AssertTrue(t, late)
+
+	next = p.expire(start.Add(time.Second))
+	if len(timeouts) != 2 || timeouts[1] != 2 {
+		t.Errorf("Expected [0 2], got %v", timeouts)
+	}
+	AssertTrue(t, next.IsZero())
+
+	s := p.Statistics()
+	if s.PacketsLost != 2 {
+		t.Errorf("Expected %v, got %v", 2, s.PacketsLost)
+	}
+	expected := []ProbeResult{
+		{Seq: 0, Sent: start, Lost: true},
+		{Seq: 1, Sent: start.Add(100 * time.Millisecond),
+			Received: start.Add(150 * time.Millisecond), Rtt: 50 * time.Millisecond},
+		{Seq: 2, Sent: start.Add(200 * time.Millisecond), Lost: true},
+	}
+	if !reflect.DeepEqual(s.Probes, expected) {
+		t.Errorf("Expected %v, got %v", expected, s.Probes)
+	}
+}
+
+func TestProbeTimeoutRun(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	// A UDP socket never receives echo replies
+	p.conn, err = net.ListenPacket("udp4", "127.0.0.1:0")
+	AssertNoError(t, err)
+	p.OnError = func(error) {}
+	p.Interval = 20 * time.Millisecond
+	p.ProbeTimeout = 50 * time.Millisecond
+	var timeouts []int
+	p.OnTimeout = func(seq int) {
+		timeouts = append(timeouts, seq)
+	}
+	var lost int
+	p.OnSummary = func(s *Statistics) {
+		lost = s.PacketsLost
+	}
+	p.SummaryInterval = 150 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := p.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if len(timeouts) < 3 || timeouts[0] != 0 {
+		t.Errorf("Expected echo requests to time out from 0, got %v", timeouts)
+	}
+	if lost < 3 {
+		t.Errorf("Expected the loss before the pinger finishes, got %v", lost)
+	}
+}
+
+func TestDuplicateReply(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	var duplicates []int
+	p.OnDuplicate = func(pkt *Packet) {
+		duplicates = append(duplicates, pkt.Seq)
+	}
+
+	p.trackSent(0, time.Now())
+	p.sequence = 1
+	p.PacketsSent = 1
+	reply, _, err := p.prober.Marshal(p, 0)
+	AssertNoError(t, err)
+	reply[0] = 0 // Echo reply
+	for i := 0; i < 2; i++ {
+		AssertNoError(t, p.processPacket(&packet{bytes: reply, nbytes: len(reply)}))
+	}
+	if p.PacketsRecv != 1 || p.PacketsRecvDuplicates != 1 {
+		t.Errorf("Expected 1 reply and 1 duplicate, got %v and %v",
+			p.PacketsRecv, p.PacketsRecvDuplicates)
+	}
+	if len(duplicates) != 1 || duplicates[0] != 0 {
+		t.Errorf("Expected [0], got %v", duplicates)
+	}
+	if s := p.Statistics(); s.PacketsRecvDuplicates != 1 {
+		t.Errorf("Expected %v, got %v", 1, s.PacketsRecvDuplicates)
+	}
+}
+
+func TestOnSend(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Count = 2
+	p.Interval = 10 * time.Millisecond
+	p.Timeout = time.Second
+	var sent []int
+	p.OnSend = func(pkt *Packet) {
+		if pkt.Nbytes == 0 {
+			t.Errorf("Expected the size of the echo request")
+		}
+		sent = append(sent, pkt.Seq)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if len(sent) != p.PacketsSent || sent[0] != 0 || sent[1] != 1 {
+		t.Errorf("Expected %v echo requests from 0, got %v", p.PacketsSent, sent)
+	}
+}
@@ -0,0 +1,193 @@
+//go:build prometheus
+
+package ping
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	descPacketsSent = prometheus.NewDesc("ping_packets_sent_total",
+		"Number of echo requests sent.", []string{"target"}, nil)
+	descPacketsRecv = prometheus.NewDesc("ping_packets_recv_total",
+		"Number of replies received.", []string{"target"}, nil)
+	descDuplicates = prometheus.NewDesc("ping_packets_recv_duplicates_total",
+		"Number of duplicate replies received.", []string{"target"}, nil)
+	descErrors = prometheus.NewDesc("ping_packets_recv_errors_total",
+		"Number of ICMP error messages received about the echo requests.", []string{"target"}, nil)
+	descCorrupted = prometheus.NewDesc("ping_packets_recv_corrupted_total",
+		"Number of replies whose payload isn't the one of their echo request.", []string{"target"}, nil)
+	descPacketsLost = prometheus.NewDesc("ping_packets_lost_total",
+		"Number of echo requests declared lost after their probe timeout.", []string{"target"}, nil)
+	descPacketLoss = prometheus.NewDesc("ping_packet_loss_ratio",
+		"Ratio of the echo requests unanswered.", []string{"target"}, nil)
+	descRtt = prometheus.NewDesc("ping_rtt_seconds",
+		"Round-trip times of the replies.", []string{"target"}, nil)
+
+	// The statistics of a stat window go down as requests leave it, so they
+	// are exported as gauges
+	descWindowPacketsSent = prometheus.NewDesc("ping_window_packets_sent",
+		"Number of echo requests sent in the stat window.", []string{"target"}, nil)
+	descWindowPacketsRecv = prometheus.NewDesc("ping_window_packets_recv",
+		"Number of replies received in the stat window.", []string{"target"}, nil)
+	descWindowDuplicates = prometheus.NewDesc("ping_window_packets_recv_duplicates",
+		"Number of duplicate replies received in the stat window.", []string{"target"}, nil)
+	descWindowErrors = prometheus.NewDesc("ping_window_packets_recv_errors",
+		"Number of ICMP error messages received in the stat window.", []string{"target"}, nil)
+	descWindowCorrupted = prometheus.NewDesc("ping_window_packets_recv_corrupted",
+		"Number of corrupted replies received in the stat window.", []string{"target"}, nil)
+	descWindowPacketsLost = prometheus.NewDesc("ping_window_packets_lost",
+		"Number of echo requests of the stat window declared lost.", []string{"target"}, nil)
+	descWindowMinRtt = prometheus.NewDesc("ping_window_rtt_min_seconds",
+		"Minimum round-trip time of the replies in the stat window.", []string{"target"}, nil)
+	descWindowAvgRtt = prometheus.NewDesc("ping_window_rtt_avg_seconds",
+		"Average round-trip time of the replies in the stat window.", []string{"target"}, nil)
+	descWindowMaxRtt = prometheus.NewDesc("ping_window_rtt_max_seconds",
+		"Maximum round-trip time of the replies in the stat window.", []string{"target"}, nil)
+)
+
+// Collector is a prometheus.Collector exposing the statistics of pingers,
+// labelled with their target: the packet counters, the packet loss and a
+// histogram of the round-trip times. For a pinger with a stat window, see
+// SetStatWindow, they are gauges of the window instead, named ping_window_*,
+// with the minimum, average and maximum round-trip times in place of the
+// histogram. It is only built with the prometheus
+// build tag, which requires github.com/prometheus/client_golang. Pingers
+// can be collected while they run. Two pingers of the same target can't be
+// collected together.
+type Collector struct {
+	// Buckets are the upper bounds, in seconds, of the buckets of the
+	// round-trip time histogram. Nil uses prometheus.DefBuckets.
+	Buckets []float64
+
+	mu      sync.Mutex
+	pingers []*Pinger
+}
+
+// NewCollector returns a Collector of pingers.
+func NewCollector(pingers ...*Pinger) *Collector {
+	return &Collector{pingers: pingers}
+}
+
+// Add adds a pinger to the collector.
+func (c *Collector) Add(p *Pinger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pingers = append(c.pingers, p)
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{descPacketsSent, descPacketsRecv,
+		descDuplicates, descErrors, descCorrupted, descPacketsLost, descPacketLoss, descRtt,
+		descWindowPacketsSent, descWindowPacketsRecv, descWindowDuplicates,
+		descWindowErrors, descWindowCorrupted, descWindowPacketsLost,
+		descWindowMinRtt, descWindowAvgRtt, descWindowMaxRtt} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	pingers := append([]*Pinger(nil), c.pingers...)
+	c.mu.Unlock()
+	bounds := c.Buckets
+	if bounds == nil {
+		bounds = prometheus.DefBuckets
+	}
+
+	for _, p := range pingers {
+		p.mu.Lock()
+		windowed := p.window != nil
+		s := p.stats()
+		p.mu.Unlock()
+
+		valueType := prometheus.CounterValue
+		if windowed {
+			valueType = prometheus.GaugeValue
+		}
+		for _, counter := range []struct {
+			desc, window *prometheus.Desc
+			v            int
+		}{
+			{descPacketsSent, descWindowPacketsSent, s.PacketsSent},
+			{descPacketsRecv, descWindowPacketsRecv, s.PacketsRecv},
+			{descDuplicates, descWindowDuplicates, s.PacketsRecvDuplicates},
+			{descErrors, descWindowErrors, s.PacketsRecvErrors},
+			{descCorrupted, descWindowCorrupted, s.PacketsRecvCorrupted},
+			{descPacketsLost, descWindowPacketsLost, s.PacketsLost},
+		} {
+			desc := counter.desc
+			if windowed {
+				desc = counter.window
+			}
+			ch <- prometheus.MustNewConstMetric(desc, valueType, float64(counter.v), s.Addr)
+		}
+		var loss float64
+		if s.PacketsSent > 0 {
+			loss = s.PacketLoss / 100
+		}
+		ch <- prometheus.MustNewConstMetric(descPacketLoss, prometheus.GaugeValue, loss, s.Addr)
+		if windowed {
+			for _, rtt := range []struct {
+				desc *prometheus.Desc
+				v    time.Duration
+			}{
+				{descWindowMinRtt, s.MinRtt},
+				{descWindowAvgRtt, s.AvgRtt},
+				{descWindowMaxRtt, s.MaxRtt},
+			} {
+				ch <- prometheus.MustNewConstMetric(rtt.desc, prometheus.GaugeValue,
+					rtt.v.Seconds(), s.Addr)
+			}
+			continue
+		}
+		count, sum, buckets := rttHistogram(s, bounds)
+		ch <- prometheus.MustNewConstHistogram(descRtt, count, sum, buckets, s.Addr)
+	}
+}
+
+// rttHistogram returns the count, sum in seconds and cumulative counts of
+// the buckets of bounds of the round-trip times of s. Once the memory budget
+// of the pinger is exceeded, they're taken from its own histogram, counted
+// in the first bucket holding their upper bound, and the sum is estimated
+// from the average.
+func rttHistogram(s *Statistics, bounds []float64) (uint64, float64, map[float64]uint64) {
+	counts := make([]uint64, len(bounds))
+	add := func(rtt time.Duration, n int) {
+		for i, bound := range bounds {
+			if rtt.Seconds() <= bound {
+				counts[i] += uint64(n)
+				return
+			}
+		}
+	}
+
+	var count uint64
+	var sum float64
+	if s.Rtts != nil {
+		for _, rtt := range s.Rtts {
+			add(rtt, 1)
+			sum += rtt.Seconds()
+		}
+		count = uint64(len(s.Rtts))
+	} else {
+		for _, h := range s.Histogram {
+			add(h.UpperBound, h.Count)
+			count += uint64(h.Count)
+		}
+		sum = s.AvgRtt.Seconds() * float64(count)
+	}
+
+	buckets := make(map[float64]uint64, len(bounds))
+	var cumulative uint64
+	for i, bound := range bounds {
+		cumulative += counts[i]
+		buckets[bound] = cumulative
+	}
+	return count, sum, buckets
+}
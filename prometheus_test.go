@@ -0,0 +1,57 @@
+//go:build prometheus
+
+package ping
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.PacketsSent = 3
+	p.PacketsRecv = 2
+	p.rtts = []time.Duration{2 * time.Millisecond, 200 * time.Millisecond}
+
+	c := NewCollector(p)
+	ch := make(chan prometheus.Metric, 16)
+	c.Collect(ch)
+	close(ch)
+	if len(ch) != 8 {
+		t.Errorf("Expected %v metrics, got %v", 8, len(ch))
+	}
+
+	count, sum, buckets := rttHistogram(p.Statistics(), []float64{0.005, 0.1, 1})
+	if count != 2 || sum != 0.202 {
+		t.Errorf("Expected 2 round-trip times summing to 0.202s, got %v and %v", count, sum)
+	}
+	if buckets[0.005] != 1 || buckets[0.1] != 1 || buckets[1] != 2 {
+		t.Errorf("Expected cumulative counts 1, 1 and 2, got %v", buckets)
+	}
+}
+
+func TestCollectorWindow(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetStatWindow(2)
+	p.window.send(0)
+	p.window.reply(0, 10*time.Millisecond)
+
+	ch := make(chan prometheus.Metric, 16)
+	NewCollector(p).Collect(ch)
+	close(ch)
+	if len(ch) != 10 {
+		t.Errorf("Expected %v metrics, got %v", 10, len(ch))
+	}
+	for m := range ch {
+		if desc := m.Desc(); desc != descPacketLoss &&
+			!strings.Contains(desc.String(), `"ping_window_`) {
+			t.Errorf("Expected a gauge of the window, got %v", desc)
+		}
+	}
+}
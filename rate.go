@@ -0,0 +1,233 @@
+package ping
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// floodInterval is the longest wait between echo requests in flood mode: as
+// ping -f, a flooding pinger sends at least a hundred per second.
+const floodInterval = 10 * time.Millisecond
+
+// SetRate makes Run send echo requests at pps per second instead of every
+// Interval, paced by a token bucket holding the burst set by SetBurst. It
+// replaces Interval and Isochronous, AutoPace included. Zero disables it.
+func (p *Pinger) SetRate(pps float64) error {
+	if pps < 0 || math.IsNaN(pps) || math.IsInf(pps, 0) {
+		return fmt.Errorf("Invalid rate %v", pps)
+	}
+	p.rate = pps
+	return nil
+}
+
+// Rate returns the rate set by SetRate.
+func (p *Pinger) Rate() float64 {
+	return p.rate
+}
+
+// SetBurst sets how many echo requests a paced pinger can send back to back
+// after being idle, the size of its token bucket. Default is 1.
+func (p *Pinger) SetBurst(n int) error {
+	if n < 1 {
+		return fmt.Errorf("Invalid burst %d", n)
+	}
+	p.burst = n
+	return nil
+}
+
+// SetFlood makes Run send echo requests as fast as they are answered, or a
+// hundred per second if they aren't, as ping -f, for stress testing links.
+// The rate set by SetRate still bounds them. As when paced, echo requests
+// are held back while replies are waiting to be processed.
+func (p *Pinger) SetFlood(flood bool) {
+	p.flood = flood
+}
+
+// SetAdaptive makes Run send each echo request as soon as the previous one is
+// answered, so that the interval tracks the round-trip time, as ping -A. The
+// interval is at least the floor set by SetAdaptiveFloor, and at most
+// Interval, when replies are lost. The rate set by SetRate still bounds it,
+// and the rate achieved is reported in the SendRate of Statistics.
+func (p *Pinger) SetAdaptive(adaptive bool) {
+	p.adaptive = adaptive
+}
+
+// SetAdaptiveFloor sets the shortest interval between echo requests in
+// adaptive mode. Default is zero, sending the next echo request as soon as
+// the reply arrives.
+func (p *Pinger) SetAdaptiveFloor(floor time.Duration) error {
+	if floor < 0 {
+		return fmt.Errorf("Invalid adaptive floor %v", floor)
+	}
+	p.adaptiveFloor = floor
+	return nil
+}
+
+// paced returns whether Run paces echo requests with a pacer.
+func (p *Pinger) paced() bool {
+	return p.rate > 0 || p.flood || p.adaptive
+}
+
+// newPacer returns the pacer of Run, started at now.
+func (p *Pinger) newPacer(now time.Time) *pacer {
+	var floor, wait time.Duration
+	if p.adaptive {
+		floor, wait = p.adaptiveFloor, p.Interval
+	}
+	if p.flood {
+		wait = floodInterval
+	}
+	return newPacer(p.getClock(), now, p.rate, p.burst, floor, wait)
+}
+
+// pacer paces echo requests with a token bucket, and in flood and adaptive
+// modes on the replies.
+type pacer struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// wait is the longest wait for a reply before the next echo request, zero
+	// if they don't follow the replies, and floor the shortest. sent is when
+	// the last echo request was sent, answered whether a reply was received
+	// since.
+	wait     time.Duration
+	floor    time.Duration
+	sent     time.Time
+	answered bool
+
+	timer Timer
+}
+
+func newPacer(clock Clock, now time.Time, rate float64, burst int, floor, wait time.Duration) *pacer {
+	if burst < 1 {
+		burst = 1
+	}
+	return &pacer{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+		wait:   wait,
+		floor:  floor,
+		timer:  clock.NewTimer(0),
+	}
+}
+
+// refill adds the tokens earned since the last refill.
+func (s *pacer) refill(now time.Time) {
+	if s.rate == 0 {
+		s.tokens = s.burst
+	} else {
+		s.tokens = math.Min(s.burst, s.tokens+now.Sub(s.last).Seconds()*s.rate)
+	}
+	s.last = now
+}
+
+// next returns when the next echo request can be sent.
+func (s *pacer) next(now time.Time) time.Time {
+	s.refill(now)
+	due := now
+	if s.tokens < 1 {
+		due = now.Add(time.Duration((1 - s.tokens) / s.rate * float64(time.Second)))
+	}
+	if s.wait > 0 {
+		t := s.sent.Add(s.floor)
+		if !s.answered {
+			t = s.sent.Add(s.wait)
+		}
+		if t.After(due) {
+			due = t
+		}
+	}
+	return due
+}
+
+// send takes a token for an echo request sent at now.
+func (s *pacer) send(now time.Time) {
+	s.refill(now)
+	s.tokens--
+	s.sent = now
+	s.answered = false
+	s.arm(now)
+}
+
+// answer records a reply received at now, for flood and adaptive modes.
+func (s *pacer) answer(now time.Time) {
+	if s.wait > 0 && !s.answered {
+		s.answered = true
+		s.arm(now)
+	}
+}
+
+// arm rearms the timer for the next echo request.
+func (s *pacer) arm(now time.Time) {
+	s.timer.Reset(s.next(now).Sub(now))
+}
+
+func (s *pacer) stop() {
+	s.timer.Stop()
+}
+
+// sendPaced sends an echo request if the pacer allows it, and rearms it. It
+// holds the request back while replies are waiting in recv, so that a fast
+// rate doesn't starve their processing.
+func (p *Pinger) sendPaced(conn net.PacketConn, pace *pacer, recv <-chan *packet) error {
+	now := p.now()
+	if len(recv) > 0 || pace.next(now).After(now) {
+		pace.arm(now)
+		return nil
+	}
+	if !p.active(now) {
+		// Check again when the next window starts, or else at the send
+		// interval, which is never zero
+		wait := p.sendInterval()
+		if next, ok := p.nextActive(now); ok {
+			wait = next.Sub(now)
+		}
+		pace.timer.Reset(wait)
+		return nil
+	}
+	pace.send(now)
+	return p.sendProbe(conn)
+}
+
+// recordSend records an echo request sent at now, for the send rate.
+func (p *Pinger) recordSend(now time.Time) {
+	if p.sendCount == 0 {
+		p.sendFirst = now
+	}
+	p.sendLast = now
+	p.sendCount++
+}
+
+// sendInterval returns the time between echo requests: Interval, or when
+// paced or Interval is zero, as ping -f -i 0, the one they were sent at, and
+// until known the one of the rate set by SetRate.
+func (p *Pinger) sendInterval() time.Duration {
+	if p.Interval > 0 && !p.paced() {
+		return p.Interval
+	}
+	if rate := p.sendRate(); rate > 0 && rate < float64(time.Second) {
+		return time.Duration(float64(time.Second) / rate)
+	}
+	if p.rate > 0 && p.rate < float64(time.Second) {
+		return time.Duration(float64(time.Second) / p.rate)
+	}
+	if p.adaptive && !p.flood && p.Interval > 0 {
+		return p.Interval
+	}
+	return floodInterval
+}
+
+// sendRate returns the rate the echo requests were sent at, per second.
+func (p *Pinger) sendRate() float64 {
+	elapsed := p.sendLast.Sub(p.sendFirst)
+	if p.sendCount < 2 || elapsed <= 0 {
+		return 0
+	}
+	return float64(p.sendCount-1) / elapsed.Seconds()
+}
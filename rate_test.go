@@ -0,0 +1,190 @@
+package ping
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetRate(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertNoError(t, p.SetRate(50))
+	if p.Rate() != 50 || !p.paced() {
+		t.Errorf("Expected %v, got %v", 50, p.Rate())
+	}
+	AssertError(t, p.SetRate(-1), "negative rate")
+	AssertError(t, p.SetRate(math.Inf(1)), "infinite rate")
+	AssertError(t, p.SetBurst(0), "empty burst")
+	AssertNoError(t, p.SetRate(0))
+	AssertFalse(t, p.paced())
+}
+
+func TestPacer(t *testing.T) {
+	start := time.Now()
+	s := newPacer(SystemClock, start, 10, 2, 0, 0)
+	defer s.stop()
+
+	// The burst goes out at once, then a request every 100ms
+	for i := 0; i < 2; i++ {
+		if next := s.next(start); !next.Equal(start) {
+			t.Errorf("Expected %v, got %v", start, next)
+		}
+		s.send(start)
+	}
+	if wait := s.next(start).Sub(start); wait != 100*time.Millisecond {
+		t.Errorf("Expected %v, got %v", 100*time.Millisecond, wait)
+	}
+	later := start.Add(250 * time.Millisecond)
+	s.send(later)
+	if wait := s.next(later).Sub(later); wait != 0 {
+		t.Errorf("Expected %v, got %v", time.Duration(0), wait)
+	}
+
+	// Flooding waits for the reply, or floodInterval
+	f := newPacer(SystemClock, start, 0, 1, 0, floodInterval)
+	defer f.stop()
+	f.send(start)
+	if wait := f.next(start).Sub(start); wait != floodInterval {
+		t.Errorf("Expected %v, got %v", floodInterval, wait)
+	}
+	f.answer(start)
+	if next := f.next(start); !next.Equal(start) {
+		t.Errorf("Expected %v, got %v", start, next)
+	}
+
+	// Adaptive mode follows the replies, no sooner than the floor
+	a := newPacer(SystemClock, start, 0, 1, 50*time.Millisecond, time.Second)
+	defer a.stop()
+	a.send(start)
+	if wait := a.next(start).Sub(start); wait != time.Second {
+		t.Errorf("Expected %v, got %v", time.Second, wait)
+	}
+	reply := start.Add(10 * time.Millisecond)
+	a.answer(reply)
+	if wait := a.next(reply).Sub(start); wait != 50*time.Millisecond {
+		t.Errorf("Expected %v, got %v", 50*time.Millisecond, wait)
+	}
+	reply = start.Add(80 * time.Millisecond)
+	if next := a.next(reply); !next.Equal(reply) {
+		t.Errorf("Expected %v, got %v", reply, next)
+	}
+}
+
+// recordingClock is a Clock recording the durations its timers are reset
+// to.
+type recordingClock struct {
+	systemClock
+	now    time.Time
+	resets []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time {
+	return c.now
+}
+
+func (c *recordingClock) NewTimer(d time.Duration) Timer {
+	return &recordingTimer{Timer: c.systemClock.NewTimer(d), clock: c}
+}
+
+type recordingTimer struct {
+	Timer
+	clock *recordingClock
+}
+
+func (t *recordingTimer) Reset(d time.Duration) bool {
+	t.clock.resets = append(t.clock.resets, d)
+	return t.Timer.Reset(d)
+}
+
+func TestSendPacedOutsideWindows(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.Interval = 0
+	AssertNoError(t, p.SetRate(100))
+	// 2017-01-02 is a Monday
+	clock := &recordingClock{now: time.Date(2017, 1, 2, 7, 0, 0, 0, time.UTC)}
+	p.SetClock(clock)
+	pace := newPacer(clock, clock.now, p.rate, 1, 0, 0)
+	defer pace.stop()
+
+	// Until the window starts
+	r, err := ParseTimeRange("08:00-18:00")
+	AssertNoError(t, err)
+	r.Location = time.UTC
+	p.Windows = []Window{r}
+	AssertNoError(t, p.sendPaced(nil, pace, nil))
+
+	// At the send interval, for a window that doesn't tell
+	p.Windows = []Window{windowFunc(func(time.Time) bool { return false })}
+	AssertNoError(t, p.sendPaced(nil, pace, nil))
+
+	expected := []time.Duration{time.Hour, 10 * time.Millisecond}
+	if len(clock.resets) != 2 || clock.resets[0] != expected[0] ||
+		clock.resets[1] != expected[1] {
+		t.Errorf("Expected %v, got %v", expected, clock.resets)
+	}
+}
+
+// windowFunc is a Window reporting whether it contains t with a function.
+type windowFunc func(t time.Time) bool
+
+func (f windowFunc) Contains(t time.Time) bool {
+	return f(t)
+}
+
+func TestRate(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	AssertNoError(t, p.SetRate(100))
+	p.Timeout = 300 * time.Millisecond
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	// 30 requests in 300ms, leaving room for slow machines
+	if p.PacketsSent < 15 || p.PacketsSent > 32 {
+		t.Errorf("Expected about %v requests, got %v", 30, p.PacketsSent)
+	}
+}
+
+func TestFlood(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetFlood(true)
+	p.Count = 200
+	p.Timeout = 5 * time.Second
+	start := time.Now()
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if p.PacketsRecv != 200 {
+		t.Errorf("Expected %v, got %v", 200, p.PacketsRecv)
+	}
+	// Much faster than the hundred per second sent without replies
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the flood to follow the replies, took %v", elapsed)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetAdaptive(true)
+	AssertError(t, p.SetAdaptiveFloor(-time.Millisecond), "negative floor")
+	AssertNoError(t, p.SetAdaptiveFloor(5*time.Millisecond))
+	AssertTrue(t, p.paced())
+	p.Count = 20
+	p.Timeout = 5 * time.Second
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	// Loopback answers at once, so the floor sets the pace rather than
+	// Interval, and no faster
+	if s := p.Statistics(); s.SendRate < 20 || s.SendRate > 210 {
+		t.Errorf("Expected about %v requests per second, got %v", 200, s.SendRate)
+	}
+}
@@ -0,0 +1,124 @@
+package ping
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// rateLimitWindow is the number of consecutive probes analyzed at once.
+	rateLimitWindow = 50
+
+	// rateLimitSettle is how long replies are waited for before a probe is
+	// analyzed.
+	rateLimitSettle = time.Second
+
+	// autoPaceMargin is the fraction of the detected reply rate AutoPace
+	// probes at.
+	autoPaceMargin = 0.8
+)
+
+// recordReply marks probe seq as answered for rate limit detection.
+func (p *Pinger) recordReply(seq int) {
+	if p.answered == nil {
+		p.answered = make(map[int]bool)
+	}
+	p.answered[seq&0xffff] = true
+}
+
+// checkRateLimit analyzes the blocks of rateLimitWindow probes whose replies
+// are no longer expected, looking for the regular pattern of replies left by
+// a remote rate limiter, as opposed to the random pattern of network loss.
+func (p *Pinger) checkRateLimit() {
+	interval := p.sendInterval()
+	lag := int(rateLimitSettle/interval) + 1
+	for p.analyzed+rateLimitWindow+lag <= p.sequence {
+		answered := make([]bool, rateLimitWindow)
+		n := 0
+		for i := range answered {
+			seq := (p.analyzed + i) & 0xffff
+			if p.answered[seq] {
+				answered[i] = true
+				n++
+			}
+			delete(p.answered, seq)
+		}
+		p.analyzed += rateLimitWindow
+		if !rateLimited(answered) {
+			continue
+		}
+
+		rate := float64(n) / (rateLimitWindow * interval.Seconds())
+		p.rateLimit = rate
+		p.rateLimitedLoss += rateLimitWindow - n
+		if handler := p.OnRateLimit; handler != nil {
+			p.callback(func() { handler(rate) })
+		}
+		if p.AutoPace && !p.paced() {
+			paced := time.Duration(float64(time.Second) / (rate * autoPaceMargin))
+			if paced > p.Interval {
+				p.Interval = paced
+			}
+		}
+	}
+}
+
+// rateLimited reports whether the replies to a block of consecutive probes
+// look rate limited: a sizeable fraction of them is lost, and the runs of
+// answered and lost probes have regular lengths.
+func rateLimited(answered []bool) bool {
+	n := 0
+	for _, a := range answered {
+		if a {
+			n++
+		}
+	}
+	loss := 1 - float64(n)/float64(len(answered))
+	if loss < 0.1 || loss > 0.95 {
+		return false
+	}
+
+	var up, down []float64
+	length := 1
+	for i := 1; i <= len(answered); i++ {
+		if i < len(answered) && answered[i] == answered[i-1] {
+			length++
+			continue
+		}
+		if answered[i-1] {
+			up = append(up, float64(length))
+		} else {
+			down = append(down, float64(length))
+		}
+		length = 1
+	}
+	// The first and last runs may be cut by the block boundaries
+	if answered[0] {
+		up = up[1:]
+	} else {
+		down = down[1:]
+	}
+	if answered[len(answered)-1] {
+		up = up[:len(up)-1]
+	} else {
+		down = down[:len(down)-1]
+	}
+	if len(up) < 3 || len(down) < 3 {
+		return false
+	}
+	return variation(up) <= 0.35 && variation(down) <= 0.35
+}
+
+// variation returns the coefficient of variation of values.
+func variation(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sumsquares float64
+	for _, v := range values {
+		sumsquares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sumsquares/float64(len(values))) / mean
+}
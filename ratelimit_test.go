@@ -0,0 +1,121 @@
+package ping
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestRateLimited(t *testing.T) {
+	pattern := func(f func(i int) bool) []bool {
+		answered := make([]bool, rateLimitWindow)
+		for i := range answered {
+			answered[i] = f(i)
+		}
+		return answered
+	}
+
+	// One reply every third probe
+	AssertTrue(t, rateLimited(pattern(func(i int) bool { return i%3 == 0 })))
+	// Bursts of 4 replies and 2 losses
+	AssertTrue(t, rateLimited(pattern(func(i int) bool { return i%6 < 4 })))
+	// No loss
+	AssertFalse(t, rateLimited(pattern(func(i int) bool { return true })))
+	// Everything lost
+	AssertFalse(t, rateLimited(pattern(func(i int) bool { return false })))
+	// Random loss
+	r := rand.New(rand.NewSource(1))
+	AssertFalse(t, rateLimited(pattern(func(i int) bool { return r.Intn(2) == 0 })))
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.Interval = 10 * time.Millisecond
+	p.AutoPace = true
+	var detected float64
+	p.OnRateLimit = func(rate float64) {
+		detected = rate
+	}
+
+	// 2 blocks are analyzed, the last 101 probes are still awaiting replies
+	for seq := 0; seq < 201; seq++ {
+		if seq%4 == 0 {
+			p.recordReply(seq)
+		}
+	}
+	p.sequence = 201
+	p.checkRateLimit()
+
+	if p.analyzed != 2*rateLimitWindow {
+		t.Errorf("Expected %v, got %v", 2*rateLimitWindow, p.analyzed)
+	}
+	if detected < 24 || detected > 26 {
+		t.Errorf("Expected a rate of about 25/s, got %v", detected)
+	}
+	if p.Interval < 50*time.Millisecond || p.Interval > 55*time.Millisecond {
+		t.Errorf("Expected an interval of about 52ms, got %v", p.Interval)
+	}
+	stats := p.Statistics()
+	if stats.RateLimit != detected {
+		t.Errorf("Expected %v, got %v", detected, stats.RateLimit)
+	}
+	if stats.RateLimitedLoss != 75 {
+		t.Errorf("Expected %v, got %v", 75, stats.RateLimitedLoss)
+	}
+}
+
+func TestCheckRateLimitNoInterval(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.Interval = 0
+	p.SetFlood(true)
+	var detected float64
+	p.OnRateLimit = func(rate float64) {
+		detected = rate
+	}
+
+	// Sent every 2ms, one reply every fourth probe
+	now := time.Now()
+	for seq := 0; seq < 1000; seq++ {
+		p.recordSend(now.Add(time.Duration(seq) * 2 * time.Millisecond))
+		if seq%4 == 0 {
+			p.recordReply(seq)
+		}
+	}
+	p.sequence = 1000
+	p.checkRateLimit()
+
+	if detected < 120 || detected > 130 {
+		t.Errorf("Expected a rate of about 125/s, got %v", detected)
+	}
+}
+
+func TestCheckRateLimitPaced(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.Interval = time.Second
+	p.AutoPace = true
+	AssertNoError(t, p.SetRate(100))
+	var detected float64
+	p.OnRateLimit = func(rate float64) {
+		detected = rate
+	}
+
+	// Paced at 100/s, one reply every fourth probe
+	for seq := 0; seq < 400; seq++ {
+		if seq%4 == 0 {
+			p.recordReply(seq)
+		}
+	}
+	p.sequence = 400
+	p.checkRateLimit()
+
+	if detected < 24 || detected > 26 {
+		t.Errorf("Expected a rate of about 25/s, got %v", detected)
+	}
+	if p.Interval != time.Second {
+		t.Errorf("Expected %v, got %v", time.Second, p.Interval)
+	}
+}
@@ -0,0 +1,94 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// resolveTimeout bounds the resolution of the target hostname during Run.
+const resolveTimeout = 10 * time.Second
+
+// resolution is the result of the resolution of the target hostname.
+type resolution struct {
+	addrs []net.IPAddr
+	err   error
+}
+
+// SetResolveInterval makes Run resolve the target hostname again every d, so
+// that a pinger running for long against records that change follows them.
+// OnResolve is called when the address pinged changes. The address stays of
+// the same family, and the current one is kept as long as the hostname still
+// resolves to it. Zero or less disables it. It has no effect on the pingers
+// of a PingerPool, and is not available in a pingminimal build.
+func (p *Pinger) SetResolveInterval(d time.Duration) error {
+	if MinimalSyscalls {
+		return ErrMinimalSyscalls
+	}
+	p.resolveInterval = d
+	return nil
+}
+
+// SetResolveAfter makes Run resolve the target hostname again, like
+// SetResolveInterval, every n consecutive echo requests left unanswered.
+// Zero or less disables it. It is not available in a pingminimal build.
+func (p *Pinger) SetResolveAfter(n int) error {
+	if MinimalSyscalls {
+		return ErrMinimalSyscalls
+	}
+	p.resolveAfter = n
+	return nil
+}
+
+// resolve starts the resolution of the target hostname, unless one is in
+// progress or the pinger doesn't resolve it during Run. The result is sent
+// to p.resolved.
+func (p *Pinger) resolve() {
+	if p.resolved == nil || p.resolving {
+		return
+	}
+	p.resolving = true
+	resolved, addr := p.resolved, p.addr
+	go func() {
+		ctx, cancel := context.WithTimeout(p.ctx, resolveTimeout)
+		defer cancel()
+		addrs, err := p.lookup(ctx, addr)
+		resolved <- resolution{addrs: addrs, err: err}
+	}()
+}
+
+// updateAddr pings the first address resolved of the family of the pinger,
+// unless the current one is still valid.
+func (p *Pinger) updateAddr(r resolution) error {
+	p.resolving = false
+	if r.err != nil {
+		return fmt.Errorf("Error resolving %s: %w", p.addr, r.err)
+	}
+	var next *net.IPAddr
+	for i := range r.addrs {
+		addr := &r.addrs[i]
+		if isIPv4(addr.IP) != p.ipv4 {
+			continue
+		}
+		if addr.IP.Equal(p.ipaddr.IP) {
+			return nil
+		}
+		if next == nil {
+			next = addr
+		}
+	}
+	if next == nil {
+		family := "IPv6"
+		if p.ipv4 {
+			family = "IPv4"
+		}
+		return fmt.Errorf("No %s address for %s", family, p.addr)
+	}
+	old := p.ipaddr
+	p.ipaddr = next
+	if handler := p.OnResolve; handler != nil {
+		p.callback(func() { handler(old, next) })
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUpdateAddr(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	var old, new *net.IPAddr
+	p.OnResolve = func(o, n *net.IPAddr) {
+		old, new = o, n
+	}
+	addrs := func(ips ...string) resolution {
+		var r resolution
+		for _, ip := range ips {
+			r.addrs = append(r.addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return r
+	}
+
+	// The current address is still valid
+	AssertNoError(t, p.updateAddr(addrs("127.0.0.2", "::1", "127.0.0.1")))
+	if new != nil {
+		t.Errorf("Expected no new address, got %v", new)
+	}
+
+	AssertNoError(t, p.updateAddr(addrs("::1", "127.0.0.3", "127.0.0.4")))
+	if old == nil || new == nil || old.String() != "127.0.0.1" || new.String() != "127.0.0.3" {
+		t.Errorf("Expected 127.0.0.1 to 127.0.0.3, got %v to %v", old, new)
+	}
+	AssertEqualStrings(t, "127.0.0.3", p.IPAddr().String())
+	AssertEqualStrings(t, "127.0.0.1", p.Addr())
+
+	AssertError(t, p.updateAddr(addrs("::1")), "no IPv4 address")
+	AssertError(t, p.updateAddr(resolution{err: &net.DNSError{Err: "no such host"}}), "DNS error")
+	AssertEqualStrings(t, "127.0.0.3", p.IPAddr().String())
+}
+
+func TestResolveInterval(t *testing.T) {
+	if MinimalSyscalls {
+		t.Skip("Resolving during Run is not available in a pingminimal build, skipping")
+	}
+	p, err := NewPinger(context.Background(), "localhost")
+	AssertNoError(t, err)
+	if !isIPv4(p.IPAddr().IP) {
+		t.Skip("localhost is not an IPv4 address, skipping")
+	}
+	expected := p.IPAddr().String()
+	// A stale address, also answered on the loopback interface
+	p.ipaddr = &net.IPAddr{IP: net.ParseIP("127.0.0.2")}
+	AssertNoError(t, p.SetResolveInterval(10*time.Millisecond))
+	p.SetPrivileged(true)
+	p.Interval = 10 * time.Millisecond
+	p.Timeout = 500 * time.Millisecond
+	var resolved *net.IPAddr
+	p.OnResolve = func(old, new *net.IPAddr) {
+		resolved = new
+		p.Stop()
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if resolved == nil || resolved.String() != expected {
+		t.Errorf("Expected %v, got %v", expected, resolved)
+	}
+}
+
+func TestResolveAfter(t *testing.T) {
+	if MinimalSyscalls {
+		t.Skip("Resolving during Run is not available in a pingminimal build, skipping")
+	}
+	p, err := NewPinger(context.Background(), "localhost")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	// Nothing is answered on the UDP socket
+	p.conn.Close()
+	p.conn, err = net.ListenPacket("udp4", "127.0.0.1:0")
+	AssertNoError(t, err)
+	p.ipaddr = &net.IPAddr{IP: net.ParseIP("127.0.0.2")}
+	AssertNoError(t, p.SetResolveAfter(2))
+	p.Interval = 10 * time.Millisecond
+	p.Timeout = 500 * time.Millisecond
+	p.OnError = func(error) {}
+	var resolved *net.IPAddr
+	p.OnResolve = func(old, new *net.IPAddr) {
+		resolved = new
+		p.Stop()
+	}
+	AssertNoError(t, p.Run(context.Background()))
+	if resolved == nil || p.PacketsSent < 3 {
+		t.Errorf("Expected a resolution after 2 unanswered requests, got %v after %v",
+			resolved, p.PacketsSent)
+	}
+}
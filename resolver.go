@@ -0,0 +1,94 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Resolver resolves the hostnames of targets. *net.Resolver implements it,
+// and programs can implement it to resolve through DNS over HTTPS or their
+// own policies.
+type Resolver interface {
+	// LookupIPAddr returns the IP addresses of host.
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// NewPingerWithResolver returns a new Pinger like NewPinger, resolving addr
+// and later hostnames with r.
+func NewPingerWithResolver(ctx context.Context, addr string, r Resolver) (*Pinger, error) {
+	p := newPinger(ctx)
+	p.resolver = r
+	if err := p.SetAddr(addr); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetResolver sets the resolver of the hostnames passed to SetAddr,
+// SetDualStack and resolved again during Run. Nil resolves them with the
+// system resolver.
+func (p *Pinger) SetResolver(r Resolver) {
+	p.resolver = r
+}
+
+// SetResolveNetwork restricts the resolution of hostnames to the addresses of
+// network: "ip4" for IPv4, "ip6" for IPv6, or "ip" for both, the default.
+// It applies to the following calls to SetAddr and resolutions during Run.
+func (p *Pinger) SetResolveNetwork(network string) error {
+	switch network {
+	case "ip", "ip4", "ip6":
+	default:
+		return fmt.Errorf("Invalid resolve network %q", network)
+	}
+	p.resolveNetwork = network
+	return nil
+}
+
+// lookup returns the addresses of host, of the resolve network.
+func (p *Pinger) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r := p.resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []net.IPAddr
+	for _, addr := range addrs {
+		switch p.resolveNetwork {
+		case "ip4", "ip6":
+			if isIPv4(addr.IP) != (p.resolveNetwork == "ip4") {
+				continue
+			}
+		}
+		filtered = append(filtered, addr)
+	}
+	if len(filtered) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: host}
+	}
+	return filtered, nil
+}
+
+// resolveAddr returns the address of host pinged, an IPv4 address if it has
+// both, as net.ResolveIPAddr does.
+func (p *Pinger) resolveAddr(host string) (*net.IPAddr, error) {
+	network := p.resolveNetwork
+	if network == "" {
+		network = "ip"
+	}
+	if p.resolver == nil {
+		return net.ResolveIPAddr(network, host)
+	}
+	addrs, err := p.lookup(p.ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for i := range addrs {
+		if isIPv4(addrs[i].IP) {
+			return &addrs[i], nil
+		}
+	}
+	return &addrs[0], nil
+}
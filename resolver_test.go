@@ -0,0 +1,55 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// staticResolver resolves every hostname to its addresses.
+type staticResolver []string
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if len(r) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var addrs []net.IPAddr
+	for _, ip := range r {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func TestSetResolver(t *testing.T) {
+	r := staticResolver{"::1", "127.0.0.2"}
+	p, err := NewPingerWithResolver(context.Background(), "example.invalid", r)
+	AssertNoError(t, err)
+	AssertEqualStrings(t, "127.0.0.2", p.IPAddr().String())
+	AssertEqualStrings(t, "example.invalid", p.Addr())
+
+	AssertError(t, p.SetResolveNetwork("tcp"), "invalid network")
+	AssertNoError(t, p.SetResolveNetwork("ip6"))
+	AssertNoError(t, p.SetAddr("example.invalid"))
+	AssertEqualStrings(t, "::1", p.IPAddr().String())
+
+	p.SetResolver(staticResolver{"127.0.0.1"})
+	var dnsErr *net.DNSError
+	if err := p.SetAddr("example.invalid"); !errors.As(err, &dnsErr) {
+		t.Errorf("Expected a DNS error, got %v", err)
+	}
+
+	_, err = NewPingerWithResolver(context.Background(), "example.invalid", staticResolver{})
+	AssertError(t, err, "unknown host")
+}
+
+func TestSetResolverDualStack(t *testing.T) {
+	p, err := NewPingerWithResolver(context.Background(), "example.invalid",
+		staticResolver{"127.0.0.1", "::1"})
+	AssertNoError(t, err)
+	AssertNoError(t, p.SetDualStack(0))
+	if p.dualStack.ipv4.String() != "127.0.0.1" || p.dualStack.ipv6.String() != "::1" {
+		t.Errorf("Expected 127.0.0.1 and ::1, got %v and %v", p.dualStack.ipv4, p.dualStack.ipv6)
+	}
+	AssertEqualStrings(t, "::1", p.IPAddr().String())
+}
@@ -0,0 +1,184 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Responder answers ICMP echo requests in userspace. It is meant to stand up
+// controllable ping targets for lab testing, optionally delaying or dropping
+// replies.
+//
+// Note that the kernel usually answers echo requests on its own as well; on
+// Linux this can be disabled with the net.ipv4.icmp_echo_ignore_all sysctl.
+type Responder struct {
+	// Delay is the wait time before each reply is sent.
+	Delay time.Duration
+
+	// Jitter is the maximum random wait time added to Delay.
+	Jitter time.Duration
+
+	// Loss is the fraction of echo requests, between 0 and 1, that are
+	// dropped without a reply.
+	Loss float64
+
+	// Timestamps enables answering ICMP Timestamp requests over IPv4, for
+	// one-way delay estimation with Pinger.EstimateOWD.
+	Timestamps bool
+
+	// OnRequest is called when Responder receives an echo request, before
+	// deciding whether to answer it.
+	OnRequest func(*Packet)
+
+	conn *icmp.PacketConn
+	ipv4 bool
+}
+
+// NewResponder returns a Responder listening on address for the given
+// network, which is "ip4:icmp" or "ip6:ipv6-icmp" as in icmp.ListenPacket,
+// and requires super-user privileges. Unprivileged ICMP sockets only receive
+// the replies to their own echo requests, so they can't answer any.
+func NewResponder(network, address string) (*Responder, error) {
+	var ipv4 bool
+	switch network {
+	case "ip4:icmp", "ip4:1":
+		ipv4 = true
+	case "ip6:ipv6-icmp", "ip6:58":
+	default:
+		return nil, fmt.Errorf("Unsupported responder network %s, not a raw ICMP socket", network)
+	}
+	conn, err := listenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Responder{conn: conn, ipv4: ipv4}, nil
+}
+
+// LocalAddr returns the local address the Responder is listening on.
+func (r *Responder) LocalAddr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Run answers echo requests until ctx is cancelled or Close is called. It
+// returns the error that stopped it, or nil if ctx was cancelled.
+func (r *Responder) Run(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.conn.Close()
+		case <-done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	proto, reply := protocolIPv6ICMP, icmp.Type(ipv6.ICMPTypeEchoReply)
+	if r.ipv4 {
+		proto, reply = protocolICMP, ipv4.ICMPTypeEchoReply
+	}
+
+	bytes := make([]byte, 1500)
+	for {
+		n, peer, err := r.conn.ReadFrom(bytes)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		received := time.Now()
+
+		m, err := icmp.ParseMessage(proto, bytes[:n])
+		if err != nil {
+			continue
+		}
+		if r.ipv4 && r.Timestamps && m.Type == ipv4.ICMPTypeTimestamp {
+			if body, ok := m.Body.(*icmp.RawBody); ok {
+				data := append([]byte(nil), body.Data...)
+				// Transmitted once delayed
+				r.reply(&wg, peer, func() []byte {
+					return timestampReply(data, received, time.Now())
+				})
+			}
+			continue
+		}
+		if m.Type != ipv4.ICMPTypeEcho && m.Type != ipv6.ICMPTypeEchoRequest {
+			continue
+		}
+		body, ok := m.Body.(*icmp.Echo)
+		if !ok {
+			continue
+		}
+
+		if handler := r.OnRequest; handler != nil {
+			handler(&Packet{
+				IPAddr: addrToIPAddr(peer),
+				RAddr:  peer.String(),
+				Nbytes: n,
+				Seq:    body.Seq,
+			})
+		}
+
+		if r.Loss > 0 && rand.Float64() < r.Loss {
+			continue
+		}
+
+		out, err := (&icmp.Message{
+			Type: reply, Code: 0,
+			Body: &icmp.Echo{
+				ID:   body.ID,
+				Seq:  body.Seq,
+				Data: append([]byte(nil), body.Data...),
+			},
+		}).Marshal(nil)
+		if err != nil {
+			continue
+		}
+
+		r.reply(&wg, peer, func() []byte {
+			return out
+		})
+	}
+}
+
+// reply sends the reply returned by marshal to peer after the configured
+// delay, unless it is nil.
+func (r *Responder) reply(wg *sync.WaitGroup, peer net.Addr, marshal func() []byte) {
+	delay := r.Delay
+	if r.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(r.Jitter)))
+	}
+	wg.Add(1)
+	time.AfterFunc(delay, func() {
+		defer wg.Done()
+		if b := marshal(); b != nil {
+			r.conn.WriteTo(b, peer)
+		}
+	})
+}
+
+// Close stops the Responder and closes its socket.
+func (r *Responder) Close() error {
+	return r.conn.Close()
+}
+
+func addrToIPAddr(addr net.Addr) *net.IPAddr {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a
+	case *net.UDPAddr:
+		return &net.IPAddr{IP: a.IP, Zone: a.Zone}
+	}
+	return nil
+}
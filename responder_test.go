@@ -0,0 +1,86 @@
+package ping
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// respond pings 127.0.0.1 once across a Responder delaying its replies by
+// delay and dropping loss of them, and returns the echo requests it received
+// and the round-trip times of all the replies, the kernel's included.
+func respond(t *testing.T, delay time.Duration, loss float64) ([]*Packet, []time.Duration) {
+	r, err := NewResponder("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	r.Delay = delay
+	r.Loss = loss
+
+	var mu sync.Mutex
+	var requests []*Packet
+	r.OnRequest = func(pkt *Packet) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, pkt)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Run(ctx)
+	}()
+
+	p, err := NewPinger(ctx, "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.Count = 2
+	p.Timeout = delay + 500*time.Millisecond
+	var rtts []time.Duration
+	p.OnRecv = func(pkt *Packet) {
+		rtts = append(rtts, pkt.Rtt)
+	}
+	// The kernel answers too, the reply of the responder is then a
+	// duplicate
+	p.OnDuplicate = p.OnRecv
+	if err := p.Listen(); err != nil {
+		cancel()
+		<-done
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	p.Run(context.Background())
+
+	cancel()
+	AssertNoError(t, <-done)
+	mu.Lock()
+	defer mu.Unlock()
+	return requests, rtts
+}
+
+func TestResponder(t *testing.T) {
+	delay := 100 * time.Millisecond
+	requests, rtts := respond(t, delay, 0)
+	if len(requests) == 0 || requests[0].Seq != 0 {
+		t.Fatalf("Expected an echo request to reach the responder, got %v", requests)
+	}
+	delayed := 0
+	for _, rtt := range rtts {
+		if rtt >= delay {
+			delayed++
+		}
+	}
+	if delayed == 0 {
+		t.Errorf("Expected a reply delayed by %v, got %v", delay, rtts)
+	}
+
+	requests, rtts = respond(t, delay, 1)
+	if len(requests) == 0 {
+		t.Fatalf("Expected an echo request to reach the responder")
+	}
+	for _, rtt := range rtts {
+		if rtt >= delay {
+			t.Errorf("Expected no reply from the responder, got one after %v", rtt)
+		}
+	}
+}
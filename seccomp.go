@@ -0,0 +1,23 @@
+package ping
+
+import "errors"
+
+// MinimalSyscalls reports whether the package was built with the pingminimal
+// build tag. In this mode the probing loop is restricted to the syscalls
+// returned by SyscallAllowlist, so that it can run under a tight seccomp
+// profile: the socket must be opened with Listen before Run, and options
+// that would resolve names, open files or sockets while probing are
+// rejected with ErrMinimalSyscalls.
+const MinimalSyscalls = minimalSyscalls
+
+// ErrMinimalSyscalls is returned for options unavailable in a pingminimal
+// build.
+var ErrMinimalSyscalls = errors.New("Not available in a pingminimal build")
+
+// SyscallAllowlist returns the names of the syscalls made by Run on the
+// socket opened by Listen, including those of the Go runtime, for use in a
+// seccomp profile installed between Listen and Run. It returns nil on
+// platforms without seccomp.
+func SyscallAllowlist() []string {
+	return append([]string(nil), syscallAllowlist...)
+}
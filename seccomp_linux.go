@@ -0,0 +1,14 @@
+package ping
+
+var syscallAllowlist = []string{
+	// Probing
+	"sendto", "recvfrom", "sendmsg", "recvmsg", "recvmmsg", "write", "read", "close",
+	// Network poller and timers
+	"epoll_pwait", "epoll_pwait2", "epoll_wait", "epoll_ctl", "eventfd2",
+	"pipe2", "nanosleep", "clock_nanosleep", "clock_gettime", "gettimeofday",
+	// Go runtime
+	"futex", "sched_yield", "mmap", "munmap", "madvise", "mprotect",
+	"rt_sigaction", "rt_sigprocmask", "rt_sigreturn", "sigaltstack",
+	"getpid", "gettid", "tgkill", "clone", "clone3", "set_robust_list",
+	"getrandom", "exit", "exit_group",
+}
@@ -0,0 +1,5 @@
+//go:build pingminimal
+
+package ping
+
+const minimalSyscalls = true
@@ -0,0 +1,5 @@
+//go:build !linux
+
+package ping
+
+var syscallAllowlist []string
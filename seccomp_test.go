@@ -0,0 +1,51 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSyscallAllowlist(t *testing.T) {
+	allowlist := SyscallAllowlist()
+	if runtime.GOOS != "linux" {
+		if allowlist != nil {
+			t.Errorf("Expected no allowlist on %s, got %v", runtime.GOOS, allowlist)
+		}
+		return
+	}
+
+	found := make(map[string]bool)
+	for _, name := range allowlist {
+		found[name] = true
+	}
+	for _, name := range []string{"sendto", "recvfrom", "futex"} {
+		if !found[name] {
+			t.Errorf("Expected %s in the allowlist", name)
+		}
+	}
+	allowlist[0] = "open"
+	AssertNotEqualStrings(t, "open", SyscallAllowlist()[0])
+}
+
+func TestMinimalSyscalls(t *testing.T) {
+	if !MinimalSyscalls {
+		t.Skip("Not a pingminimal build, skipping")
+	}
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	if err := p.SetWakeOnLAN(mac, ""); err != ErrMinimalSyscalls {
+		t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+	}
+	if err := p.SetResolveInterval(time.Minute); err != ErrMinimalSyscalls {
+		t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+	}
+
+	p.Count = 1
+	if err := p.Run(context.Background()); err == nil {
+		t.Errorf("Expected Run without Listen to fail")
+	}
+}
@@ -0,0 +1,230 @@
+package ping
+
+import (
+	"sync"
+	"time"
+)
+
+// SLA tracks the availability of a target and breaches of a round-trip time
+// threshold over fixed reporting periods, for SLA reporting. Periods are
+// aligned on multiples of Period since the zero time, so a Period of 24h
+// gives UTC days.
+//
+// The target is unavailable while its Pinger reports it as StateDown.
+type SLA struct {
+	// Period is the length of each reporting period, DefaultSLAPeriod if
+	// not positive.
+	Period time.Duration
+
+	// RttThreshold is the round-trip time above which a reply is reported
+	// as a breach. Zero disables breach reporting.
+	RttThreshold time.Duration
+
+	// OnBreach is called when a reply breaches RttThreshold.
+	OnBreach func(*Breach)
+
+	mu        sync.Mutex
+	clock     func() time.Time
+	current   *SLAReport
+	reports   []*SLAReport
+	down      bool
+	downSince time.Time
+}
+
+// SLAReport holds the availability figures of one reporting period.
+type SLAReport struct {
+	// Start and End delimit the reporting period.
+	Start time.Time
+	End   time.Time
+
+	// Availability is the percentage of the period the target was
+	// available, up to now for the current period.
+	Availability float64
+
+	// Downtime is the time the target was unavailable during the period.
+	Downtime time.Duration
+
+	// Outages are the intervals during which the target was unavailable.
+	// The last one has a zero End if the target is still down.
+	Outages []Outage
+
+	// Breaches are the replies that breached the round-trip time threshold.
+	Breaches []Breach
+}
+
+// Outage is an interval during which the target was unavailable.
+type Outage struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Breach is a reply that breached the round-trip time threshold.
+type Breach struct {
+	// Time is when the reply was received.
+	Time time.Time
+
+	// Seq is the sequence number of the reply.
+	Seq int
+
+	// Rtt is the round-trip time of the reply.
+	Rtt time.Duration
+}
+
+// DefaultSLAPeriod is the reporting period of an SLA without a positive
+// Period: a UTC day.
+const DefaultSLAPeriod = 24 * time.Hour
+
+// NewSLA returns an SLA with reporting periods of the given length, or of
+// DefaultSLAPeriod if it isn't positive.
+func NewSLA(period time.Duration) *SLA {
+	if period <= 0 {
+		period = DefaultSLAPeriod
+	}
+	return &SLA{Period: period}
+}
+
+// Attach hooks the SLA into p's OnRecv and OnStateChange callbacks, keeping
+// any callbacks already set. The SLA then reads the time on the clock of p.
+func (s *SLA) Attach(p *Pinger) {
+	s.mu.Lock()
+	s.clock = p.now
+	s.mu.Unlock()
+
+	onRecv := p.OnRecv
+	p.OnRecv = func(pkt *Packet) {
+		if onRecv != nil {
+			onRecv(pkt)
+		}
+		s.recv(s.now(), pkt)
+	}
+
+	onStateChange := p.OnStateChange
+	p.OnStateChange = func(old, new State) {
+		if onStateChange != nil {
+			onStateChange(old, new)
+		}
+		s.stateChange(s.now(), new)
+	}
+}
+
+// Report returns the report of the current period, up to now.
+func (s *SLA) Report() *SLAReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report(s.clockNow())
+}
+
+// Reports returns the reports of the completed periods, oldest first.
+func (s *SLA) Reports() []*SLAReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollover(s.clockNow())
+	return append([]*SLAReport(nil), s.reports...)
+}
+
+// now returns the current time on the clock of the attached pinger.
+func (s *SLA) now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clockNow()
+}
+
+// clockNow is now for callers holding s.mu.
+func (s *SLA) clockNow() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+// period returns the length of the reporting periods.
+func (s *SLA) period() time.Duration {
+	if s.Period <= 0 {
+		return DefaultSLAPeriod
+	}
+	return s.Period
+}
+
+func (s *SLA) report(now time.Time) *SLAReport {
+	s.rollover(now)
+	r := *s.current
+	r.Outages = append([]Outage(nil), r.Outages...)
+	r.Breaches = append([]Breach(nil), r.Breaches...)
+	if s.down {
+		r.Downtime += now.Sub(s.downSince)
+	}
+	r.Availability = availability(r.Downtime, now.Sub(r.Start))
+	return &r
+}
+
+func (s *SLA) recv(now time.Time, pkt *Packet) {
+	if s.RttThreshold <= 0 || pkt.Rtt <= s.RttThreshold {
+		return
+	}
+
+	s.mu.Lock()
+	s.rollover(now)
+	b := Breach{Time: now, Seq: pkt.Seq, Rtt: pkt.Rtt}
+	s.current.Breaches = append(s.current.Breaches, b)
+	s.mu.Unlock()
+
+	if handler := s.OnBreach; handler != nil {
+		handler(&b)
+	}
+}
+
+func (s *SLA) stateChange(now time.Time, state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollover(now)
+
+	switch {
+	case state == StateDown && !s.down:
+		s.down = true
+		s.downSince = now
+		s.current.Outages = append(s.current.Outages, Outage{Start: now})
+	case state != StateDown && s.down:
+		s.closeOutage(now)
+		s.down = false
+	}
+}
+
+// closeOutage ends the ongoing outage of the current period at t.
+func (s *SLA) closeOutage(t time.Time) {
+	s.current.Downtime += t.Sub(s.downSince)
+	if n := len(s.current.Outages); n > 0 {
+		s.current.Outages[n-1].End = t
+	}
+}
+
+// rollover completes the current period, and any period without events
+// since, if now is past its end.
+func (s *SLA) rollover(now time.Time) {
+	period := s.period()
+	if s.current == nil {
+		start := now.Truncate(period)
+		s.current = &SLAReport{Start: start, End: start.Add(period)}
+	}
+
+	for !now.Before(s.current.End) {
+		end := s.current.End
+		if s.down {
+			s.closeOutage(end)
+			s.downSince = end
+		}
+		s.current.Availability = availability(s.current.Downtime, period)
+		s.reports = append(s.reports, s.current)
+
+		s.current = &SLAReport{Start: end, End: end.Add(period)}
+		if s.down {
+			s.current.Outages = []Outage{{Start: end}}
+		}
+	}
+}
+
+func availability(downtime, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 100
+	}
+	return float64(elapsed-downtime) / float64(elapsed) * 100
+}
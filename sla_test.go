@@ -0,0 +1,92 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSLA(t *testing.T) {
+	s := NewSLA(time.Hour)
+	s.RttThreshold = 100 * time.Millisecond
+	var breaches []*Breach
+	s.OnBreach = func(b *Breach) {
+		breaches = append(breaches, b)
+	}
+
+	t0 := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.recv(t0.Add(time.Minute), &Packet{Seq: 1, Rtt: 10 * time.Millisecond})
+	s.recv(t0.Add(2*time.Minute), &Packet{Seq: 2, Rtt: 200 * time.Millisecond})
+	s.stateChange(t0.Add(15*time.Minute), StateDown)
+	s.stateChange(t0.Add(45*time.Minute), StateUp)
+
+	// This outage spans two periods
+	s.stateChange(t0.Add(110*time.Minute), StateDown)
+	r := s.report(t0.Add(130 * time.Minute))
+
+	if len(breaches) != 1 || breaches[0].Seq != 2 {
+		t.Errorf("Expected one breach for seq 2, got %v", breaches)
+	}
+	if len(s.reports) != 2 {
+		t.Fatalf("Expected %v, got %v", 2, len(s.reports))
+	}
+
+	first := s.reports[0]
+	if first.Downtime != 30*time.Minute || first.Availability != 50 {
+		t.Errorf("Expected 30m and 50%%, got %v and %v", first.Downtime,
+			first.Availability)
+	}
+	if len(first.Breaches) != 1 || len(first.Outages) != 1 {
+		t.Errorf("Expected 1 breach and 1 outage, got %v and %v",
+			len(first.Breaches), len(first.Outages))
+	}
+
+	second := s.reports[1]
+	if second.Downtime != 10*time.Minute {
+		t.Errorf("Expected %v, got %v", 10*time.Minute, second.Downtime)
+	}
+	if !second.Outages[0].End.Equal(t0.Add(2 * time.Hour)) {
+		t.Errorf("Expected %v, got %v", t0.Add(2*time.Hour), second.Outages[0].End)
+	}
+
+	if r.Downtime != 10*time.Minute || r.Availability != 0 {
+		t.Errorf("Expected 10m and 0%%, got %v and %v", r.Downtime,
+			r.Availability)
+	}
+	if len(r.Outages) != 1 || !r.Outages[0].End.IsZero() {
+		t.Errorf("Expected one ongoing outage, got %v", r.Outages)
+	}
+}
+
+func TestSLAPeriod(t *testing.T) {
+	t0 := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, s := range []*SLA{NewSLA(0), NewSLA(-time.Hour), {}} {
+		r := s.report(t0)
+		if r.End.Sub(r.Start) != DefaultSLAPeriod {
+			t.Errorf("Expected %v, got %v", DefaultSLAPeriod, r.End.Sub(r.Start))
+		}
+		s.report(t0.Add(2 * DefaultSLAPeriod))
+		if len(s.reports) != 2 {
+			t.Errorf("Expected %v, got %v", 2, len(s.reports))
+		}
+	}
+}
+
+func TestSLAClock(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	clock := &fixedClock{now: time.Date(2017, 1, 1, 0, 30, 0, 0, time.UTC)}
+	p.SetClock(clock)
+	s := NewSLA(time.Hour)
+	s.Attach(p)
+
+	p.OnStateChange(StateUp, StateDown)
+	clock.now = clock.now.Add(10 * time.Minute)
+	r := s.Report()
+	if !r.Start.Equal(clock.now.Truncate(time.Hour)) {
+		t.Errorf("Expected %v, got %v", clock.now.Truncate(time.Hour), r.Start)
+	}
+	if r.Downtime != 10*time.Minute {
+		t.Errorf("Expected %v, got %v", 10*time.Minute, r.Downtime)
+	}
+}
@@ -0,0 +1,157 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ipConn is an ICMP socket, opened by icmp.ListenPacket or listenControl.
+type ipConn interface {
+	net.PacketConn
+	IPv4PacketConn() *ipv4.PacketConn
+	IPv6PacketConn() *ipv6.PacketConn
+}
+
+// controlConn is an ICMP socket opened by listenControl.
+type controlConn struct {
+	net.PacketConn
+	p4 *ipv4.PacketConn
+	p6 *ipv6.PacketConn
+}
+
+func (c *controlConn) IPv4PacketConn() *ipv4.PacketConn { return c.p4 }
+func (c *controlConn) IPv6PacketConn() *ipv6.PacketConn { return c.p6 }
+
+// SetSource sets the local IP address the echo requests are sent from, of
+// the address family of the target. An empty address lets the system pick
+// one. It must be called before Listen or Run, and fails on the pingers of a
+// PingerPool, which share a socket.
+func (p *Pinger) SetSource(addr string) error {
+	if p.shared != nil {
+		return errPooled
+	}
+	if addr == "" {
+		p.source = ""
+		return nil
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return fmt.Errorf("Invalid source address %q", addr)
+	}
+	if ip.Unmap().Is4() != p.ipv4 {
+		return fmt.Errorf("Source address %s is not of the family of %s", addr, p.addr)
+	}
+	p.source = addr
+	return nil
+}
+
+// Source returns the source address set by SetSource.
+func (p *Pinger) Source() string {
+	return p.source
+}
+
+// SetInterface binds the echo requests to the network interface name, so that
+// they leave from it whatever the routing table says, for multi-homed hosts
+// and VRFs. An empty name removes the binding. It must be called before
+// Listen or Run. Binding to an interface is supported on Linux, where
+// privileged pingers may need the CAP_NET_RAW capability, on macOS and on
+// Windows. It fails on the pingers of a PingerPool, like SetSource.
+func (p *Pinger) SetInterface(name string) error {
+	if p.shared != nil {
+		return errPooled
+	}
+	if name == "" {
+		p.iface = nil
+		return nil
+	}
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	p.iface = ifi
+	return nil
+}
+
+// Interface returns the name of the interface set by SetInterface.
+func (p *Pinger) Interface() string {
+	if p.iface == nil {
+		return ""
+	}
+	return p.iface.Name
+}
+
+// listenICMP opens an ICMP socket of the given network, from the pinger's
+// source address, with its socket options. With timestamps, the kernel
+// timestamps the packets received where batchReads is set, for them to be
+// read by a batchReader, unless it can't, in which case they are timed when
+// read.
+func (p *Pinger) listenICMP(network string, timestamps bool) (ipConn, error) {
+	options := p.ipOption != IPOptionNone && p.ipv4
+	timestamps = timestamps && batchReads
+	if !p.dontFragment && p.iface == nil && !options && !timestamps {
+		return listenPacket(network, p.source)
+	}
+	return listenControl(network, p.source, p.ipv4, func(fd uintptr) error {
+		if timestamps {
+			// Best effort
+			_ = setTimestamps(fd)
+		}
+		if p.dontFragment {
+			if err := setDontFragment(fd, p.ipv4); err != nil {
+				return err
+			}
+		}
+		if options {
+			if err := setIPOptions(fd, p.ipOption.marshal()); err != nil {
+				return err
+			}
+		}
+		if p.iface != nil {
+			return bindToInterface(fd, p.iface, p.ipv4)
+		}
+		return nil
+	})
+}
+
+// listenControl opens an ICMP socket like listenPacket, calling control on
+// it before it is bound.
+func listenControl(network, address string, ipv4Conn bool, control func(fd uintptr) error) (ipConn, error) {
+	if !platformSupported {
+		return nil, ErrUnsupportedPlatform
+	}
+	var conn net.PacketConn
+	var err error
+	if network == "udp4" || network == "udp6" {
+		conn, err = listenDgram(ipv4Conn, address, control)
+	} else {
+		lc := net.ListenConfig{Control: rawControl(control)}
+		conn, err = lc.ListenPacket(context.Background(), network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ipv4Conn {
+		return &controlConn{PacketConn: conn, p4: ipv4.NewPacketConn(conn)}, nil
+	}
+	return &controlConn{PacketConn: conn, p6: ipv6.NewPacketConn(conn)}, nil
+}
+
+// rawControl adapts control to the Control function of a net.ListenConfig.
+func rawControl(control func(fd uintptr) error) func(network, address string, c syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		var cerr error
+		err := c.Control(func(fd uintptr) {
+			cerr = control(fd)
+		})
+		if err != nil {
+			return err
+		}
+		return cerr
+	}
+}
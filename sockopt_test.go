@@ -0,0 +1,56 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetSource(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertError(t, p.SetSource("localhost"), "not an address")
+	AssertError(t, p.SetSource("::1"), "IPv6 source of an IPv4 target")
+	AssertNoError(t, p.SetSource("127.0.0.1"))
+	AssertEqualStrings(t, "127.0.0.1", p.Source())
+	AssertNoError(t, p.SetSource(""))
+	AssertEqualStrings(t, "", p.Source())
+
+	p6, err := NewPinger(context.Background(), "::1")
+	AssertNoError(t, err)
+	AssertError(t, p6.SetSource("127.0.0.1"), "IPv4 source of an IPv6 target")
+	AssertNoError(t, p6.SetSource("fe80::1%lo"))
+}
+
+func TestSetInterface(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertError(t, p.SetInterface("nonexistent0"), "unknown interface")
+	AssertEqualStrings(t, "", p.Interface())
+
+	ifaces, err := net.Interfaces()
+	AssertNoError(t, err)
+	var loopback string
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagLoopback != 0 {
+			loopback = ifi.Name
+		}
+	}
+	if loopback == "" {
+		t.Skip("No loopback interface, skipping")
+	}
+	AssertNoError(t, p.SetInterface(loopback))
+	AssertEqualStrings(t, loopback, p.Interface())
+	AssertNoError(t, p.SetSource("127.0.0.1"))
+
+	p.SetPrivileged(true)
+	p.Count = 1
+	p.Timeout = time.Second
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't bind raw ICMP socket to %s, skipping: %s", loopback, err)
+	}
+	if p.PacketsRecv != 1 {
+		t.Errorf("Expected %v, got %v", 1, p.PacketsRecv)
+	}
+}
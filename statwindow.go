@@ -0,0 +1,128 @@
+package ping
+
+import (
+	"math"
+	"time"
+)
+
+// statWindow maintains the statistics of the last echo requests of a pinger,
+// in a ring indexed by sequence number. The mean and variance of the
+// round-trip times are updated incrementally with Welford's algorithm as
+// requests enter and leave the window.
+type statWindow struct {
+	slots []windowSlot
+	sent  int
+	recv  int
+	mean  float64
+	m2    float64
+}
+
+type windowSlot struct {
+	seq     int
+	used    bool
+	replied bool
+	rtt     time.Duration
+}
+
+func newStatWindow(n int) *statWindow {
+	return &statWindow{slots: make([]windowSlot, n)}
+}
+
+// clone returns an empty window of the same size, nil for a nil window.
+func (w *statWindow) clone() *statWindow {
+	if w == nil {
+		return nil
+	}
+	return newStatWindow(len(w.slots))
+}
+
+// SetStatWindow makes Statistics cover only the last n echo requests, in
+// memory bounded by n, instead of all of them. The pinger then retains
+// neither all the round-trip times nor the outcome of every request.
+// Statistics returns the round-trip times of the window, but no Probes.
+// Zero or less covers all the requests again. It must be called before Run.
+func (p *Pinger) SetStatWindow(n int) {
+	if n <= 0 {
+		p.window = nil
+		return
+	}
+	p.window = newStatWindow(n)
+	p.rtts = nil
+	p.results = nil
+}
+
+// send enters echo request seq in the window, evicting the oldest one.
+func (w *statWindow) send(seq int) {
+	slot := &w.slots[seq%len(w.slots)]
+	if slot.used {
+		if slot.replied {
+			w.remove(slot.rtt)
+		}
+	} else {
+		w.sent++
+	}
+	*slot = windowSlot{seq: seq, used: true}
+}
+
+// reply records the reply to echo request seq, if it's still in the window.
+func (w *statWindow) reply(seq int, rtt time.Duration) {
+	slot := &w.slots[seq%len(w.slots)]
+	if !slot.used || slot.seq != seq || slot.replied {
+		return
+	}
+	slot.replied = true
+	slot.rtt = rtt
+	w.recv++
+	delta := float64(rtt) - w.mean
+	w.mean += delta / float64(w.recv)
+	w.m2 += delta * (float64(rtt) - w.mean)
+}
+
+func (w *statWindow) remove(rtt time.Duration) {
+	if w.recv == 1 {
+		w.recv, w.mean, w.m2 = 0, 0, 0
+		return
+	}
+	old := w.mean
+	w.mean = (float64(w.recv)*old - float64(rtt)) / float64(w.recv-1)
+	w.m2 -= (float64(rtt) - old) * (float64(rtt) - w.mean)
+	if w.m2 < 0 {
+		// Rounding
+		w.m2 = 0
+	}
+	w.recv--
+}
+
+// statistics returns the statistics of the window, with the round-trip
+// times in sequence order.
+func (w *statWindow) statistics() *Statistics {
+	s := &Statistics{
+		PacketsSent: w.sent,
+		PacketsRecv: w.recv,
+		PacketLoss:  packetLoss(w.sent, w.recv),
+	}
+	oldest := -1
+	for i, slot := range w.slots {
+		if slot.used && (oldest < 0 || slot.seq < w.slots[oldest].seq) {
+			oldest = i
+		}
+	}
+	for i := 0; oldest >= 0 && i < len(w.slots); i++ {
+		slot := w.slots[(oldest+i)%len(w.slots)]
+		if !slot.replied {
+			continue
+		}
+		if len(s.Rtts) == 0 || slot.rtt < s.MinRtt {
+			s.MinRtt = slot.rtt
+		}
+		if slot.rtt > s.MaxRtt {
+			s.MaxRtt = slot.rtt
+		}
+		s.Rtts = append(s.Rtts, slot.rtt)
+	}
+	if w.recv > 0 {
+		s.AvgRtt = time.Duration(w.mean)
+		s.StdDevRtt = time.Duration(math.Sqrt(w.m2 / float64(w.recv)))
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package ping
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStatWindow(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetStatWindow(3)
+
+	rtts := rttsOf(10, 20, 30, 0, 50, 60)
+	for seq, rtt := range rtts {
+		p.window.send(seq)
+		p.trackSent(seq, time.Now())
+		if rtt != 0 {
+			p.PacketsRecv++
+			p.recordRtt(rtt)
+			p.window.reply(seq, rtt)
+		}
+	}
+	// A reply to a request out of the window is ignored
+	p.window.reply(1, time.Second)
+
+	s := p.Statistics()
+	expected := statistics(3, 2, rttsOf(50, 60))
+	if s.PacketsSent != 3 || s.PacketsRecv != 2 {
+		t.Errorf("Expected %v/%v, got %v/%v", 3, 2, s.PacketsSent, s.PacketsRecv)
+	}
+	if math.Abs(s.PacketLoss-expected.PacketLoss) > 1e-9 {
+		t.Errorf("Expected %v, got %v", expected.PacketLoss, s.PacketLoss)
+	}
+	for _, d := range [][2]time.Duration{
+		{expected.MinRtt, s.MinRtt},
+		{expected.MaxRtt, s.MaxRtt},
+		{expected.AvgRtt, s.AvgRtt},
+		{expected.StdDevRtt, s.StdDevRtt},
+		{expected.MedianRtt, s.MedianRtt},
+	} {
+		if diff := d[0] - d[1]; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("Expected %v, got %v", d[0], d[1])
+		}
+	}
+	if len(s.Rtts) != 2 || s.Rtts[0] != 50*time.Millisecond {
+		t.Errorf("Expected %v, got %v", expected.Rtts, s.Rtts)
+	}
+	if p.rtts != nil || s.Probes != nil {
+		t.Errorf("Expected the round-trip times and probes not to be retained")
+	}
+}
+
+func TestSnapshotStatistics(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	p.SetStatWindow(10)
+	p.Interval = time.Millisecond
+	p.Timeout = 200 * time.Millisecond
+	if err := p.Listen(); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background())
+	}()
+	var last *Statistics
+	for running := true; running; {
+		select {
+		case err := <-done:
+			AssertNoError(t, err)
+			running = false
+		default:
+			last = p.SnapshotStatistics()
+		}
+	}
+	if last.PacketsSent > 10 || last.PacketsRecv > last.PacketsSent {
+		t.Errorf("Expected at most 10 requests in the window, got %v/%v",
+			last.PacketsRecv, last.PacketsSent)
+	}
+}
@@ -0,0 +1,115 @@
+package ping
+
+import (
+	"math"
+	"time"
+)
+
+// histogramBuckets is the number of buckets of the round-trip time
+// histogram: 4 per power of two from 1µs, up to about 71 minutes.
+const histogramBuckets = 128
+
+// HistogramBucket is a bucket of a round-trip time histogram.
+type HistogramBucket struct {
+	// UpperBound is the largest round-trip time counted in the bucket. The
+	// smallest is the upper bound of the previous bucket.
+	UpperBound time.Duration
+
+	// Count is the number of round-trip times in the bucket.
+	Count int
+}
+
+// rttStream aggregates round-trip times in constant memory, with Welford's
+// online algorithm for the mean and variance and a logarithmic histogram
+// for their distribution.
+type rttStream struct {
+	Count   int                   `json:"count"`
+	Mean    float64               `json:"mean"`
+	M2      float64               `json:"m2"`
+	Min     time.Duration         `json:"min"`
+	Max     time.Duration         `json:"max"`
+	Buckets [histogramBuckets]int `json:"buckets"`
+}
+
+func (s *rttStream) add(rtt time.Duration) {
+	if s.Count == 0 || rtt < s.Min {
+		s.Min = rtt
+	}
+	if s.Count == 0 || rtt > s.Max {
+		s.Max = rtt
+	}
+	s.Count++
+	delta := float64(rtt) - s.Mean
+	s.Mean += delta / float64(s.Count)
+	s.M2 += delta * (float64(rtt) - s.Mean)
+	s.Buckets[bucket(rtt)]++
+}
+
+// fill sets the round-trip time statistics of stats from the stream.
+func (s *rttStream) fill(stats *Statistics) {
+	if s.Count == 0 {
+		return
+	}
+	stats.MinRtt = s.Min
+	stats.MaxRtt = s.Max
+	stats.AvgRtt = time.Duration(s.Mean)
+	stats.StdDevRtt = time.Duration(math.Sqrt(s.M2 / float64(s.Count)))
+	for i, n := range s.Buckets {
+		if n > 0 {
+			stats.Histogram = append(stats.Histogram, HistogramBucket{
+				UpperBound: bucketBound(i),
+				Count:      n,
+			})
+		}
+	}
+}
+
+// bucket returns the histogram bucket of rtt.
+func bucket(rtt time.Duration) int {
+	if rtt <= time.Microsecond {
+		return 0
+	}
+	i := int(math.Ceil(4 * math.Log2(float64(rtt)/float64(time.Microsecond))))
+	if i >= histogramBuckets {
+		return histogramBuckets - 1
+	}
+	return i
+}
+
+// bucketBound returns the upper bound of histogram bucket i.
+func bucketBound(i int) time.Duration {
+	if i == histogramBuckets-1 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(float64(time.Microsecond) * math.Exp2(float64(i)/4))
+}
+
+// recordRtt retains rtt, or aggregates it once the retained round-trip times
+// and probe outcomes exceed MemoryBudget.
+func (p *Pinger) recordRtt(rtt time.Duration) {
+	if p.window != nil {
+		// Kept by the window
+		return
+	}
+	if p.stream != nil {
+		p.stream.add(rtt)
+		return
+	}
+	p.rtts = append(p.rtts, rtt)
+	p.checkMemoryBudget()
+}
+
+// checkMemoryBudget aggregates the round-trip times and drops the probe
+// outcomes once they exceed MemoryBudget.
+func (p *Pinger) checkMemoryBudget() {
+	if p.MemoryBudget <= 0 || p.stream != nil ||
+		len(p.rtts)*8+len(p.results)*probeResultSize <= p.MemoryBudget {
+		return
+	}
+	p.stream = &rttStream{}
+	for _, rtt := range p.rtts {
+		p.stream.add(rtt)
+	}
+	p.rtts = nil
+	p.results = nil
+}
@@ -0,0 +1,108 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.MemoryBudget = 80
+
+	var rtts []time.Duration
+	for i := 1; i <= 100; i++ {
+		rtt := time.Duration(i*i) * 10 * time.Microsecond
+		rtts = append(rtts, rtt)
+		p.recordRtt(rtt)
+		if i == 10 && p.stream != nil {
+			t.Fatalf("Expected 10 round-trip times to fit in the budget")
+		}
+	}
+	if p.rtts != nil {
+		t.Fatalf("Expected the round-trip times to be aggregated, got %d", len(p.rtts))
+	}
+
+	p.PacketsSent, p.PacketsRecv = 100, 100
+	stats := p.Statistics()
+	expected := statistics(100, 100, rtts)
+	if stats.Rtts != nil {
+		t.Errorf("Expected no Rtts, got %d", len(stats.Rtts))
+	}
+	if stats.MinRtt != expected.MinRtt || stats.MaxRtt != expected.MaxRtt {
+		t.Errorf("Expected %v-%v, got %v-%v", expected.MinRtt, expected.MaxRtt,
+			stats.MinRtt, stats.MaxRtt)
+	}
+	for _, d := range [][2]time.Duration{
+		{expected.AvgRtt, stats.AvgRtt},
+		{expected.StdDevRtt, stats.StdDevRtt},
+	} {
+		if diff := d[0] - d[1]; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("Expected %v, got %v", d[0], d[1])
+		}
+	}
+
+	count := 0
+	var bound time.Duration
+	for _, b := range stats.Histogram {
+		if b.UpperBound <= bound {
+			t.Errorf("Expected increasing bucket bounds, got %v after %v",
+				b.UpperBound, bound)
+		}
+		bound = b.UpperBound
+		count += b.Count
+	}
+	if count != 100 {
+		t.Errorf("Expected %v, got %v", 100, count)
+	}
+
+	var buf bytes.Buffer
+	AssertNoError(t, p.Save(&buf))
+	restored, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertNoError(t, restored.Load(&buf))
+	if s := restored.Statistics(); s.AvgRtt != stats.AvgRtt || len(s.Histogram) != len(stats.Histogram) {
+		t.Errorf("Expected the aggregate to be restored, got %v", s)
+	}
+}
+
+func TestMemoryBudgetProbes(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.MemoryBudget = 10 * probeResultSize
+
+	// Unanswered echo requests count as well
+	start := time.Now()
+	for seq := 0; seq < 100; seq++ {
+		p.trackSent(seq, start.Add(time.Duration(seq)*time.Second))
+		p.PacketsSent++
+		if seq == 9 && p.stream != nil {
+			t.Fatalf("Expected 10 probe outcomes to fit in the budget")
+		}
+	}
+	if p.results != nil {
+		t.Fatalf("Expected the probe outcomes to be dropped, got %d", len(p.results))
+	}
+	stats := p.Statistics()
+	if stats.Probes != nil || stats.Rtts != nil {
+		t.Errorf("Expected no Probes nor Rtts, got %d and %d", len(stats.Probes), len(stats.Rtts))
+	}
+	if stats.PacketsSent != 100 {
+		t.Errorf("Expected %v, got %v", 100, stats.PacketsSent)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	for _, rtt := range []time.Duration{0, time.Microsecond, 1500 * time.Microsecond,
+		time.Second, 100 * time.Hour} {
+		i := bucket(rtt)
+		if rtt > bucketBound(i) {
+			t.Errorf("Expected %v <= %v", rtt, bucketBound(i))
+		}
+		if i > 0 && rtt <= bucketBound(i-1) {
+			t.Errorf("Expected %v > %v", rtt, bucketBound(i-1))
+		}
+	}
+}
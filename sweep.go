@@ -0,0 +1,170 @@
+package ping
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SizeStatistics are the statistics of the echo requests of one size, in
+// size sweep mode.
+type SizeStatistics struct {
+	// Size is the size of the data of the echo requests.
+	Size int
+
+	// PacketsSent and PacketsRecv are the number of echo requests of the
+	// size sent, and of replies to them received.
+	PacketsSent int
+	PacketsRecv int
+
+	// PacketLoss is the percentage of the echo requests of the size lost.
+	PacketLoss float64
+
+	// MinRtt, AvgRtt and MaxRtt are the minimum, average and maximum
+	// round-trip times of the replies to them.
+	MinRtt time.Duration
+	AvgRtt time.Duration
+	MaxRtt time.Duration
+}
+
+// SetSizeSweep makes successive echo requests grow the size of their data
+// from min to max bytes, by step, starting over from min after max, like the
+// -g, -G and -h options of ping on BSD. It finds size-dependent loss and MTU
+// black holes, the statistics of each size being reported in the Sizes of
+// Statistics. The size of an echo request follows from its sequence number.
+// It replaces the size set by SetSize, and SetSize disables it.
+func (p *Pinger) SetSizeSweep(min, max, step int) error {
+	if min < timeSliceLength {
+		return fmt.Errorf("Invalid sweep minimum %d, less than %d bytes", min, timeSliceLength)
+	}
+	if max < min || step < 1 {
+		return fmt.Errorf("Invalid sweep from %d to %d by %d", min, max, step)
+	}
+	p.sweepMin, p.sweepMax, p.sweepStep = min, max, step
+	return nil
+}
+
+// SizeSweep returns the sweep set by SetSizeSweep, a zero step if there is
+// none.
+func (p *Pinger) SizeSweep() (min, max, step int) {
+	return p.sweepMin, p.sweepMax, p.sweepStep
+}
+
+// sizeOf returns the size of the data of echo request seq. Sequence numbers
+// are 16 bits on the wire, so that the size of a reply can be told from it.
+func (p *Pinger) sizeOf(seq int) int {
+	if p.sweepStep == 0 {
+		return p.size
+	}
+	sizes := (p.sweepMax-p.sweepMin)/p.sweepStep + 1
+	return p.sweepMin + (seq&0xffff)%sizes*p.sweepStep
+}
+
+// maxSize returns the size of the data of the largest echo requests.
+func (p *Pinger) maxSize() int {
+	if p.sweepStep == 0 {
+		return p.size
+	}
+	return p.sizeOf((p.sweepMax - p.sweepMin) / p.sweepStep)
+}
+
+// sizeCounters accumulate the statistics of a size.
+type sizeCounters struct {
+	sent, recv int
+	min, max   time.Duration
+	total      time.Duration
+}
+
+// recordSizeSent records echo request seq sent, in size sweep mode.
+func (p *Pinger) recordSizeSent(seq int) {
+	if p.sweepStep == 0 {
+		return
+	}
+	if p.sizes == nil {
+		p.sizes = make(map[int]*sizeCounters)
+	}
+	size := p.sizeOf(seq)
+	c := p.sizes[size]
+	if c == nil {
+		c = &sizeCounters{}
+		p.sizes[size] = c
+	}
+	c.sent++
+}
+
+// recordSizeReply records the reply to echo request seq, in size sweep
+// mode.
+func (p *Pinger) recordSizeReply(seq int, rtt time.Duration) {
+	c := p.sizes[p.sizeOf(seq)]
+	if p.sweepStep == 0 || c == nil {
+		return
+	}
+	if c.recv == 0 || rtt < c.min {
+		c.min = rtt
+	}
+	if rtt > c.max {
+		c.max = rtt
+	}
+	c.recv++
+	c.total += rtt
+}
+
+// sizeStatistics returns the statistics of each size swept, by size.
+func (p *Pinger) sizeStatistics() []SizeStatistics {
+	var stats []SizeStatistics
+	for size, c := range p.sizes {
+		s := SizeStatistics{
+			Size:        size,
+			PacketsSent: c.sent,
+			PacketsRecv: c.recv,
+			MinRtt:      c.min,
+			MaxRtt:      c.max,
+		}
+		s.setLoss()
+		if c.recv > 0 {
+			s.AvgRtt = c.total / time.Duration(c.recv)
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Size < stats[j].Size
+	})
+	return stats
+}
+
+// setLoss computes the packet loss from the packet counts.
+func (s *SizeStatistics) setLoss() {
+	s.PacketLoss = 0
+	if s.PacketsSent > 0 && s.PacketsRecv < s.PacketsSent {
+		s.PacketLoss = float64(s.PacketsSent-s.PacketsRecv) / float64(s.PacketsSent) * 100
+	}
+}
+
+// mergeSizes merges the statistics of the sizes of b into a.
+func mergeSizes(a, b []SizeStatistics) []SizeStatistics {
+	merged := append([]SizeStatistics(nil), a...)
+	for _, other := range b {
+		i := sort.Search(len(merged), func(i int) bool {
+			return merged[i].Size >= other.Size
+		})
+		if i == len(merged) || merged[i].Size != other.Size {
+			merged = append(merged[:i], append([]SizeStatistics{other}, merged[i:]...)...)
+			continue
+		}
+		s := &merged[i]
+		if s.PacketsRecv == 0 || (other.PacketsRecv > 0 && other.MinRtt < s.MinRtt) {
+			s.MinRtt = other.MinRtt
+		}
+		if other.MaxRtt > s.MaxRtt {
+			s.MaxRtt = other.MaxRtt
+		}
+		if recv := s.PacketsRecv + other.PacketsRecv; recv > 0 {
+			s.AvgRtt = time.Duration((float64(s.AvgRtt)*float64(s.PacketsRecv) +
+				float64(other.AvgRtt)*float64(other.PacketsRecv)) / float64(recv))
+		}
+		s.PacketsSent += other.PacketsSent
+		s.PacketsRecv += other.PacketsRecv
+		s.setLoss()
+	}
+	return merged
+}
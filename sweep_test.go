@@ -0,0 +1,91 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSetSizeSweep(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertError(t, p.SetSizeSweep(4, 100, 10), "minimum below the timestamp")
+	AssertError(t, p.SetSizeSweep(100, 50, 10), "maximum below the minimum")
+	AssertError(t, p.SetSizeSweep(8, 100, 0), "zero step")
+
+	AssertNoError(t, p.SetSizeSweep(8, 30, 10))
+	for seq, size := range []int{8, 18, 28, 8, 18} {
+		if got := p.sizeOf(seq); got != size {
+			t.Errorf("Expected %v, got %v", size, got)
+		}
+	}
+	if p.maxSize() != 28 {
+		t.Errorf("Expected %v, got %v", 28, p.maxSize())
+	}
+	// The sizes follow the sequence numbers of the replies
+	if p.sizeOf(0x10001) != p.sizeOf(1) {
+		t.Errorf("Expected %v, got %v", p.sizeOf(1), p.sizeOf(0x10001))
+	}
+
+	p.SetSize(16)
+	if _, _, step := p.SizeSweep(); step != 0 || p.sizeOf(1) != 16 {
+		t.Errorf("Expected SetSize to disable the sweep, got step %v", step)
+	}
+}
+
+func TestMergeSizes(t *testing.T) {
+	a := []SizeStatistics{{Size: 8, PacketsSent: 2, PacketsRecv: 2,
+		MinRtt: 2 * time.Millisecond, AvgRtt: 3 * time.Millisecond, MaxRtt: 4 * time.Millisecond}}
+	b := []SizeStatistics{
+		{Size: 8, PacketsSent: 2, PacketsRecv: 1,
+			MinRtt: time.Millisecond, AvgRtt: 6 * time.Millisecond, MaxRtt: 6 * time.Millisecond},
+		{Size: 4, PacketsSent: 1, PacketLoss: 100},
+	}
+	merged := mergeSizes(a, b)
+	if len(merged) != 2 || merged[0].Size != 4 || merged[1].Size != 8 {
+		t.Fatalf("Expected sizes 4 and 8, got %+v", merged)
+	}
+	s := merged[1]
+	if s.PacketsSent != 4 || s.PacketsRecv != 3 || s.PacketLoss != 25 ||
+		s.MinRtt != time.Millisecond || s.AvgRtt != 4*time.Millisecond ||
+		s.MaxRtt != 6*time.Millisecond {
+		t.Errorf("Expected the merged statistics of size 8, got %+v", s)
+	}
+	if a[0].PacketsSent != 2 {
+		t.Errorf("Expected %v, got %v", 2, a[0].PacketsSent)
+	}
+}
+
+func TestSizeSweep(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetPrivileged(true)
+	AssertNoError(t, p.SetSizeSweep(8, 1008, 500))
+	p.Count = 6
+	p.Interval = 10 * time.Millisecond
+	p.Timeout = 2 * time.Second
+	var sizes []int
+	p.OnRecv = func(pkt *Packet) {
+		sizes = append(sizes, len(pkt.Payload)+timeSliceLength)
+	}
+	p.OnError = func(err error) {
+		t.Errorf("Expected no error, got %s", err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	for i, size := range sizes {
+		if size != p.sizeOf(i) {
+			t.Errorf("Expected %v, got %v", p.sizeOf(i), size)
+		}
+	}
+	stats := p.Statistics()
+	if len(stats.Sizes) != 3 {
+		t.Fatalf("Expected %v sizes, got %+v", 3, stats.Sizes)
+	}
+	for i, s := range stats.Sizes {
+		if s.Size != 8+500*i || s.PacketsRecv != 2 || s.MinRtt == 0 {
+			t.Errorf("Expected 2 replies of %v bytes, got %+v", 8+500*i, s)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+//go:build !windows && !plan9
+
+package ping
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// SyslogSink writes periodic summaries and state changes of a Pinger to
+// syslog. Messages are written with the facility and severity given when the
+// sink was created.
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink returns a SyslogSink connected to the local syslog daemon.
+// priority is a combination of a syslog facility and severity.
+func NewSyslogSink(priority syslog.Priority, tag string) (*SyslogSink, error) {
+	w, err := syslog.New(priority, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+// DialSyslogSink returns a SyslogSink connected to the syslog daemon at raddr
+// on the given network, see syslog.Dial.
+func DialSyslogSink(network, raddr string, priority syslog.Priority, tag string) (*SyslogSink, error) {
+	w, err := syslog.Dial(network, raddr, priority, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+// Attach hooks the sink into p's OnSummary and OnStateChange callbacks,
+// keeping any callbacks already set. SummaryInterval must be set on p for
+// summaries to be written.
+func (s *SyslogSink) Attach(p *Pinger) {
+	onSummary := p.OnSummary
+	p.OnSummary = func(stats *Statistics) {
+		if onSummary != nil {
+			onSummary(stats)
+		}
+		s.Summary(stats)
+	}
+
+	onStateChange := p.OnStateChange
+	p.OnStateChange = func(old, new State) {
+		if onStateChange != nil {
+			onStateChange(old, new)
+		}
+		s.StateChange(p.Addr(), old, new)
+	}
+}
+
+// Summary writes stats to syslog.
+func (s *SyslogSink) Summary(stats *Statistics) error {
+	_, err := fmt.Fprintf(s.w, "host=%s addr=%s sent=%d recv=%d loss=%v%% "+
+		"rtt_min=%v rtt_avg=%v rtt_max=%v rtt_stddev=%v",
+		stats.Addr, stats.IPAddr, stats.PacketsSent, stats.PacketsRecv,
+		stats.PacketLoss, stats.MinRtt, stats.AvgRtt, stats.MaxRtt,
+		stats.StdDevRtt)
+	return err
+}
+
+// StateChange writes a state transition of the host addr to syslog.
+func (s *SyslogSink) StateChange(addr string, old, new State) error {
+	_, err := fmt.Fprintf(s.w, "host=%s state=%s previous=%s", addr, new, old)
+	return err
+}
+
+// Close closes the connection to the syslog daemon.
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,57 @@
+//go:build !windows && !plan9
+
+package ping
+
+import (
+	"fmt"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogSink(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	AssertNoError(t, err)
+	defer l.Close()
+	priority := syslog.LOG_DAEMON | syslog.LOG_NOTICE
+	s, err := DialSyslogSink("udp", l.LocalAddr().String(), priority, "goping")
+	AssertNoError(t, err)
+	defer s.Close()
+
+	read := func() string {
+		b := make([]byte, 1024)
+		l.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := l.ReadFrom(b)
+		AssertNoError(t, err)
+		return string(b[:n])
+	}
+	header := fmt.Sprintf("<%d>", priority)
+
+	AssertNoError(t, s.Summary(&Statistics{
+		Addr: "example.com", IPAddr: &net.IPAddr{IP: net.ParseIP("192.0.2.1")},
+		PacketsSent: 4, PacketsRecv: 3, PacketLoss: 25,
+		MinRtt: time.Millisecond, AvgRtt: 2 * time.Millisecond,
+		MaxRtt: 3 * time.Millisecond, StdDevRtt: time.Millisecond,
+	}))
+	msg := read()
+	if !strings.HasPrefix(msg, header) || !strings.Contains(msg, " goping[") {
+		t.Errorf("Expected a message of priority %s tagged goping, got %q", header, msg)
+	}
+	want := "host=example.com addr=192.0.2.1 sent=4 recv=3 loss=25% " +
+		"rtt_min=1ms rtt_avg=2ms rtt_max=3ms rtt_stddev=1ms"
+	if !strings.HasSuffix(strings.TrimSpace(msg), want) {
+		t.Errorf("Expected %q, got %q", want, msg)
+	}
+
+	AssertNoError(t, s.StateChange("example.com", StateUp, StateDown))
+	msg = read()
+	if !strings.HasPrefix(msg, header) {
+		t.Errorf("Expected a message of priority %s, got %q", header, msg)
+	}
+	want = fmt.Sprintf("host=example.com state=%s previous=%s", StateDown, StateUp)
+	if !strings.HasSuffix(strings.TrimSpace(msg), want) {
+		t.Errorf("Expected %q, got %q", want, msg)
+	}
+}
@@ -0,0 +1,38 @@
+package ping
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// batchReads is whether the packets received on ICMP sockets are read in
+// batches, with recvmmsg, and timestamped by the kernel.
+const batchReads = true
+
+// timestampSpace is the room for a receive timestamp in the control messages
+// of a packet.
+var timestampSpace = syscall.CmsgSpace(int(unsafe.Sizeof(syscall.Timespec{})))
+
+// setTimestamps makes the kernel timestamp the packets received on socket fd.
+func setTimestamps(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, 1)
+}
+
+// parseTimestamp returns the time a packet was received at, from its control
+// messages, or the zero time if the kernel didn't timestamp it.
+func parseTimestamp(oob []byte) time.Time {
+	ms, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}
+	}
+	for _, m := range ms {
+		if m.Header.Level != syscall.SOL_SOCKET || m.Header.Type != syscall.SCM_TIMESTAMPNS ||
+			len(m.Data) < int(unsafe.Sizeof(syscall.Timespec{})) {
+			continue
+		}
+		ts := (*syscall.Timespec)(unsafe.Pointer(&m.Data[0]))
+		return time.Unix(ts.Unix())
+	}
+	return time.Time{}
+}
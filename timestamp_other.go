@@ -0,0 +1,17 @@
+//go:build !linux
+
+package ping
+
+import "time"
+
+const batchReads = false
+
+var timestampSpace = 0
+
+func setTimestamps(fd uintptr) error {
+	return ErrUnsupportedPlatform
+}
+
+func parseTimestamp(oob []byte) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,204 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Hop is a router on the path to a traced target, or the target itself.
+type Hop struct {
+	// TTL is the TTL, or hop limit for IPv6, of the probes that reached the
+	// hop.
+	TTL int
+
+	// Addr is the address of the first answer, empty if no probe was
+	// answered.
+	Addr string
+
+	// Addrs are all the addresses that answered, which differ when the
+	// path is load balanced.
+	Addrs []string
+
+	// Statistics are the round-trip times and loss of the probes.
+	Statistics *Statistics
+}
+
+// Tracer discovers the hops to the target of a Pinger by sending echo
+// requests with increasing TTLs, and collecting the ICMP Time Exceeded
+// errors of the routers they expire at, as traceroute does.
+type Tracer struct {
+	// MaxHops is the largest TTL probed. Default is 30.
+	MaxHops int
+
+	// Probes is the number of probes sent to each hop. Default is 3.
+	Probes int
+
+	// Timeout is how long to wait for the answer to each probe. Default is
+	// 1s.
+	Timeout time.Duration
+
+	// OnHop is called with each hop once its probes are done.
+	OnHop func(*Hop)
+
+	p *Pinger
+}
+
+// NewTracer returns a Tracer to the target of p. Tracing requires p to be
+// privileged, as unprivileged sockets don't receive the errors of routers.
+func NewTracer(p *Pinger) *Tracer {
+	return &Tracer{
+		MaxHops: 30,
+		Probes:  3,
+		Timeout: time.Second,
+		p:       p,
+	}
+}
+
+// Traceroute traces the hops to the target with a Tracer with the default
+// options.
+func (p *Pinger) Traceroute(ctx context.Context) ([]*Hop, error) {
+	return NewTracer(p).Run(ctx)
+}
+
+// traceAnswer is what a probe was answered with.
+type traceAnswer int
+
+const (
+	// Time Exceeded from a router
+	traceExpired traceAnswer = iota
+	// Echo reply from the target
+	traceReached
+	// Destination Unreachable, the trace can't go further
+	traceUnreachable
+)
+
+// Run traces the hops to the target, until it answers, a hop reports it
+// unreachable, or MaxHops is reached. This is a blocking function. It is not
+// available in a pingminimal build, as it opens its own socket.
+func (t *Tracer) Run(ctx context.Context) ([]*Hop, error) {
+	if MinimalSyscalls {
+		return nil, ErrMinimalSyscalls
+	}
+	p := t.p
+	if !p.Privileged() {
+		return nil, errors.New("Tracing requires a privileged pinger")
+	}
+	proto := ipv6Proto[p.network]
+	if p.ipv4 {
+		proto = ipv4Proto[p.network]
+	}
+	conn, err := p.listenICMP(proto, false)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var hops []*Hop
+	bytes := make([]byte, 1500)
+	for ttl := 1; ttl <= t.MaxHops; ttl++ {
+		if p.ipv4 {
+			err = conn.IPv4PacketConn().SetTTL(ttl)
+		} else {
+			err = conn.IPv6PacketConn().SetHopLimit(ttl)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		hop := &Hop{TTL: ttl}
+		var rtts []time.Duration
+		done := false
+		for i := 0; i < t.Probes; i++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			seq := (ttl*t.Probes + i) & 0xffff
+			msg, dst, err := icmpProber{}.Marshal(p, seq)
+			if err != nil {
+				return nil, err
+			}
+			sent := time.Now()
+			if _, err := conn.WriteTo(msg, dst); err != nil {
+				return nil, err
+			}
+
+			conn.SetReadDeadline(sent.Add(t.Timeout))
+			for {
+				n, peer, err := conn.ReadFrom(bytes)
+				if err != nil {
+					break
+				}
+				answer, ok := p.parseTraceAnswer(bytes[:n], seq)
+				if !ok {
+					continue
+				}
+				rtts = append(rtts, time.Since(sent))
+				hop.addAddr(addrIP(peer).String())
+				if answer != traceExpired {
+					done = true
+				}
+				break
+			}
+		}
+
+		hop.Statistics = statistics(t.Probes, len(rtts), rtts)
+		hop.Statistics.Addr = hop.Addr
+		hops = append(hops, hop)
+		if handler := t.OnHop; handler != nil {
+			handler(hop)
+		}
+		if done {
+			break
+		}
+	}
+	return hops, nil
+}
+
+func (h *Hop) addAddr(addr string) {
+	if h.Addr == "" {
+		h.Addr = addr
+	}
+	for _, a := range h.Addrs {
+		if a == addr {
+			return
+		}
+	}
+	h.Addrs = append(h.Addrs, addr)
+}
+
+// parseTraceAnswer returns what b answers, if it answers our probe seq: an
+// echo reply, or an ICMP error quoting the probe.
+func (p *Pinger) parseTraceAnswer(b []byte, seq int) (traceAnswer, bool) {
+	proto := protocolIPv6ICMP
+	if p.ipv4 {
+		proto = protocolICMP
+	}
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return 0, false
+	}
+
+	var answer traceAnswer
+	var quoted []byte
+	switch body := m.Body.(type) {
+	case *icmp.Echo:
+		if m.Type != ipv4.ICMPTypeEchoReply && m.Type != ipv6.ICMPTypeEchoReply {
+			return 0, false
+		}
+		return traceReached, body.ID == p.id && body.Seq == seq
+	case *icmp.TimeExceeded:
+		answer, quoted = traceExpired, body.Data
+	case *icmp.DstUnreach:
+		answer, quoted = traceUnreachable, body.Data
+	default:
+		return 0, false
+	}
+
+	id, quotedSeq, ok := quotedEcho(quoted, p.ipv4)
+	return answer, ok && id == p.id && quotedSeq == seq
+}
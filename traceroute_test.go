@@ -0,0 +1,77 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestParseTraceAnswer(t *testing.T) {
+	p, err := NewPinger(context.Background(), "192.0.2.1")
+	AssertNoError(t, err)
+	probe, _, err := icmpProber{}.Marshal(p, 7)
+	AssertNoError(t, err)
+
+	// IPv4 header of the expired probe, followed by its first 8 bytes
+	quoted := append(make([]byte, ipv4.HeaderLen), probe[:8]...)
+	quoted[0] = 0x45
+	for _, tt := range []struct {
+		typ    icmp.Type
+		body   icmp.MessageBody
+		seq    int
+		answer traceAnswer
+		ok     bool
+	}{
+		{ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quoted}, 7, traceExpired, true},
+		{ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quoted}, 8, traceExpired, false},
+		{ipv4.ICMPTypeDestinationUnreachable, &icmp.DstUnreach{Data: quoted}, 7, traceUnreachable, true},
+		{ipv4.ICMPTypeEchoReply, &icmp.Echo{ID: p.id, Seq: 7}, 7, traceReached, true},
+		{ipv4.ICMPTypeEchoReply, &icmp.Echo{ID: p.id + 1, Seq: 7}, 7, traceReached, false},
+		{ipv4.ICMPTypeEcho, &icmp.Echo{ID: p.id, Seq: 7}, 7, 0, false},
+		{ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quoted[:10]}, 7, 0, false},
+	} {
+		b, err := (&icmp.Message{Type: tt.typ, Body: tt.body}).Marshal(nil)
+		AssertNoError(t, err)
+		answer, ok := p.parseTraceAnswer(b, tt.seq)
+		if ok != tt.ok || (ok && answer != tt.answer) {
+			t.Errorf("Expected %v %v for %v, got %v %v", tt.answer, tt.ok, tt.typ,
+				answer, ok)
+		}
+	}
+}
+
+func TestTraceroute(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	_, err = p.Traceroute(context.Background())
+	AssertError(t, err, "unprivileged")
+
+	p.SetPrivileged(true)
+	tracer := NewTracer(p)
+	tracer.Probes = 2
+	tracer.Timeout = 500 * time.Millisecond
+	var seen []*Hop
+	tracer.OnHop = func(hop *Hop) {
+		seen = append(seen, hop)
+	}
+	hops, err := tracer.Run(context.Background())
+	if MinimalSyscalls {
+		if err != ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Skipf("Can't open raw ICMP socket, skipping: %s", err)
+	}
+	if len(hops) != 1 || len(seen) != 1 {
+		t.Fatalf("Expected 1 hop, got %d", len(hops))
+	}
+	AssertEqualStrings(t, "127.0.0.1", hops[0].Addr)
+	if hops[0].Statistics.PacketsRecv != 2 {
+		t.Errorf("Expected %v, got %v", 2, hops[0].Statistics.PacketsRecv)
+	}
+}
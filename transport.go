@@ -0,0 +1,339 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTCPPort is the port TCP probes connect to by default.
+	DefaultTCPPort = 80
+
+	// DefaultUDPPort is the port UDP probes are sent to by default, the
+	// first port of traceroute, unlikely to be open.
+	DefaultUDPPort = 33434
+
+	// dialTimeout bounds the probes sent on a connection when there is no
+	// ProbeTimeout.
+	dialTimeout = 10 * time.Second
+
+	// dialProbeLen is the length of the sequence number and timestamp of TCP
+	// and UDP probes.
+	dialProbeLen = 4 + timeSliceLength
+)
+
+// SetProtocol sets the transport of the probes: "icmp" for ICMP echo
+// requests, the default, "tcp" to time TCP connections to the port set by
+// SetPort, or "udp" to send UDP datagrams to that port. Networks filtering
+// ICMP often let them through. A TCP connection accepted or refused, and a
+// UDP datagram answered or refused with an ICMP Port Unreachable, are
+// replies. Neither needs privileges, but the options of ICMP, like SetTTL,
+// don't apply to them. It has no effect on the pingers of a PingerPool. TCP
+// and UDP are not available in a pingminimal build, as each probe is sent on
+// a new socket.
+func (p *Pinger) SetProtocol(protocol string) error {
+	if MinimalSyscalls && protocol != "icmp" {
+		return ErrMinimalSyscalls
+	}
+	switch protocol {
+	case "icmp":
+		p.prober = icmpProber{}
+	case "tcp":
+		p.prober = tcpProber{}
+	case "udp":
+		p.prober = udpProber{}
+	default:
+		return fmt.Errorf("Unknown protocol %q", protocol)
+	}
+	return nil
+}
+
+// SetPort sets the destination port of TCP and UDP probes. Zero uses
+// DefaultTCPPort or DefaultUDPPort.
+func (p *Pinger) SetPort(port int) {
+	p.port = port
+}
+
+// SetProber sets a custom transport of the probes.
+func (p *Pinger) SetProber(prober Prober) {
+	p.prober = prober
+}
+
+// probePort returns the destination port of TCP and UDP probes.
+func (p *Pinger) probePort(defaultPort int) int {
+	if p.port == 0 {
+		return defaultPort
+	}
+	return p.port
+}
+
+// dialer returns a dialer from the source address and interface of the
+// pinger.
+func (p *Pinger) dialer() *net.Dialer {
+	d := &net.Dialer{}
+	if source, err := netip.ParseAddr(p.source); err == nil {
+		d.LocalAddr = &net.TCPAddr{IP: source.AsSlice(), Zone: source.Zone()}
+	}
+	if p.iface != nil {
+		iface, ipv4 := p.iface, p.ipv4
+		d.Control = rawControl(func(fd uintptr) error {
+			return bindToInterface(fd, iface, ipv4)
+		})
+	}
+	return d
+}
+
+// probeTimeout returns how long a probe sent on a connection waits for an
+// answer.
+func (p *Pinger) probeTimeout() time.Duration {
+	if p.ProbeTimeout > 0 {
+		return p.ProbeTimeout
+	}
+	return dialTimeout
+}
+
+// marshalDialProbe returns probe seq of TCP and UDP probes, its sequence
+// number and timestamp padded to the size of the pinger.
+func marshalDialProbe(p *Pinger, seq int, dst net.Addr) ([]byte, net.Addr, error) {
+	b := make([]byte, dialProbeLen-timeSliceLength+p.sizeOf(seq))
+	binary.BigEndian.PutUint32(b[0:4], uint32(seq))
+	copy(b[4:dialProbeLen], timeToBytes(p.now()))
+	return b, dst, nil
+}
+
+// parseDialProbe decodes a TCP or UDP probe answered, timed on the clock of
+// the pinger.
+func parseDialProbe(p *Pinger, b []byte) (*Packet, error) {
+	if len(b) < dialProbeLen {
+		return nil, nil
+	}
+	return &Packet{
+		Seq: int(binary.BigEndian.Uint32(b[0:4])),
+		Rtt: p.now().Sub(bytesToTime(b[4:dialProbeLen])),
+	}, nil
+}
+
+// tcpProber times TCP connections.
+type tcpProber struct{}
+
+func (tcpProber) Listen(p *Pinger) (net.PacketConn, error) {
+	network := "tcp6"
+	if p.ipv4 {
+		network = "tcp4"
+	}
+	d, timeout := p.dialer(), p.probeTimeout()
+	return newDialConn(network, func(ctx context.Context, dst net.Addr, b []byte) *dialAnswer {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		conn, err := d.DialContext(ctx, network, dst.String())
+		if err != nil {
+			// A refused connection was answered by the target
+			if isConnRefused(err) {
+				return answered(b, dst)
+			}
+			return nil
+		}
+		conn.Close()
+		return answered(b, dst)
+	}), nil
+}
+
+func (tcpProber) Marshal(p *Pinger, seq int) ([]byte, net.Addr, error) {
+	dst := &net.TCPAddr{IP: p.ipaddr.IP, Port: p.probePort(DefaultTCPPort), Zone: p.ipaddr.Zone}
+	return marshalDialProbe(p, seq, dst)
+}
+
+func (tcpProber) Parse(p *Pinger, b []byte) (*Packet, error) {
+	return parseDialProbe(p, b)
+}
+
+// udpProber sends UDP datagrams, answered by the target or refused with an
+// ICMP Port Unreachable.
+type udpProber struct{}
+
+func (udpProber) Listen(p *Pinger) (net.PacketConn, error) {
+	network := "udp6"
+	if p.ipv4 {
+		network = "udp4"
+	}
+	d, timeout := p.dialer(), p.probeTimeout()
+	if addr, ok := d.LocalAddr.(*net.TCPAddr); ok {
+		d.LocalAddr = &net.UDPAddr{IP: addr.IP, Zone: addr.Zone}
+	}
+	return newDialConn(network, func(ctx context.Context, dst net.Addr, b []byte) *dialAnswer {
+		// A connected socket is told about the Port Unreachable
+		conn, err := d.DialContext(ctx, network, dst.String())
+		if err != nil {
+			return nil
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(timeout))
+		if _, err = conn.Write(b); err == nil {
+			_, err = conn.Read(make([]byte, 1))
+		}
+		if err != nil && !isConnRefused(err) {
+			return nil
+		}
+		return answered(b, dst)
+	}), nil
+}
+
+func (udpProber) Marshal(p *Pinger, seq int) ([]byte, net.Addr, error) {
+	dst := &net.UDPAddr{IP: p.ipaddr.IP, Port: p.probePort(DefaultUDPPort), Zone: p.ipaddr.Zone}
+	return marshalDialProbe(p, seq, dst)
+}
+
+func (udpProber) Parse(p *Pinger, b []byte) (*Packet, error) {
+	return parseDialProbe(p, b)
+}
+
+// dialConn is the socket of probes sent on a new connection each, or
+// through a blocking system call. Writing a probe sends it, and reading
+// returns the answers.
+type dialConn struct {
+	network string
+	probe   dialFunc
+	answers chan *dialAnswer
+
+	ctx   context.Context
+	close context.CancelFunc
+
+	// release is called once the probes in flight are done after Close
+	inflight sync.WaitGroup
+	release  func()
+
+	// wake is closed when the read deadline changes
+	mu       sync.Mutex
+	deadline time.Time
+	wake     chan struct{}
+}
+
+// dialFunc sends probe b to dst, and returns the answer, nil if there is
+// none.
+type dialFunc func(ctx context.Context, dst net.Addr, b []byte) *dialAnswer
+
+// dialAnswer is the answer to a probe, received from addr with TTL ttl if
+// known.
+type dialAnswer struct {
+	b    []byte
+	addr net.Addr
+	ttl  int
+}
+
+// newDialConn returns a dialConn sending probes with probe.
+func newDialConn(network string, probe dialFunc) *dialConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &dialConn{
+		network: network,
+		probe:   probe,
+		answers: make(chan *dialAnswer),
+		ctx:     ctx,
+		close:   cancel,
+		wake:    make(chan struct{}),
+	}
+}
+
+// answered is the dialAnswer of TCP and UDP probes, the probe itself.
+func answered(b []byte, dst net.Addr) *dialAnswer {
+	return &dialAnswer{b: b, addr: dst}
+}
+
+func (c *dialConn) WriteTo(b []byte, dst net.Addr) (int, error) {
+	if c.ctx.Err() != nil {
+		return 0, &net.OpError{Op: "write", Net: c.network, Addr: dst, Err: net.ErrClosed}
+	}
+	b = append([]byte(nil), b...)
+	c.inflight.Add(1)
+	go func() {
+		defer c.inflight.Done()
+		a := c.probe(c.ctx, dst, b)
+		if a == nil {
+			return
+		}
+		select {
+		case c.answers <- a:
+		case <-c.ctx.Done():
+		}
+	}()
+	return len(b), nil
+}
+
+func (c *dialConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, _, addr, err := c.readFrom(b)
+	return n, addr, err
+}
+
+// readFrom reads an answer like ReadFrom, with its TTL.
+func (c *dialConn) readFrom(b []byte) (n, ttl int, addr net.Addr, err error) {
+	for {
+		c.mu.Lock()
+		deadline, wake := c.deadline, c.wake
+		c.mu.Unlock()
+
+		var expired <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, 0, nil, &net.OpError{Op: "read", Net: c.network, Err: os.ErrDeadlineExceeded}
+			}
+			timer = time.NewTimer(d)
+			expired = timer.C
+		}
+		var a *dialAnswer
+		select {
+		case a = <-c.answers:
+		case <-expired:
+		case <-wake:
+		case <-c.ctx.Done():
+			err = &net.OpError{Op: "read", Net: c.network, Err: net.ErrClosed}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if a != nil {
+			return copy(b, a.b), a.ttl, a.addr, nil
+		}
+		if err != nil {
+			return 0, 0, nil, err
+		}
+	}
+}
+
+func (c *dialConn) Close() error {
+	c.close()
+	if c.release != nil {
+		go func() {
+			c.inflight.Wait()
+			c.release()
+		}()
+	}
+	return nil
+}
+
+func (c *dialConn) LocalAddr() net.Addr {
+	return nil
+}
+
+func (c *dialConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *dialConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	close(c.wake)
+	c.wake = make(chan struct{})
+	return nil
+}
+
+func (c *dialConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
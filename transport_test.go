@@ -0,0 +1,116 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetProtocol(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertError(t, p.SetProtocol("sctp"), "unknown protocol")
+	AssertNoError(t, p.SetProtocol("icmp"))
+	if MinimalSyscalls {
+		if err := p.SetProtocol("tcp"); err != ErrMinimalSyscalls {
+			t.Errorf("Expected %v, got %v", ErrMinimalSyscalls, err)
+		}
+	}
+}
+
+// runProtocol pings 127.0.0.1 with protocol to port, until three replies or
+// for half a second.
+func runProtocol(t *testing.T, protocol string, port int) *Statistics {
+	if MinimalSyscalls {
+		t.Skip("TCP and UDP probes are not available in a pingminimal build, skipping")
+	}
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	AssertNoError(t, p.SetProtocol(protocol))
+	p.SetPort(port)
+	p.Count = 3
+	p.Interval = 10 * time.Millisecond
+	p.ProbeTimeout = 200 * time.Millisecond
+	p.Timeout = 500 * time.Millisecond
+	AssertNoError(t, p.Run(context.Background()))
+	return p.Statistics()
+}
+
+func TestTCPProbe(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	AssertNoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	s := runProtocol(t, "tcp", port)
+	if s.PacketsRecv != 3 || len(s.Rtts) != 3 {
+		t.Errorf("Expected %v replies, got %v", 3, s.PacketsRecv)
+	}
+
+	// A refused connection is a reply too
+	l.Close()
+	s = runProtocol(t, "tcp", port)
+	if s.PacketsRecv != 3 {
+		t.Errorf("Expected %v replies, got %v", 3, s.PacketsRecv)
+	}
+}
+
+func TestUDPProbe(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	AssertNoError(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// Never answered
+	s := runProtocol(t, "udp", port)
+	if s.PacketsRecv != 0 || s.PacketsSent == 0 {
+		t.Errorf("Expected no reply, got %v/%v", s.PacketsRecv, s.PacketsSent)
+	}
+
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(b[:n], addr)
+		}
+	}()
+	s = runProtocol(t, "udp", port)
+	if s.PacketsRecv != 3 {
+		t.Errorf("Expected %v replies, got %v", 3, s.PacketsRecv)
+	}
+
+	// Refused with an ICMP Port Unreachable
+	conn.Close()
+	s = runProtocol(t, "udp", port)
+	if s.PacketsRecv != 3 {
+		t.Errorf("Expected %v replies, got %v", 3, s.PacketsRecv)
+	}
+}
+
+func TestDialProbeClock(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	clock := &fixedClock{now: time.Unix(1500000000, 0)}
+	p.SetClock(clock)
+	b, _, err := marshalDialProbe(p, 7, nil)
+	AssertNoError(t, err)
+
+	clock.now = clock.now.Add(5 * time.Millisecond)
+	pkt, err := parseDialProbe(p, b)
+	AssertNoError(t, err)
+	if pkt.Seq != 7 || pkt.Rtt != 5*time.Millisecond {
+		t.Errorf("Expected seq %v and %v, got %v and %v", 7, 5*time.Millisecond,
+			pkt.Seq, pkt.Rtt)
+	}
+}
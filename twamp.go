@@ -0,0 +1,125 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// TWAMPPort is the well-known TWAMP reflector port.
+	TWAMPPort = 862
+
+	// twampPacketLen is the size of an unauthenticated reflected packet
+	// without padding. Sender packets are padded to the same size, as RFC
+	// 5357 recommends.
+	twampPacketLen = 41
+
+	// ntpEpochOffset is the number of seconds between the NTP epoch (1900)
+	// and the Unix epoch (1970).
+	ntpEpochOffset = 2208988800
+)
+
+// SetTWAMP makes the pinger send TWAMP-Light test packets (RFC 5357 section
+// 4.1.2, unauthenticated mode) to the reflector listening on the given UDP
+// port of the target, instead of ICMP echo requests. A port of zero selects
+// TWAMPPort. Round-trip times exclude the time the packet spent in the
+// reflector, as reported by its timestamps.
+//
+// TWAMP-Light test packets are regular UDP datagrams and don't require
+// super-user privileges.
+func (p *Pinger) SetTWAMP(port int) {
+	if port == 0 {
+		port = TWAMPPort
+	}
+	p.prober = twampProber{port: port}
+}
+
+// twampProber sends TWAMP-Light test packets.
+type twampProber struct {
+	port int
+}
+
+func (t twampProber) Listen(p *Pinger) (net.PacketConn, error) {
+	network := "udp6"
+	if p.ipv4 {
+		network = "udp4"
+	}
+	var lc net.ListenConfig
+	if p.iface != nil || batchReads {
+		lc.Control = rawControl(func(fd uintptr) error {
+			if batchReads {
+				// Best effort, as for ICMP sockets
+				_ = setTimestamps(fd)
+			}
+			if p.iface != nil {
+				return bindToInterface(fd, p.iface, p.ipv4)
+			}
+			return nil
+		})
+	}
+	conn, err := lc.ListenPacket(context.Background(), network, net.JoinHostPort(p.source, "0"))
+	if err != nil || !batchReads {
+		return conn, err
+	}
+	// Read by a batchReader, with their kernel timestamps
+	if p.ipv4 {
+		return &controlConn{PacketConn: conn, p4: ipv4.NewPacketConn(conn)}, nil
+	}
+	return &controlConn{PacketConn: conn, p6: ipv6.NewPacketConn(conn)}, nil
+}
+
+func (t twampProber) Marshal(p *Pinger, seq int) ([]byte, net.Addr, error) {
+	size := twampPacketLen
+	if p.sizeOf(seq) > size {
+		size = p.sizeOf(seq)
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b[0:4], uint32(seq))
+	binary.BigEndian.PutUint64(b[4:12], timeToNTP(p.now()))
+	// Error estimate: not synchronized, multiplier 1, scale 0.
+	binary.BigEndian.PutUint16(b[12:14], 1)
+
+	dst := &net.UDPAddr{IP: p.ipaddr.IP, Port: t.port, Zone: p.ipaddr.Zone}
+	return b, dst, nil
+}
+
+func (t twampProber) Parse(p *Pinger, b []byte) (*Packet, error) {
+	now := p.receiveTime()
+	if len(b) < twampPacketLen {
+		// Not a reflected test packet, ignore it
+		return nil, nil
+	}
+
+	// Reflected packet layout: sequence number (4), timestamp (8), error
+	// estimate (2), MBZ (2), receive timestamp (8), sender sequence number
+	// (4), sender timestamp (8), sender error estimate (2), MBZ (2) and
+	// sender TTL (1).
+	transmit := ntpToTime(binary.BigEndian.Uint64(b[4:12]))
+	receive := ntpToTime(binary.BigEndian.Uint64(b[16:24]))
+	seq := binary.BigEndian.Uint32(b[24:28])
+	sent := ntpToTime(binary.BigEndian.Uint64(b[28:36]))
+
+	return &Packet{
+		Rtt: now.Sub(sent) - transmit.Sub(receive),
+		Seq: int(seq),
+	}, nil
+}
+
+// timeToNTP returns t in the 64-bit NTP timestamp format.
+func timeToNTP(t time.Time) uint64 {
+	sec := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return sec<<32 | frac
+}
+
+// ntpToTime returns the time of the 64-bit NTP timestamp ts.
+func ntpToTime(ts uint64) time.Time {
+	sec := int64(ts>>32) - ntpEpochOffset
+	nsec := int64((ts & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(sec, nsec)
+}
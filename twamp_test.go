@@ -0,0 +1,126 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNTPTimestamp(t *testing.T) {
+	now := time.Unix(1500000000, 123456789)
+	d := ntpToTime(timeToNTP(now)).Sub(now)
+	if d < -time.Nanosecond || d > time.Nanosecond {
+		t.Errorf("Expected %v, got %v", now, now.Add(d))
+	}
+}
+
+// fixedClock is a Clock standing still at now, with the timers of the
+// system.
+type fixedClock struct {
+	systemClock
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestTWAMPClock(t *testing.T) {
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetTWAMP(0)
+	clock := &fixedClock{now: time.Unix(1500000000, 0)}
+	p.SetClock(clock)
+
+	b, _, err := p.prober.Marshal(p, 7)
+	AssertNoError(t, err)
+	out := make([]byte, len(b))
+	binary.BigEndian.PutUint64(out[4:12], timeToNTP(clock.now.Add(30*time.Millisecond)))
+	binary.BigEndian.PutUint64(out[16:24], timeToNTP(clock.now.Add(10*time.Millisecond)))
+	copy(out[24:38], b[0:14])
+
+	clock.now = clock.now.Add(50 * time.Millisecond)
+	// Kernel timestamps are ignored with a clock
+	p.received = time.Now()
+	pkt, err := p.prober.Parse(p, out)
+	AssertNoError(t, err)
+	if pkt.Seq != 7 {
+		t.Errorf("Expected %v, got %v", 7, pkt.Seq)
+	}
+	// 50ms on the clock, 20ms of them in the reflector, within the
+	// resolution of NTP timestamps
+	if d := pkt.Rtt - 30*time.Millisecond; d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("Expected %v, got %v", 30*time.Millisecond, pkt.Rtt)
+	}
+
+	// Timed by the kernel otherwise
+	p.SetClock(nil)
+	sent := time.Now()
+	b, _, err = p.prober.Marshal(p, 8)
+	AssertNoError(t, err)
+	binary.BigEndian.PutUint64(out[4:12], timeToNTP(sent))
+	binary.BigEndian.PutUint64(out[16:24], timeToNTP(sent))
+	copy(out[24:38], b[0:14])
+	p.received = sent.Add(time.Second)
+	pkt, err = p.prober.Parse(p, out)
+	AssertNoError(t, err)
+	if pkt.Rtt < 999*time.Millisecond || pkt.Rtt > time.Second {
+		t.Errorf("Expected about %v, got %v", time.Second, pkt.Rtt)
+	}
+}
+
+func TestTWAMP(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	AssertNoError(t, err)
+	defer conn.Close()
+
+	// Minimal TWAMP-Light reflector
+	go func() {
+		b := make([]byte, 512)
+		for seq := uint32(0); ; seq++ {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			received := timeToNTP(time.Now())
+			out := make([]byte, n)
+			binary.BigEndian.PutUint32(out[0:4], seq)
+			binary.BigEndian.PutUint64(out[16:24], received)
+			copy(out[24:38], b[0:14])
+			out[40] = 255
+			time.Sleep(50 * time.Millisecond)
+			binary.BigEndian.PutUint64(out[4:12], timeToNTP(time.Now()))
+			conn.WriteTo(out, addr)
+		}
+	}()
+
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	p.SetTWAMP(conn.LocalAddr().(*net.UDPAddr).Port)
+	p.Count = 3
+	p.Interval = 100 * time.Millisecond
+	p.Timeout = 2 * time.Second
+
+	var seqs []int
+	p.OnRecv = func(pkt *Packet) {
+		seqs = append(seqs, pkt.Seq)
+	}
+	AssertNoError(t, p.Listen())
+	p.Run(context.Background())
+
+	stats := p.Statistics()
+	if stats.PacketsRecv < 3 {
+		t.Fatalf("Expected at least %v, got %v", 3, stats.PacketsRecv)
+	}
+	for i, seq := range seqs {
+		if seq != i {
+			t.Errorf("Expected %v, got %v", i, seq)
+		}
+	}
+	// The time spent in the reflector is not part of the round-trip time
+	if stats.MinRtt >= 50*time.Millisecond {
+		t.Errorf("Expected a round-trip time under 50ms, got %v", stats.MinRtt)
+	}
+}
@@ -0,0 +1,191 @@
+package ping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a period of time during which probing is active, see
+// Pinger.Windows.
+type Window interface {
+	// Contains reports whether t is inside the window.
+	Contains(t time.Time) bool
+}
+
+// TimeRange is a Window repeating every day, or on some days of the week.
+type TimeRange struct {
+	// Start and End are the bounds of the range, as offsets since midnight.
+	// If End is before Start, the range spans midnight.
+	Start time.Duration
+	End   time.Duration
+
+	// Weekdays are the days on which the range starts. Empty means every
+	// day.
+	Weekdays []time.Weekday
+
+	// Location is the time zone of the range. Default is local time.
+	Location *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseTimeRange parses a time range such as "08:00-18:00",
+// "Mon-Fri 08:00-18:00" or "Sat,Sun 22:00-06:00", in local time.
+func ParseTimeRange(s string) (*TimeRange, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("Invalid time range %q", s)
+	}
+
+	r := &TimeRange{}
+	if len(fields) == 2 {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		r.Weekdays = days
+	}
+
+	bounds := strings.Split(fields[len(fields)-1], "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("Invalid time range %q", s)
+	}
+	var err error
+	if r.Start, err = parseTimeOfDay(bounds[0]); err != nil {
+		return nil, err
+	}
+	if r.End, err = parseTimeOfDay(bounds[1]); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("Invalid weekdays %q", s)
+		}
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return nil, fmt.Errorf("Invalid weekday %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+				return nil, fmt.Errorf("Invalid weekday %q", bounds[1])
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("Invalid time of day %q", s)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("Invalid time of day %q", s)
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("Invalid time of day %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Contains reports whether t is inside the range.
+func (r *TimeRange) Contains(t time.Time) bool {
+	loc := r.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+
+	if r.Start <= r.End {
+		return r.onDay(t.Weekday()) && offset >= r.Start && offset < r.End
+	}
+	// The range spans midnight, t is either in the part starting today or
+	// in the part that started yesterday.
+	return (r.onDay(t.Weekday()) && offset >= r.Start) ||
+		(r.onDay((t.Weekday()+6)%7) && offset < r.End)
+}
+
+// next returns the first start of the range after t, or the zero time if
+// the range never starts.
+func (r *TimeRange) next(t time.Time) time.Time {
+	loc := r.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	for i := 0; i <= 7; i++ {
+		midnight := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, loc)
+		if start := midnight.Add(r.Start); start.After(t) && r.onDay(midnight.Weekday()) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+func (r *TimeRange) onDay(d time.Weekday) bool {
+	if len(r.Weekdays) == 0 {
+		return true
+	}
+	for _, day := range r.Weekdays {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// active reports whether the pinger should send echo requests at t.
+func (p *Pinger) active(t time.Time) bool {
+	if len(p.Windows) == 0 {
+		return true
+	}
+	for _, w := range p.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextActive returns when the pinger, outside of its windows at t, enters
+// the next one, if all its windows are TimeRanges.
+func (p *Pinger) nextActive(t time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, w := range p.Windows {
+		r, ok := w.(*TimeRange)
+		if !ok {
+			return time.Time{}, false
+		}
+		if start := r.next(t); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next, !next.IsZero()
+}
@@ -0,0 +1,83 @@
+package ping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	// 2017-01-02 is a Monday
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2017, 1, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		Range   string
+		Inside  []time.Time
+		Outside []time.Time
+	}{
+		{
+			Range:   "08:00-18:00",
+			Inside:  []time.Time{at(2, 8, 0), at(8, 17, 59)},
+			Outside: []time.Time{at(2, 7, 59), at(2, 18, 0)},
+		}, {
+			Range:   "Mon-Fri 08:00-18:00",
+			Inside:  []time.Time{at(2, 12, 0), at(6, 12, 0)},
+			Outside: []time.Time{at(7, 12, 0), at(8, 12, 0)},
+		}, {
+			Range:   "Fri,Sat 22:00-06:00",
+			Inside:  []time.Time{at(6, 23, 0), at(7, 5, 0), at(8, 5, 59)},
+			Outside: []time.Time{at(6, 5, 0), at(8, 22, 0), at(6, 21, 0)},
+		}, {
+			Range:   "Sat-Sun 00:00-24:00",
+			Inside:  []time.Time{at(7, 0, 0), at(8, 23, 59)},
+			Outside: []time.Time{at(6, 23, 59), at(9, 0, 0)},
+		},
+	}
+
+	for _, set := range tests {
+		r, err := ParseTimeRange(set.Range)
+		AssertNoError(t, err)
+		r.Location = time.UTC
+		for _, tm := range set.Inside {
+			if !r.Contains(tm) {
+				t.Errorf("Expected %v to be inside %q", tm, set.Range)
+			}
+		}
+		for _, tm := range set.Outside {
+			if r.Contains(tm) {
+				t.Errorf("Expected %v to be outside %q", tm, set.Range)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"", "08:00", "Foo 08:00-09:00",
+		"25:00-26:00", "08:60-09:00", "Mon Tue 08:00-09:00"} {
+		_, err := ParseTimeRange(invalid)
+		AssertError(t, err, invalid)
+	}
+}
+
+func TestTimeRangeNext(t *testing.T) {
+	// 2017-01-02 is a Monday
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2017, 1, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		Range string
+		T     time.Time
+		Next  time.Time
+	}{
+		{"08:00-18:00", at(2, 7, 0), at(2, 8, 0)},
+		{"08:00-18:00", at(2, 8, 0), at(3, 8, 0)},
+		{"Mon-Fri 08:00-18:00", at(6, 19, 0), at(9, 8, 0)},
+		{"Fri,Sat 22:00-06:00", at(8, 5, 0), at(13, 22, 0)},
+	}
+	for _, set := range tests {
+		r, err := ParseTimeRange(set.Range)
+		AssertNoError(t, err)
+		r.Location = time.UTC
+		if next := r.next(set.T); !next.Equal(set.Next) {
+			t.Errorf("%q: Expected %v, got %v", set.Range, set.Next, next)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ping
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// WakeOnLANAddr is the default destination of Wake-on-LAN magic packets, the
+// limited broadcast address on the discard port.
+const WakeOnLANAddr = "255.255.255.255:9"
+
+// MagicPacket returns the Wake-on-LAN magic packet waking the host with the
+// given MAC address: 6 bytes of 0xff followed by 16 repetitions of the
+// address.
+func MagicPacket(mac net.HardwareAddr) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("Invalid MAC address %s for Wake-on-LAN", mac)
+	}
+	b := bytes.Repeat([]byte{0xff}, 6)
+	return append(b, bytes.Repeat(mac, 16)...), nil
+}
+
+// WakeOnLAN sends a magic packet waking the host with the given MAC address
+// to addr, a UDP host:port, or to WakeOnLANAddr if addr is empty.
+func WakeOnLAN(mac net.HardwareAddr, addr string) error {
+	w, err := newWaker(mac, addr)
+	if err != nil {
+		return err
+	}
+	return w.wake()
+}
+
+// SetWakeOnLAN makes the pinger send a Wake-on-LAN magic packet for the given
+// MAC address to addr, as WakeOnLAN does, before its first echo request and
+// with every following one until the target replies. The time it took is
+// reported in Statistics.TimeToFirstReply. It is not available in a
+// pingminimal build, as each magic packet is sent on a new socket.
+func (p *Pinger) SetWakeOnLAN(mac net.HardwareAddr, addr string) error {
+	if MinimalSyscalls {
+		return ErrMinimalSyscalls
+	}
+	w, err := newWaker(mac, addr)
+	if err != nil {
+		return err
+	}
+	p.waker = w
+	return nil
+}
+
+// waker sends Wake-on-LAN magic packets.
+type waker struct {
+	packet []byte
+	addr   *net.UDPAddr
+}
+
+func newWaker(mac net.HardwareAddr, addr string) (*waker, error) {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return nil, err
+	}
+	if addr == "" {
+		addr = WakeOnLANAddr
+	}
+	uaddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &waker{packet: packet, addr: uaddr}, nil
+}
+
+func (w *waker) wake() error {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// The net package enables SO_BROADCAST on UDP sockets
+	if _, err := conn.WriteTo(w.packet, w.addr); err != nil {
+		return fmt.Errorf("Error sending Wake-on-LAN packet: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	b, err := MagicPacket(mac)
+	AssertNoError(t, err)
+	if len(b) != 102 {
+		t.Fatalf("Expected %v, got %v", 102, len(b))
+	}
+	if !bytes.Equal(b[:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("Expected a 0xff header, got %x", b[:6])
+	}
+	for i := 6; i < len(b); i += 6 {
+		if !bytes.Equal(b[i:i+6], mac) {
+			t.Errorf("Expected %x at %d, got %x", []byte(mac), i, b[i:i+6])
+		}
+	}
+
+	long, _ := net.ParseMAC("00:11:22:33:44:55:66:77")
+	_, err = MagicPacket(long)
+	AssertError(t, err, "8-byte MAC")
+}
+
+func TestSetWakeOnLAN(t *testing.T) {
+	if MinimalSyscalls {
+		t.Skip("Wake-on-LAN is not available in a pingminimal build, skipping")
+	}
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	AssertNoError(t, err)
+	defer listener.Close()
+
+	p, err := NewPinger(context.Background(), "127.0.0.1")
+	AssertNoError(t, err)
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	AssertNoError(t, p.SetWakeOnLAN(mac, listener.LocalAddr().String()))
+	p.SetPrivileged(true)
+	p.Count = 1
+	p.Timeout = time.Second
+	if err := p.Run(context.Background()); err != nil {
+		t.Skipf("Can't ping 127.0.0.1, skipping: %s", err)
+	}
+
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 200)
+	n, _, err := listener.ReadFrom(b)
+	AssertNoError(t, err)
+	want, _ := MagicPacket(mac)
+	if !bytes.Equal(b[:n], want) {
+		t.Errorf("Expected %x, got %x", want, b[:n])
+	}
+
+	stats := p.Statistics()
+	if stats.PacketsRecv != 1 {
+		t.Fatalf("Expected %v, got %v", 1, stats.PacketsRecv)
+	}
+	if stats.TimeToFirstReply <= 0 || stats.TimeToFirstReply > time.Second {
+		t.Errorf("Expected a time to first reply within 1s, got %v",
+			stats.TimeToFirstReply)
+	}
+}